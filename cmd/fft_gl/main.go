@@ -1,6 +1,7 @@
 package main
 
 import (
+	"io"
 	"math"
 	"os"
 	"runtime"
@@ -20,6 +21,9 @@ func init() {
 	runtime.LockOSThread()
 }
 
+// songs with the same format & settings reuse the transform's plan and buffers
+var planCache = fft.NewPlanCache(2)
+
 func main() {
 	runtime.LockOSThread()
 	var wg sync.WaitGroup
@@ -38,6 +42,18 @@ func main() {
 
 	initLog()
 
+	if wisdom := os.Getenv("FFTW_WISDOM"); wisdom != "" {
+		if err := fft.ImportWisdom(wisdom); err != nil {
+			log.Warn().Err(err).Msg("could not import wisdom")
+		}
+
+		defer func() {
+			if err := fft.ExportWisdom(wisdom); err != nil {
+				log.Warn().Err(err).Msg("could not export wisdom")
+			}
+		}()
+	}
+
 	window := NewWindow()
 	if err := window.Init(1280, 720, os.Getenv("FS") == "true", "Visualizer - ..."); err != nil {
 		panic(err)
@@ -61,17 +77,43 @@ func initLog() {
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 }
 
+// a reader of its own over the song's mmap. The analysis finishes before playback does, so closing one of the readers
+// must not unmap the file under the others, it has no Close and doFFT closes the mmap once the song has played
+func mmapReader(m *mmap.ReaderAt) io.ReadSeeker {
+	return struct{ io.ReadSeeker }{&util.MMapSeeker{M: m}}
+}
+
 func doFFT(window *window, fileName string) {
 	m, err := mmap.Open(fileName)
 	if err != nil {
 		panic(err)
 	}
 
-	fftInput, err := wav.ReadWav(&util.MMapSeeker{M: m})
+	defer func() {
+		if err := m.Close(); err != nil {
+			log.Warn().Err(err).Msg("could not close song")
+		}
+	}()
+
+	fftInput, err := wav.ReadWavMode(mmapReader(m), wav.Lenient)
 	if err != nil {
 		panic(err)
 	}
 
+	var planning fft.Planning
+	if rigor := os.Getenv("FFT_RIGOR"); rigor != "" {
+		if planning.Rigor, err = fft.ParseRigor(rigor); err != nil {
+			panic(err)
+		}
+	}
+
+	var sizing fft.Sizing
+	if name := os.Getenv("FFT_SIZING"); name != "" {
+		if sizing, err = fft.ParseSizing(name); err != nil {
+			panic(err)
+		}
+	}
+
 	streamer := &streamingFFT{
 		Audio:             fftInput,
 		Window:            fft.BlackmanNuttallWindow,
@@ -81,6 +123,7 @@ func doFFT(window *window, fileName string) {
 		Gamma:             2.2,
 		WindowSize:        time.Millisecond * 165,
 		FrameRate:         30,
+		Sizing:            sizing,
 		SmoothingAlpha:    0.45,
 		PercentileHigh:    0.07,
 		PercentileLow:     0.4,
@@ -88,11 +131,24 @@ func doFFT(window *window, fileName string) {
 		SmoothingPoints:   3,
 		EstimateFrameSize: time.Millisecond * 800,
 		EstimateStride:    time.Millisecond * 6500,
+		SinglePrecision:   os.Getenv("SINGLE_PRECISION") == "true",
+		ConstantQ:         os.Getenv("CONSTANT_Q") == "true",
+		Chroma:            os.Getenv("CHROMA") == "true",
+		Stereo:            os.Getenv("STEREO") == "true",
+		FFTOptions:        fft.Options{Planning: planning, Cache: planCache},
 	}
 
-	streamer.init()
+	if os.Getenv("PITCH") == "true" {
+		if streamer.PitchInput, err = wav.ReadWavMode(mmapReader(m), wav.Lenient); err != nil {
+			panic(err)
+		}
+	}
 
-	binsPerFrame := streamer.fft.NumberOutputFrequencies()
+	if err = streamer.init(); err != nil {
+		panic(err)
+	}
+
+	binsPerFrame := streamer.numberOutputFrequencies()
 	log.Info().
 		Int("sampleRate", fftInput.SampleRate()).
 		Int("n", binsPerFrame).
@@ -110,7 +166,7 @@ func doFFT(window *window, fileName string) {
 	to := make(chan FFTResult, streamer.FrameRate*10)
 	go streamer.StreamFFT(to)
 
-	audioPlay, err := wav.ReadWav(&util.MMapSeeker{M: m})
+	audioPlay, err := wav.ReadWavMode(mmapReader(m), wav.Lenient)
 	if err != nil {
 		panic(err)
 	}
@@ -155,6 +211,17 @@ func doFFT(window *window, fileName string) {
 
 		playAudio()
 		window.Show(v.Data)
+		if v.Beat {
+			window.Pulse()
+		}
+
+		if streamer.PitchInput != nil {
+			window.ShowPitch(streamer.pitchPosition(v.Pitch))
+		}
+
+		if v.Panning != nil {
+			window.ShowStereo(v.Panning, v.Stereo.Correlation, v.Stereo.Balance)
+		}
 	}
 
 	// handle the first value in this way, play one frame of audio early
@@ -5,8 +5,13 @@ import (
 	"time"
 
 	"github.com/Twister915/vis.go/pkg/audio"
+	"github.com/Twister915/vis.go/pkg/beat"
 	"github.com/Twister915/vis.go/pkg/bin"
+	"github.com/Twister915/vis.go/pkg/chroma"
+	"github.com/Twister915/vis.go/pkg/features"
 	"github.com/Twister915/vis.go/pkg/fft"
+	"github.com/Twister915/vis.go/pkg/pitch"
+	"github.com/Twister915/vis.go/pkg/stereo"
 	"github.com/Twister915/vis.go/pkg/util"
 	"github.com/rs/zerolog/log"
 )
@@ -20,7 +25,26 @@ type streamingFFT struct {
 	Bins       int
 	WindowSize time.Duration
 	FrameRate  int
-	PowTwo     bool
+	// the same as Sizing: fft.NextPow2Size
+	PowTwo bool
+	Sizing fft.Sizing
+
+	// use the float32/fftwf pipeline instead of float64
+	SinglePrecision bool
+	// compute the bars with a constant-Q transform, one bin per bar spaced evenly in pitch from FMin to FMax, instead of
+	// summing linear FFT bins along the gamma curve. Takes precedence over SinglePrecision
+	ConstantQ bool
+	// show the chroma instead of the spectrum, 12 bars (one per pitch class, C to B) folded from the FFT frames. Takes
+	// precedence over ConstantQ and SinglePrecision, and sets Bins
+	Chroma bool
+	// measure the stereo image of every frame and pan every bar (see FFTResult.Stereo and Panning). This needs the
+	// complex frames of the linear float64 transform, so it takes precedence over SinglePrecision and is ignored with
+	// ConstantQ or Chroma. The bars come from the unscaled magnitudes, as with Scaling: fft.Magnitude
+	Stereo     bool
+	FFTOptions fft.Options `json:"-"`
+
+	// another input on the same audio, when set the pitch of every frame is tracked from it (see FFTResult.Pitch)
+	PitchInput audio.Input `json:"-"`
 
 	SmoothingAlpha float64
 	PercentileHigh float64
@@ -30,7 +54,9 @@ type streamingFFT struct {
 	SmoothingPoints int
 
 	fftBuffer      [][]float64
+	fftBuffer32    [][]float32
 	binBuffer      [][]float64
+	binBuffer32    [][]float32
 	combinedBuffer []float64
 
 	hasLastBinned bool
@@ -44,32 +70,256 @@ type streamingFFT struct {
 	nMuSigma     int
 
 	fft            *fft.FFTByFrame
+	fft32          *fft.FFTByFrame32
+	cqt            *fft.ConstantQ
 	precomputedBin *bin.CachedBinsSpec
+
+	chroma      *chroma.Filterbank
+	chromaFrame []float64
+	// every streamed frame's chroma added up, for estimating the key
+	chromaSum []float64
+
+	// predicts beats from the flux of the bars, which is an onset envelope whatever mode the bars are in
+	beats        *beat.Predictor
+	lastCombined []float64
+
+	pitch *pitch.Tracker
+
+	// spectral descriptors of the linear FFT frames, nil in constant-Q mode. In single precision mode the frame is
+	// widened into the buffers first
+	features        *features.Extractor
+	featureSpectrum [][]float64
+	featureSamples  [][]float64
+
+	// the complex frame in stereo mode, indexed [bin][channel]
+	stereoSpectrum [][]complex128
+	// each bin's panning times its energy and its energy, indexed [bin][2], which bin into the sums for each bar's
+	// weighted mean panning (indexed [2][bar])
+	stereoWeights [][]float64
+	stereoBars    [][]float64
 }
 
 type FFTResult struct {
 	I    int
 	Err  error
 	Data [][]float64
+
+	// a beat is predicted on this frame, and how far through the current beat it is (see beat.Predictor.Phase)
+	Beat      bool
+	BeatPhase float64
+
+	// the pitch of the frame, when there is a PitchInput
+	Pitch pitch.Estimate
+
+	// the timbre of the frame, zero in constant-Q mode
+	Features features.Frame
+
+	// the stereo image of the frame, and where each bar sits from -1 (left) to 1 (right), in Stereo mode
+	Stereo  stereo.Frame
+	Panning []float64
 }
 
-func (f *streamingFFT) init() {
+func (f *streamingFFT) init() (err error) {
 	log.Info().Interface("config", f).Msg("init streaming FFT")
-	samplesPerFrame := int(f.WindowSize / f.Audio.Timebase())
+	sizing := f.Sizing
 	if f.PowTwo {
-		samplesPerFrame = fft.NextPower2(samplesPerFrame)
+		sizing = fft.NextPow2Size
+	}
+
+	requested := int(f.WindowSize / f.Audio.Timebase())
+	if samplesPerFrame := sizing.Size(requested); samplesPerFrame != requested {
 		f.WindowSize = time.Duration(samplesPerFrame) * f.Audio.Timebase()
-		log.Info().Str("dur", f.WindowSize.String()).Int("samplesPerFrame", samplesPerFrame).Msg("rounded to pow2")
+		log.Info().Str("dur", f.WindowSize.String()).Int("samplesPerFrame", samplesPerFrame).Str("sizing", sizing.String()).Msg("resized window")
+	}
+
+	if f.Chroma {
+		f.Bins = chroma.PitchClasses
 	}
 
 	channels := f.Audio.Channels()
 
-	f.fftBuffer = util.Create2DFloats(samplesPerFrame, channels)
 	f.binBuffer = util.Create2DFloats(channels, f.Bins)
 	f.lastBinned = util.Create2DFloats(channels, f.Bins)
 	f.combinedBuffer = make([]float64, f.Bins)
-	f.fft = fft.NewFFTByFrame(f.Audio, f.WindowSize, time.Second/time.Duration(f.FrameRate), f.Window, false)
-	f.precomputedBin = bin.PrecomputeBinSpec(f.fft.NumberOutputFrequencies(), f.Audio.SampleRate(), f.Bins, f.FMin, f.FMax, f.Gamma)
+	f.lastCombined = make([]float64, f.Bins)
+	f.beats = beat.NewPredictor(float64(f.FrameRate), beat.Config{})
+	windowMove := time.Second / time.Duration(f.FrameRate)
+	// after the transform, whichever one it is
+	defer func() {
+		if err == nil {
+			err = f.initPitch(windowMove)
+		}
+	}()
+	defer f.initFeatures()
+
+	// the linear transforms keep their samples for the zero crossing rate
+	f.FFTOptions.KeepSamples = true
+
+	if f.Chroma {
+		f.fft = fft.NewFFTByFrameOptions(f.Audio, f.WindowSize, windowMove, f.Window, f.FFTOptions)
+		f.fftBuffer = util.Create2DFloats(f.fft.NumberOutputFrequencies(), channels)
+		f.chroma = chroma.NewFilterbank(f.Audio.SampleRate(), f.fft.FFTSize(), chroma.Config{})
+		f.chromaFrame = make([]float64, chroma.PitchClasses)
+		f.chromaSum = make([]float64, chroma.PitchClasses)
+		return
+	}
+
+	if f.ConstantQ {
+		// as many bins per octave as it takes to spread the bars from FMin to FMax
+		binsPerOctave := int(math.Round(float64(f.Bins) / math.Log2(f.FMax/f.FMin)))
+		if binsPerOctave < 1 {
+			binsPerOctave = 1
+		}
+
+		if f.cqt, err = fft.NewConstantQ(f.Audio, windowMove, fft.ConstantQConfig{
+			MinFreq:       f.FMin,
+			BinsPerOctave: binsPerOctave,
+			Bins:          f.Bins,
+		}, f.FFTOptions); err != nil {
+			return
+		}
+
+		f.fftBuffer = util.Create2DFloats(f.cqt.NumberOutputFrequencies(), channels)
+		return
+	}
+
+	// the buffers hold one frame of output, (fft size / 2) + 1 bins
+	if f.SinglePrecision && !f.Stereo {
+		f.fft32 = fft.NewFFTByFrame32Options(f.Audio, f.WindowSize, windowMove, f.Window, f.FFTOptions)
+		f.fftBuffer32 = util.CreateNDTs(float32(0), f.fft32.NumberOutputFrequencies(), channels).([][]float32)
+		f.binBuffer32 = util.CreateNDTs(float32(0), channels, f.Bins).([][]float32)
+	} else {
+		f.fft = fft.NewFFTByFrameOptions(f.Audio, f.WindowSize, windowMove, f.Window, f.FFTOptions)
+		f.fftBuffer = util.Create2DFloats(f.fft.NumberOutputFrequencies(), channels)
+		if f.Stereo {
+			f.stereoSpectrum = make([][]complex128, f.fft.NumberOutputFrequencies())
+			for i := range f.stereoSpectrum {
+				f.stereoSpectrum[i] = make([]complex128, channels)
+			}
+
+			f.stereoWeights = util.Create2DFloats(f.fft.NumberOutputFrequencies(), 2)
+			f.stereoBars = util.Create2DFloats(2, f.Bins)
+		}
+	}
+
+	f.precomputedBin = bin.PrecomputeBinSpec(f.numberOutputFrequencies(), f.Audio.SampleRate(), f.Bins, f.FMin, f.FMax, f.Gamma)
+	return
+}
+
+// the pitch tracker's frames are shorter, they start late enough to be centered on the same samples
+func (f *streamingFFT) initPitch(windowMove time.Duration) (err error) {
+	if f.PitchInput == nil {
+		return
+	}
+
+	if f.pitch, err = pitch.NewTracker(f.PitchInput, windowMove, pitch.Config{}, f.FFTOptions); err != nil {
+		return
+	}
+
+	if skip := (f.frameSize() - f.pitch.FrameSize()) / 2; skip > 0 {
+		if err := f.PitchInput.Seek(skip); err != nil {
+			log.Warn().Err(err).Msg("could not align pitch tracker")
+		}
+	}
+
+	return
+}
+
+func (f *streamingFFT) initFeatures() {
+	switch {
+	case f.fft != nil:
+		f.features = features.NewExtractor(f.Audio.SampleRate(), f.fft.FFTSize(), features.Config{})
+	case f.fft32 != nil:
+		f.features = features.NewExtractor(f.Audio.SampleRate(), f.fft32.FFTSize(), features.Config{})
+		f.featureSpectrum = util.Create2DFloats(f.fft32.NumberOutputFrequencies(), f.Audio.Channels())
+		f.featureSamples = util.Create2DFloats(f.fft32.FrameSize(), f.Audio.Channels())
+	}
+}
+
+// the descriptors of the last computed frame
+func (f *streamingFFT) frameFeatures() features.Frame {
+	switch {
+	case f.features == nil:
+		return features.Frame{}
+	case f.fft32 != nil:
+		for i, bin := range f.fftBuffer32 {
+			for c, v := range bin {
+				f.featureSpectrum[i][c] = float64(v)
+			}
+		}
+
+		for i, frame := range f.fft32.Samples() {
+			for c, v := range frame {
+				f.featureSamples[i][c] = float64(v)
+			}
+		}
+
+		return f.features.Compute(f.featureSpectrum, f.featureSamples)
+	default:
+		return f.features.Compute(f.fftBuffer, f.fft.Samples())
+	}
+}
+
+// samples in each frame
+func (f *streamingFFT) frameSize() int {
+	if f.cqt != nil {
+		return f.cqt.FrameSize()
+	}
+
+	return int(f.WindowSize / f.Audio.Timebase())
+}
+
+// the position of a pitch on the tracker's range from 0 (its lowest) to 1 (its highest) on a log scale, NaN when there
+// is no pitch
+func (f *streamingFFT) pitchPosition(e pitch.Estimate) float64 {
+	if !e.Voiced {
+		return math.NaN()
+	}
+
+	lowest, highest := f.pitch.Range()
+	return math.Log2(e.Frequency/lowest) / math.Log2(highest/lowest)
+}
+
+func (f *streamingFFT) numberOutputFrequencies() int {
+	if f.cqt != nil {
+		return f.cqt.NumberOutputFrequencies()
+	}
+
+	if f.fft32 != nil {
+		return f.fft32.NumberOutputFrequencies()
+	}
+
+	return f.fft.NumberOutputFrequencies()
+}
+
+func (f *streamingFFT) hasNext() bool {
+	if f.cqt != nil {
+		return f.cqt.HasNext()
+	}
+
+	if f.fft32 != nil {
+		return f.fft32.HasNext()
+	}
+
+	return f.fft.HasNext()
+}
+
+// releases the transform's plan (back to FFTOptions.Cache when set) and closes the audio, which leaves the song's
+// mmap to doFFT
+func (f *streamingFFT) close() error {
+	if f.pitch != nil {
+		f.pitch.Close()
+	}
+
+	if f.cqt != nil {
+		return f.cqt.Close()
+	}
+
+	if f.fft32 != nil {
+		return f.fft32.Close()
+	}
+
+	return f.fft.Close()
 }
 
 func (f *streamingFFT) StreamFFT(to chan<- FFTResult) {
@@ -78,6 +328,10 @@ func (f *streamingFFT) StreamFFT(to chan<- FFTResult) {
 	defer func() {
 		defer close(to)
 
+		if closeErr := f.close(); closeErr != nil {
+			log.Warn().Err(closeErr).Msg("could not close fft")
+		}
+
 		if err != nil {
 			to <- FFTResult{I: i, Err: err}
 		}
@@ -90,7 +344,7 @@ func (f *streamingFFT) StreamFFT(to chan<- FFTResult) {
 
 	buf := util.CreateNDFloat64(cap(to)+2, f.Audio.Channels(), f.Bins).([][][]float64)
 	nB := 0
-	for f.fft.HasNext() {
+	for f.hasNext() {
 		if err = f.computeFrameFFT(); err != nil {
 			return
 		}
@@ -102,14 +356,83 @@ func (f *streamingFFT) StreamFFT(to chan<- FFTResult) {
 		}
 
 		f.bin(result)
+		if f.chroma != nil {
+			for c, v := range f.chromaFrame {
+				f.chromaSum[c] += v
+			}
+		}
+
 		bin.CombineChannelsAvg(result, f.combinedBuffer)
+		isBeat := f.beats.Add(f.barFlux())
 		f.addValuesToMuSigma(i, f.combinedBuffer)
 		f.postBinProcessing(result)
-		to <- FFTResult{I: i, Data: result}
+		out := FFTResult{I: i, Data: result, Beat: isBeat, BeatPhase: f.beats.Phase(), Features: f.frameFeatures()}
+		if f.stereoSpectrum != nil {
+			out.Stereo, out.Panning = f.frameStereo()
+		}
+
+		if f.pitch != nil && f.pitch.HasNext() {
+			if out.Pitch, err = f.pitch.Next(); err != nil {
+				return
+			}
+		}
+
+		to <- out
 		i++
 	}
 
+	log.Info().Float64("bpm", f.beats.BPM()).Msg("final tempo")
+
 	log.Info().Float64("mu", f.rollingMu).Float64("sigma", f.rollingSigma).Msg("final sigma & µ")
+	if f.chroma != nil {
+		if key, _, keyErr := chroma.EstimateKey(f.chromaSum, chroma.KrumhanslKessler); keyErr != nil {
+			log.Warn().Err(keyErr).Msg("could not estimate key")
+		} else {
+			log.Info().Str("key", key.String()).Float64("correlation", key.Correlation).Msg("estimated key")
+		}
+	}
+}
+
+// how much the bars (in dB, channels combined) rose since the last frame
+func (f *streamingFFT) barFlux() (flux float64) {
+	for i, v := range f.combinedBuffer {
+		last := f.lastCombined[i]
+		f.lastCombined[i] = v
+		if math.IsNaN(v) || math.IsInf(v, 0) || math.IsNaN(last) || math.IsInf(last, 0) {
+			continue
+		}
+
+		if v > last {
+			flux += v - last
+		}
+	}
+
+	return
+}
+
+// the stereo image of the last computed frame, and each bar's panning as the mean of its bins' weighted by their energy
+func (f *streamingFFT) frameStereo() (image stereo.Frame, panning []float64) {
+	stereo.Measure(f.stereoSpectrum, &image)
+	for i, bin := range f.stereoSpectrum {
+		var energy float64
+		for _, v := range bin {
+			energy += real(v)*real(v) + imag(v)*imag(v)
+		}
+
+		f.stereoWeights[i][0] = image.Panning[i] * energy
+		f.stereoWeights[i][1] = energy
+	}
+
+	f.precomputedBin.Bin(f.stereoWeights, f.stereoBars)
+	panning = make([]float64, f.Bins)
+	for i := range panning {
+		// empty or silent bars are centered
+		if sum, energy := f.stereoBars[0][i], f.stereoBars[1][i]; energy > 0 {
+			panning[i] = sum / energy
+		}
+	}
+
+	return
 }
 
 func (f *streamingFFT) estimateDistribution() (err error) {
@@ -155,6 +478,28 @@ func (f *streamingFFT) estimateDistribution() (err error) {
 }
 
 func (f *streamingFFT) computeFrameFFT() error {
+	if f.cqt != nil {
+		return f.cqt.Compute(f.fftBuffer)
+	}
+
+	if f.stereoSpectrum != nil {
+		if err := f.fft.ComputeComplex(f.stereoSpectrum); err != nil {
+			return err
+		}
+
+		for i, bin := range f.stereoSpectrum {
+			for c, v := range bin {
+				f.fftBuffer[i][c] = math.Hypot(real(v), imag(v))
+			}
+		}
+
+		return nil
+	}
+
+	if f.fft32 != nil {
+		return f.fft32.Compute(f.fftBuffer32)
+	}
+
 	return f.fft.Compute(f.fftBuffer)
 }
 
@@ -167,16 +512,59 @@ func (f *streamingFFT) postBinProcessing(binnedCs [][]float64) {
 }
 
 func (f *streamingFFT) bin(to [][]float64) {
-	f.precomputedBin.Bin(f.fftBuffer, to)
+	f.binFrame(to)
 	bin.DBConversionCs(to)
 }
 
 func (f *streamingFFT) binCombined(to []float64) {
-	f.precomputedBin.Bin(f.fftBuffer, f.binBuffer)
+	f.binFrame(f.binBuffer)
 	bin.CombineChannelsAvg(f.binBuffer, to)
 	bin.DBConversion(to)
 }
 
+// bins the last computed frame, in single precision mode only the (small) binned result is widened to float64
+func (f *streamingFFT) binFrame(to [][]float64) {
+	// chroma mixes the channels, every channel shows the same bars
+	if f.chroma != nil {
+		f.chroma.Apply(f.fftBuffer, f.chromaFrame)
+		for c := range to {
+			copy(to[c], f.chromaFrame)
+		}
+
+		return
+	}
+
+	// constant-Q bins are already the bars, they only need transposing to [channel][bar]. Bars above the Nyquist
+	// frequency have no bin, and are NaN like empty bars are when binning
+	if f.cqt != nil {
+		for c := range to {
+			for i := len(f.fftBuffer); i < len(to[c]); i++ {
+				to[c][i] = math.NaN()
+			}
+		}
+
+		for i, bar := range f.fftBuffer {
+			for c, v := range bar {
+				to[c][i] = v
+			}
+		}
+
+		return
+	}
+
+	if f.fft32 == nil {
+		f.precomputedBin.Bin(f.fftBuffer, to)
+		return
+	}
+
+	f.precomputedBin.Bin32(f.fftBuffer32, f.binBuffer32)
+	for c, ch := range f.binBuffer32 {
+		for i, v := range ch {
+			to[c][i] = float64(v)
+		}
+	}
+}
+
 func (f *streamingFFT) exponentialSmoothing(in [][]float64) {
 	defer func() {
 		f.hasLastBinned = true
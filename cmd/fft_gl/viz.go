@@ -1,6 +1,7 @@
 package main
 
 import (
+	"math"
 	"time"
 
 	"github.com/Twister915/vis.go/pkg/bin"
@@ -15,6 +16,22 @@ const (
 	highWaterHeight = 2
 
 	min = 0.01
+
+	pulseHeight = 4
+	// frames of pitch shown across the window
+	pitchHistory = 150
+	// how long the beat pulse takes to fade out
+	pulseFade = time.Millisecond * 250
+
+	meterHeight = 4
+	// how far the stereo display moves towards each new frame
+	stereoSmoothing = 0.3
+)
+
+var (
+	// the colors of bars panned hard left and hard right, centered bars are w.colors[1]
+	leftColor  = col{255, 140, 60, 255}.toDec()
+	rightColor = col{80, 160, 255, 255}.toDec()
 )
 
 func (w *window) showViz(frame [][]float64) {
@@ -42,9 +59,40 @@ func (w *window) showViz(frame [][]float64) {
 	}
 }
 
+func (w *window) pitchViz(position float64) {
+	w.pitches = append(w.pitches, position)
+	if over := len(w.pitches) - pitchHistory; over > 0 {
+		w.pitches = append(w.pitches[:0], w.pitches[over:]...)
+	}
+}
+
+func (w *window) stereoViz(panning []float64, correlation, balance float64) {
+	if w.panning == nil {
+		w.panning = make([]float64, len(panning))
+		copy(w.panning, panning)
+		w.correlation, w.balance = correlation, balance
+		return
+	}
+
+	for i, v := range panning {
+		w.panning[i] += stereoSmoothing * (v - w.panning[i])
+	}
+
+	w.correlation += stereoSmoothing * (correlation - w.correlation)
+	w.balance += stereoSmoothing * (balance - w.balance)
+}
+
+func (w *window) pulseViz() {
+	w.pulse = 1
+}
+
 func (w *window) updateViz(delta time.Duration) {
 	const fallAcc = 0.015
 
+	if w.pulse -= float64(delta) / float64(pulseFade); w.pulse < 0 {
+		w.pulse = 0
+	}
+
 	fallDvDt := fallAcc * (float64(delta) / float64(time.Second))
 	for i, v := range w.highWater {
 		if v > w.lrMaxBuf[i] {
@@ -64,9 +112,135 @@ func (w *window) drawViz(x, y, wi, he float32) {
 	barHeight := he - paddingTop
 
 	w.drawHighChannelLines(barWidth, barHeight)
-	w.drawWhiteLines(barWidth, barHeight)
+	if w.panning != nil {
+		w.drawPannedLines(barWidth, barHeight)
+	} else {
+		w.drawWhiteLines(barWidth, barHeight)
+	}
+
 	w.drawHighWaters(barWidth, barHeight)
 	gl.PopMatrix()
+
+	w.drawPulse(x, y, wi)
+	w.drawCorrelation(x, y, wi)
+	w.drawPitch(x, y, wi, he)
+}
+
+// the recent pitch as a line scrolling right to left over the bars, broken where there was none
+func (w *window) drawPitch(x, y, wi, he float32) {
+	if len(w.pitches) == 0 {
+		return
+	}
+
+	c := col{80, 200, 255, 255}.toDec()
+	gl.Color4f(c[0], c[1], c[2], c[3])
+	gl.LineWidth(2)
+
+	step := (wi - paddingBeside*2) / pitchHistory
+	left := x + wi - paddingBeside - step*float32(len(w.pitches))
+	drawing := false
+	for i, v := range w.pitches {
+		if math.IsNaN(v) {
+			if drawing {
+				gl.End()
+				drawing = false
+			}
+
+			continue
+		}
+
+		if !drawing {
+			gl.Begin(gl.LINE_STRIP)
+			drawing = true
+		}
+
+		gl.Vertex2f(left+step*float32(i), y+he-float32(v)*(he-paddingTop))
+	}
+
+	if drawing {
+		gl.End()
+	}
+}
+
+// a strip along the top which flashes on every beat
+func (w *window) drawPulse(x, y, wi float32) {
+	if w.pulse <= 0 {
+		return
+	}
+
+	c := w.colors[0]
+	gl.Color4f(c[0], c[1], c[2], c[3]*float32(w.pulse))
+	gl.Begin(gl.QUADS)
+	gl.Vertex2f(x+paddingBeside, y)
+	gl.Vertex2f(x+wi-paddingBeside, y)
+	gl.Vertex2f(x+wi-paddingBeside, y+pulseHeight)
+	gl.Vertex2f(x+paddingBeside, y+pulseHeight)
+	gl.End()
+}
+
+// a meter under the beat strip, growing right from the middle when the channels are correlated and left when they are
+// out of phase, with a tick at the balance
+func (w *window) drawCorrelation(x, y, wi float32) {
+	if w.panning == nil {
+		return
+	}
+
+	center := x + wi/2
+	half := wi/2 - paddingBeside
+	top := y + pulseHeight + paddingBetween
+
+	c := col{90, 220, 120, 255}.toDec()
+	if w.correlation < 0 {
+		c = col{255, 70, 70, 255}.toDec()
+	}
+
+	end := center + half*float32(w.correlation)
+	gl.Color4f(c[0], c[1], c[2], c[3])
+	gl.Begin(gl.QUADS)
+	gl.Vertex2f(center, top)
+	gl.Vertex2f(end, top)
+	gl.Vertex2f(end, top+meterHeight)
+	gl.Vertex2f(center, top+meterHeight)
+	gl.End()
+
+	tick := center + half*float32(w.balance)
+	c = w.colors[1]
+	gl.Color4f(c[0], c[1], c[2], c[3])
+	gl.Begin(gl.QUADS)
+	gl.Vertex2f(tick-1, top-1)
+	gl.Vertex2f(tick+1, top-1)
+	gl.Vertex2f(tick+1, top+meterHeight+1)
+	gl.Vertex2f(tick-1, top+meterHeight+1)
+	gl.End()
+}
+
+// the bars in a color from leftColor through w.colors[1] to rightColor by where they are panned
+func (w *window) drawPannedLines(barWidth, barHeight float32) {
+	gl.PushMatrix()
+
+	for i, v := range w.state {
+		w.drawLine(barWidth, barHeight, panColor(w.colors[1], w.panning[i]), w.normLineHeight(v))
+	}
+
+	gl.PopMatrix()
+}
+
+// mixes center towards leftColor (panning -1) or rightColor (panning 1)
+func panColor(center col, panning float64) (out col) {
+	side := rightColor
+	if panning < 0 {
+		side, panning = leftColor, -panning
+	}
+
+	if panning > 1 {
+		panning = 1
+	}
+
+	for i := range out {
+		out[i] = center[i] + (side[i]-center[i])*float32(panning)
+	}
+
+	return
 }
 
 func (w *window) drawWhiteLines(barWidth, barHeight float32) {
@@ -20,6 +20,14 @@ type window struct {
 	highWater []float64
 	dydtHW    []float64
 	lrMaxBuf  []float64
+	// 1 on a beat, fading to 0
+	pulse float64
+	// the position of the recent pitches from 0 to 1, NaN without one
+	pitches []float64
+	// each bar's panning and the correlation & balance of the channels, smoothed, nil panning outside stereo mode
+	panning     []float64
+	correlation float64
+	balance     float64
 
 	colors cols
 	start  time.Time
@@ -97,6 +105,30 @@ func (w *window) Show(frame [][]float64) {
 	w.showViz(frame)
 }
 
+// adds a frame's pitch to the pitch line, see streamingFFT.pitchPosition
+func (w *window) ShowPitch(position float64) {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	w.pitchViz(position)
+}
+
+// pans the bars and moves the correlation meter, see FFTResult.Stereo and Panning
+func (w *window) ShowStereo(panning []float64, correlation, balance float64) {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	w.stereoViz(panning, correlation, balance)
+}
+
+// flashes the beat pulse
+func (w *window) Pulse() {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	w.pulseViz()
+}
+
 func (w *window) update(delta time.Duration) {
 	w.l.Lock()
 	defer w.l.Unlock()
@@ -0,0 +1,193 @@
+package audio
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+// exposes a legacy Input through the smaller interfaces, the result implements Reader, Seeker, Lengther and Closer
+func FromInput(in Input) ReadSeekCloser {
+	if r, ok := in.(*readerInput); ok {
+		if rsc, ok := r.r.(ReadSeekCloser); ok {
+			return rsc
+		}
+	}
+
+	return &inputReader{Input: in, format: FormatOf(in)}
+}
+
+type inputReader struct {
+	Input
+	format Format
+}
+
+func (i *inputReader) Format() Format {
+	return i.format
+}
+
+// builds a full Input on top of a Reader, capabilities the reader does not implement return ErrNotSupported
+//
+// the position is tracked by the adapter, so Has(n) is exact whenever the reader is also a Lengther. If the length is
+// not known Has always reports true and the end of the stream is signalled by io.EOF from the read methods
+func ToInput(r Reader) Input {
+	if ir, ok := r.(*inputReader); ok {
+		return ir.Input
+	}
+
+	if in, ok := r.(Input); ok {
+		return in
+	}
+
+	return &readerInput{r: r, format: r.Format(), mutex: new(sync.Mutex)}
+}
+
+type readerInput struct {
+	r      Reader
+	format Format
+
+	mutex *sync.Mutex
+	frame int
+
+	r32 Reader32
+}
+
+func (r *readerInput) BitDepth() int {
+	return r.format.BitDepth
+}
+
+func (r *readerInput) Channels() int {
+	return r.format.Channels
+}
+
+func (r *readerInput) Timebase() time.Duration {
+	return r.format.Timebase()
+}
+
+func (r *readerInput) SampleRate() int {
+	return r.format.SampleRate
+}
+
+// 0 when the reader does not know its length
+func (r *readerInput) Length() time.Duration {
+	frames := r.Frames()
+	if frames < 0 {
+		return 0
+	}
+
+	return r.format.Duration(frames)
+}
+
+// -1 when the reader does not know its length
+func (r *readerInput) Frames() int {
+	if l, ok := r.r.(Lengther); ok {
+		return l.Frames()
+	}
+
+	return -1
+}
+
+func (r *readerInput) Seek(n int) (err error) {
+	s, ok := r.r.(Seeker)
+	if !ok {
+		err = ErrNotSupported
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err = s.Seek(n); err != nil {
+		return
+	}
+
+	r.frame += n
+	return
+}
+
+func (r *readerInput) Reset() (err error) {
+	s, ok := r.r.(Seeker)
+	if !ok {
+		err = ErrNotSupported
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err = s.Reset(); err != nil {
+		return
+	}
+
+	r.frame = 0
+	return
+}
+
+func (r *readerInput) ReadSamples(to [][]float64) (int, error) {
+	return r.ReadSamplesDir(to, ReadSampleByChannel)
+}
+
+func (r *readerInput) ReadSamplesDir(to [][]float64, dir SampleReadDirection) (n int, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	n, err = r.r.ReadSamplesDir(to, dir)
+	r.frame += n
+	return
+}
+
+func (r *readerInput) ReadSamplesDir32(to [][]float32, dir SampleReadDirection) (n int, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.r32 == nil {
+		r.r32 = To32(r.r)
+	}
+
+	n, err = r.r32.ReadSamplesDir32(to, dir)
+	r.frame += n
+	return
+}
+
+func (r *readerInput) ReadNSamples(n int) (out [][]float64, err error) {
+	out = util.Create2DFloats(n, r.format.Channels)
+	read, err := r.ReadSamples(out)
+	if err != nil {
+		return
+	}
+
+	if read < n {
+		err = io.EOF
+	}
+
+	return
+}
+
+func (r *readerInput) ReadSample() (out []float64, err error) {
+	samples, err := r.ReadNSamples(1)
+	if err != nil {
+		return
+	}
+
+	out = samples[0]
+	return
+}
+
+func (r *readerInput) Has(n int) bool {
+	frames := r.Frames()
+	if frames < 0 {
+		return true
+	}
+
+	return frames-r.frame >= n
+}
+
+func (r *readerInput) Close() (err error) {
+	if c, ok := r.r.(Closer); ok {
+		err = c.Close()
+	}
+
+	return
+}
@@ -0,0 +1,254 @@
+package audio_test
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/audio"
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+var testFormat = audio.Format{BitDepth: 16, Channels: 2, SampleRate: 1000}
+
+// frames frames of stereo, the left channel counting up from 0 and the right the same negated
+func ramp(frames int) [][]float64 {
+	samples := util.Create2DFloats(frames, 2)
+	for i := range samples {
+		samples[i][0], samples[i][1] = float64(i), -float64(i)
+	}
+
+	return samples
+}
+
+func newInput(frames int) audio.Input {
+	return audio.ToInput(audio.NewMemoryReader(testFormat, ramp(frames)))
+}
+
+func TestSeekBounds(t *testing.T) {
+	in := newInput(10)
+
+	if err := in.Seek(-1); err == nil {
+		t.Fatal("seeked before the start")
+	}
+
+	if err := in.Seek(11); err == nil {
+		t.Fatal("seeked past the end")
+	}
+
+	// failed seeks leave the position alone
+	if !in.Has(10) || in.Has(11) {
+		t.Fatal("position moved by a failed seek")
+	}
+
+	// the end itself is a valid position, with nothing left to read
+	if err := in.Seek(10); err != nil {
+		t.Fatal(err)
+	}
+
+	if !in.Has(0) || in.Has(1) {
+		t.Fatalf("at the end Has(0) is %v and Has(1) is %v", in.Has(0), in.Has(1))
+	}
+
+	if _, err := in.ReadSamples(util.Create2DFloats(1, 2)); err != io.EOF {
+		t.Fatalf("read at the end: %v", err)
+	}
+
+	if err := in.Seek(-4); err != nil {
+		t.Fatal(err)
+	}
+
+	sample, err := in.ReadSample()
+	if err != nil || sample[0] != 6 {
+		t.Fatalf("read %v, %v after seeking back", sample, err)
+	}
+
+	if err = in.Reset(); err != nil || !in.Has(10) {
+		t.Fatalf("reset: %v", err)
+	}
+}
+
+func TestLength(t *testing.T) {
+	in := newInput(250)
+	if in.Frames() != 250 || in.Length() != 250*time.Millisecond {
+		t.Fatalf("%d frames, %v", in.Frames(), in.Length())
+	}
+
+	if in.SampleRate() != 1000 || in.Channels() != 2 || in.BitDepth() != 16 || in.Timebase() != time.Millisecond {
+		t.Fatalf("format %d Hz, %d channels, %d bits, %v", in.SampleRate(), in.Channels(), in.BitDepth(), in.Timebase())
+	}
+
+	// a short read stops at the end, and the next one is io.EOF
+	if err := in.Seek(248); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := in.ReadSamples(util.Create2DFloats(4, 2))
+	if err != nil || n != 2 || in.Has(1) {
+		t.Fatalf("read %d, %v", n, err)
+	}
+
+	if _, err = in.ReadNSamples(1); err != io.EOF {
+		t.Fatalf("read past the end: %v", err)
+	}
+
+	if err = in.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = in.ReadNSamples(251); err != io.EOF {
+		t.Fatalf("read more than the input: %v", err)
+	}
+}
+
+func TestUnknownLength(t *testing.T) {
+	// only a Reader, so the adapter cannot know the length
+	in := audio.ToInput(struct{ audio.Reader }{audio.NewMemoryReader(testFormat, ramp(10))})
+	if in.Frames() != -1 || in.Length() != 0 {
+		t.Fatalf("%d frames, %v", in.Frames(), in.Length())
+	}
+
+	if !in.Has(100) {
+		t.Fatal("expected Has to be true without a length")
+	}
+}
+
+func TestReadDirections(t *testing.T) {
+	in := newInput(8)
+	if err := in.Seek(2); err != nil {
+		t.Fatal(err)
+	}
+
+	// indexed [channel][sample]
+	byChannel := util.Create2DFloats(2, 3)
+	n, err := in.ReadSamplesDir(byChannel, audio.ReadChannelBySample)
+	if err != nil || n != 3 {
+		t.Fatalf("read %d, %v", n, err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if byChannel[0][i] != float64(2+i) || byChannel[1][i] != -float64(2+i) {
+			t.Fatalf("read %v", byChannel)
+		}
+	}
+
+	// indexed [sample][channel], carrying on from where the last read stopped
+	bySample := util.Create2DFloats(3, 2)
+	if n, err = in.ReadSamplesDir(bySample, audio.ReadSampleByChannel); err != nil || n != 3 {
+		t.Fatalf("read %d, %v", n, err)
+	}
+
+	for i := range bySample {
+		if bySample[i][0] != float64(5+i) || bySample[i][1] != -float64(5+i) {
+			t.Fatalf("read %v", bySample)
+		}
+	}
+}
+
+func TestRead32(t *testing.T) {
+	samples := ramp(6)
+	samples[1][0] = 0.1
+
+	for _, dir := range []audio.SampleReadDirection{audio.ReadSampleByChannel, audio.ReadChannelBySample} {
+		for name, r := range map[string]audio.Reader32{
+			// how the single precision analyzers read an Input
+			"input":  audio.To32(audio.FromInput(audio.ToInput(audio.NewMemoryReader(testFormat, samples)))),
+			"reader": audio.To32(audio.NewMemoryReader(testFormat, samples)),
+		} {
+			to := util.CreateNDTs(float32(0), 6, 2).([][]float32)
+			if dir == audio.ReadChannelBySample {
+				to = util.CreateNDTs(float32(0), 2, 6).([][]float32)
+			}
+
+			n, err := r.ReadSamplesDir32(to, dir)
+			if err != nil || n != 6 {
+				t.Fatalf("%s: read %d, %v", name, n, err)
+			}
+
+			for i := range samples {
+				for ch, v := range samples[i] {
+					var got float32
+					if dir == audio.ReadChannelBySample {
+						got = to[ch][i]
+					} else {
+						got = to[i][ch]
+					}
+
+					if got != float32(v) {
+						t.Fatalf("%s (%v): sample %d channel %d is %v, expected %v", name, dir, i, ch, got, float32(v))
+					}
+				}
+			}
+		}
+	}
+}
+
+// the Input's position follows single precision reads too
+func TestRead32Position(t *testing.T) {
+	in := newInput(4)
+	if _, err := audio.To32(audio.FromInput(in)).ReadSamplesDir32(util.CreateNDTs(float32(0), 3, 2).([][]float32), audio.ReadSampleByChannel); err != nil {
+		t.Fatal(err)
+	}
+
+	if !in.Has(1) || in.Has(2) {
+		t.Fatal("position not advanced by ReadSamplesDir32")
+	}
+}
+
+// a reader which can only read, and does not know its length
+type streamReader struct {
+	left int
+}
+
+func (s *streamReader) Format() audio.Format {
+	return testFormat
+}
+
+func (s *streamReader) ReadSamplesDir(to [][]float64, dir audio.SampleReadDirection) (n int, err error) {
+	if s.left == 0 {
+		err = io.EOF
+		return
+	}
+
+	for n < len(to) && s.left > 0 {
+		n++
+		s.left--
+	}
+
+	return
+}
+
+func TestUnsupported(t *testing.T) {
+	in := audio.ToInput(&streamReader{left: 3})
+	if in.Frames() != -1 || !in.Has(1000) {
+		t.Fatalf("%d frames, has 1000 %v", in.Frames(), in.Has(1000))
+	}
+
+	if err := in.Seek(1); err != audio.ErrNotSupported {
+		t.Fatalf("seek: %v", err)
+	}
+
+	if err := in.Reset(); err != audio.ErrNotSupported {
+		t.Fatalf("reset: %v", err)
+	}
+
+	if err := in.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAdapterRoundTrip(t *testing.T) {
+	in := newInput(5)
+	r := audio.FromInput(in)
+	if r.Format() != testFormat {
+		t.Fatalf("format %+v", r.Format())
+	}
+
+	if audio.ToInput(r) != in {
+		t.Fatal("ToInput(FromInput(in)) is not in")
+	}
+
+	if err := r.Seek(5); err != nil || in.Has(1) {
+		t.Fatalf("seek through the reader: %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package audio
+
+import "time"
+
+// describes the shape of the sample data an input produces
+type Format struct {
+	BitDepth   int
+	Channels   int
+	SampleRate int
+}
+
+// time between two samples
+func (f Format) Timebase() time.Duration {
+	return time.Second / time.Duration(f.SampleRate)
+}
+
+// the duration of n frames of audio in this format
+func (f Format) Duration(frames int) time.Duration {
+	return time.Duration(frames) * f.Timebase()
+}
+
+// the number of whole frames which fit in d
+func (f Format) Frames(d time.Duration) int {
+	return int(d / f.Timebase())
+}
+
+// builds a format from the getters on a legacy Input
+func FormatOf(in Input) Format {
+	return Format{
+		BitDepth:   in.BitDepth(),
+		Channels:   in.Channels(),
+		SampleRate: in.SampleRate(),
+	}
+}
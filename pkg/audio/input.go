@@ -9,6 +9,8 @@ const (
 	ReadChannelBySample
 )
 
+// the full set of operations the fft & playback code uses, new decoders and wrappers should implement Reader (and
+// whichever of Seeker, Lengther & Closer they support) and use ToInput to get one of these
 type Input interface {
 	BitDepth() int
 
@@ -0,0 +1,69 @@
+package audio
+
+import (
+	"errors"
+	"io"
+)
+
+var errSeekRange = errors.New("seek outside of the samples")
+
+// a Reader over samples already in memory (indexed [frame][channel], the layout ReadSamples produces), for example
+// audio resynthesized by fft.ISTFT. Use ToInput to play it
+func NewMemoryReader(format Format, samples [][]float64) *MemoryReader {
+	return &MemoryReader{format: format, samples: samples}
+}
+
+type MemoryReader struct {
+	format  Format
+	samples [][]float64
+	at      int
+}
+
+func (m *MemoryReader) Format() Format {
+	return m.format
+}
+
+func (m *MemoryReader) Frames() int {
+	return len(m.samples)
+}
+
+func (m *MemoryReader) Seek(n int) error {
+	if m.at+n < 0 || m.at+n > len(m.samples) {
+		return errSeekRange
+	}
+
+	m.at += n
+	return nil
+}
+
+func (m *MemoryReader) Reset() error {
+	m.at = 0
+	return nil
+}
+
+func (m *MemoryReader) ReadSamplesDir(to [][]float64, dir SampleReadDirection) (n int, err error) {
+	frames := len(to)
+	if dir == ReadChannelBySample {
+		frames = len(to[0])
+	}
+
+	if m.at >= len(m.samples) {
+		err = io.EOF
+		return
+	}
+
+	for ; n < frames && m.at < len(m.samples); n++ {
+		for ch, v := range m.samples[m.at] {
+			switch dir {
+			case ReadSampleByChannel:
+				to[n][ch] = v
+			case ReadChannelBySample:
+				to[ch][n] = v
+			}
+		}
+
+		m.at++
+	}
+
+	return
+}
@@ -0,0 +1,49 @@
+package audio
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrNotSupported = errors.New("operation not supported by this input")
+
+// the only thing a decoder must implement: a format and a way to read samples into a pre-allocated buffer
+type Reader interface {
+	Format() Format
+
+	ReadSamplesDir([][]float64, SampleReadDirection) (int, error)
+}
+
+// implemented by inputs which can move their read position (in frames)
+type Seeker interface {
+	// relative to the current position
+	Seek(int) error
+
+	// go back to the first frame
+	Reset() error
+}
+
+// implemented by inputs which know how many frames they contain
+type Lengther interface {
+	Frames() int
+}
+
+type Closer interface {
+	io.Closer
+}
+
+type ReadSeeker interface {
+	Reader
+	Seeker
+}
+
+type ReadCloser interface {
+	Reader
+	Closer
+}
+
+type ReadSeekCloser interface {
+	Reader
+	Seeker
+	Closer
+}
@@ -0,0 +1,56 @@
+package audio
+
+import "github.com/Twister915/vis.go/pkg/util"
+
+// single precision counterpart of Reader, for pipelines which never need float64 samples
+type Reader32 interface {
+	Format() Format
+
+	ReadSamplesDir32([][]float32, SampleReadDirection) (int, error)
+}
+
+// returns r itself when it can read float32 samples natively, otherwise wraps it and converts from float64
+func To32(r Reader) Reader32 {
+	if ir, ok := r.(*inputReader); ok {
+		if r32, ok := ir.Input.(Reader32); ok {
+			return r32
+		}
+	}
+
+	if r32, ok := r.(Reader32); ok {
+		return r32
+	}
+
+	return &reader32{Reader: r}
+}
+
+type reader32 struct {
+	Reader
+
+	buf [][]float64
+}
+
+func (r *reader32) ReadSamplesDir32(to [][]float32, dir SampleReadDirection) (n int, err error) {
+	if len(r.buf) != len(to) || len(r.buf[0]) != len(to[0]) {
+		r.buf = util.Create2DFloats(len(to), len(to[0]))
+	}
+
+	n, err = r.Reader.ReadSamplesDir(r.buf, dir)
+
+	switch dir {
+	case ReadSampleByChannel:
+		for i := 0; i < n; i++ {
+			for c, v := range r.buf[i] {
+				to[i][c] = float32(v)
+			}
+		}
+	case ReadChannelBySample:
+		for c, ch := range r.buf {
+			for i := 0; i < n; i++ {
+				to[c][i] = float32(ch[i])
+			}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,319 @@
+package beat
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/fft"
+	"github.com/Twister915/vis.go/pkg/onset"
+)
+
+// tempo estimation and beat tracking from an onset strength envelope (see pkg/onset). The tempo is the period which best
+// matches the envelope's autocorrelation, weighted towards a typical tempo so the result is not an octave off, and the
+// beats are found by dynamic programming (Ellis, 2007) like librosa.beat.beat_track
+
+type Config struct {
+	// the range of tempos considered, 0 means 30 and 300
+	MinBPM, MaxBPM float64
+
+	// tempos are weighted by a log-normal prior centered here, one octave wide. 0 means 120
+	StartBPM float64
+
+	// how strongly beats keep to the tempo, higher is stricter. 0 means 100, librosa's default
+	Tightness float64
+}
+
+const (
+	defaultMinBPM    = 30.0
+	defaultMaxBPM    = 300.0
+	defaultStartBPM  = 120.0
+	defaultTightness = 100.0
+)
+
+func (c Config) withDefaults() Config {
+	if c.MinBPM <= 0 {
+		c.MinBPM = defaultMinBPM
+	}
+
+	if c.MaxBPM <= 0 {
+		c.MaxBPM = defaultMaxBPM
+	}
+
+	if c.StartBPM <= 0 {
+		c.StartBPM = defaultStartBPM
+	}
+
+	if c.Tightness <= 0 {
+		c.Tightness = defaultTightness
+	}
+
+	return c
+}
+
+// an onset strength envelope, one value per frame of frameSize samples starting every hop samples
+type Envelope struct {
+	Values []float64
+
+	SampleRate, FrameSize, Hop int
+}
+
+// computes the envelope of every remaining frame, with the given detection function
+func NewEnvelope(frames *fft.FFTByFrame, function onset.Function) (out Envelope, err error) {
+	out = Envelope{SampleRate: frames.SampleRate(), FrameSize: frames.FrameSize(), Hop: frames.Hop()}
+
+	cfg := onset.DefaultConfig()
+	cfg.Function = function
+	analyzer := onset.NewAnalyzer(frames, cfg)
+	for analyzer.HasNext() {
+		if _, _, err = analyzer.Compute(); err != nil {
+			return
+		}
+
+		out.Values = append(out.Values, analyzer.Detector().Envelope())
+	}
+
+	return
+}
+
+// frames per second
+func (e Envelope) FrameRate() float64 {
+	return float64(e.SampleRate) / float64(e.Hop)
+}
+
+// the center of a frame
+func (e Envelope) Time(frame int) time.Duration {
+	center := float64(frame*e.Hop+e.FrameSize/2) / float64(e.SampleRate)
+	return time.Duration(center * float64(time.Second))
+}
+
+// the tempo in beats per minute, 0 when the envelope is too short or flat to tell
+func EstimateTempo(e Envelope, cfg Config) float64 {
+	period := estimatePeriod(e.Values, e.FrameRate(), cfg.withDefaults())
+	if period <= 0 {
+		return 0
+	}
+
+	return 60 * e.FrameRate() / period
+}
+
+// the beat period in frames (fractional), from the autocorrelation of values weighted by the tempo prior
+func estimatePeriod(values []float64, frameRate float64, cfg Config) float64 {
+	minLag := int(math.Floor(60 * frameRate / cfg.MaxBPM))
+	maxLag := int(math.Ceil(60 * frameRate / cfg.MinBPM))
+	if minLag < 1 {
+		minLag = 1
+	}
+
+	if maxLag > len(values)-1 {
+		maxLag = len(values) - 1
+	}
+
+	if maxLag-minLag < 2 {
+		return 0
+	}
+
+	var mean float64
+	for _, v := range values {
+		mean += v
+	}
+
+	mean /= float64(len(values))
+
+	// unbiased autocorrelation of the envelope without its mean, weighted by the prior. One lag either side of the range
+	// is kept for interpolating
+	scores := make([]float64, maxLag+2)
+	best := -1
+	for lag := minLag - 1; lag <= maxLag+1 && lag < len(values); lag++ {
+		if lag < 1 {
+			continue
+		}
+
+		var sum float64
+		for i := lag; i < len(values); i++ {
+			sum += (values[i] - mean) * (values[i-lag] - mean)
+		}
+
+		bpm := 60 * frameRate / float64(lag)
+		octaves := math.Log2(bpm / cfg.StartBPM)
+		scores[lag] = sum / float64(len(values)-lag) * math.Exp(-0.5*octaves*octaves)
+		if lag >= minLag && lag <= maxLag && (best < 0 || scores[lag] > scores[best]) {
+			best = lag
+		}
+	}
+
+	if scores[best] <= 0 {
+		return 0
+	}
+
+	// the vertex of the parabola through the best lag and its neighbours
+	period := float64(best)
+	if best > 1 && best+1 < len(scores) {
+		a, b, c := scores[best-1], scores[best], scores[best+1]
+		if curvature := a - 2*b + c; curvature < 0 {
+			period += 0.5 * (a - c) / curvature
+		}
+	}
+
+	return period
+}
+
+// the whole-file result of Track
+type Result struct {
+	BPM float64
+
+	// the frame of each beat, and its time
+	Frames []int
+	Beats  []time.Duration
+}
+
+// estimates the tempo, then finds the beats. Beats are on strong onsets, about one period apart
+func Track(e Envelope, cfg Config) (out Result) {
+	cfg = cfg.withDefaults()
+	period := estimatePeriod(e.Values, e.FrameRate(), cfg)
+	if period <= 0 {
+		return
+	}
+
+	out.BPM = 60 * e.FrameRate() / period
+	out.Frames = trackBeats(e.Values, period, cfg.Tightness)
+	for _, frame := range out.Frames {
+		out.Beats = append(out.Beats, e.Time(frame))
+	}
+
+	return
+}
+
+// dynamic programming beat tracking, the best sequence of beats trades onset strength against keeping to period
+func trackBeats(values []float64, period, tightness float64) (beats []int) {
+	local := localScore(values, period)
+
+	// the best score of a sequence of beats ending on each frame, and the beat before it
+	cumulative := make([]float64, len(local))
+	backlink := make([]int, len(local))
+	for i := range local {
+		backlink[i] = -1
+		cumulative[i] = local[i]
+
+		// the previous beat is between half and twice a period earlier
+		first, last := i-int(math.Round(2*period)), i-int(math.Round(period/2))
+		if first < 0 {
+			first = 0
+		}
+
+		bestScore := math.Inf(-1)
+		for prev := first; prev <= last; prev++ {
+			deviation := math.Log(float64(i-prev) / period)
+			if score := cumulative[prev] - tightness*deviation*deviation; score > bestScore {
+				bestScore = score
+				backlink[i] = prev
+			}
+		}
+
+		if backlink[i] >= 0 {
+			cumulative[i] += bestScore
+		}
+	}
+
+	last := lastBeat(cumulative)
+	if last < 0 {
+		return
+	}
+
+	for i := last; i >= 0; i = backlink[i] {
+		beats = append(beats, i)
+	}
+
+	// backtracking found them last to first
+	for i, j := 0, len(beats)-1; i < j; i, j = i+1, j-1 {
+		beats[i], beats[j] = beats[j], beats[i]
+	}
+
+	return trimBeats(beats, local)
+}
+
+// the envelope divided by its standard deviation and smoothed with a Gaussian a 32nd of a period wide, so onsets close
+// to a beat still count towards it
+func localScore(values []float64, period float64) (out []float64) {
+	var mean, variance float64
+	for _, v := range values {
+		mean += v
+	}
+
+	mean /= float64(len(values))
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+
+	std := math.Sqrt(variance / float64(len(values)))
+	if std == 0 {
+		std = 1
+	}
+
+	half := int(math.Round(period))
+	kernel := make([]float64, 2*half+1)
+	for i := range kernel {
+		t := float64(i-half) * 32 / period
+		kernel[i] = math.Exp(-0.5 * t * t)
+	}
+
+	out = make([]float64, len(values))
+	for i := range out {
+		for j, w := range kernel {
+			if k := i + j - half; k >= 0 && k < len(values) {
+				out[i] += w * values[k] / std
+			}
+		}
+	}
+
+	return
+}
+
+// the last local maximum of the cumulative score which is at least half the median of them, later frames have not had
+// the chance to build up a score
+func lastBeat(cumulative []float64) int {
+	var maxima []int
+	for i := 1; i < len(cumulative)-1; i++ {
+		if cumulative[i] > cumulative[i-1] && cumulative[i] >= cumulative[i+1] {
+			maxima = append(maxima, i)
+		}
+	}
+
+	if len(maxima) == 0 {
+		return -1
+	}
+
+	scores := make([]float64, len(maxima))
+	for i, m := range maxima {
+		scores[i] = cumulative[m]
+	}
+
+	sort.Float64s(scores)
+	threshold := 0.5 * scores[len(scores)/2]
+	for i := len(maxima) - 1; i >= 0; i-- {
+		if cumulative[maxima[i]] >= threshold {
+			return maxima[i]
+		}
+	}
+
+	return -1
+}
+
+// drops beats at either end with less than half the RMS of the beats' local scores, where the music starts and stops
+func trimBeats(beats []int, local []float64) []int {
+	var sum float64
+	for _, b := range beats {
+		sum += local[b] * local[b]
+	}
+
+	threshold := 0.5 * math.Sqrt(sum/float64(len(beats)))
+	for len(beats) > 0 && local[beats[0]] < threshold {
+		beats = beats[1:]
+	}
+
+	for len(beats) > 0 && local[beats[len(beats)-1]] < threshold {
+		beats = beats[:len(beats)-1]
+	}
+
+	return beats
+}
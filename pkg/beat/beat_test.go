@@ -0,0 +1,132 @@
+package beat_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/audio"
+	"github.com/Twister915/vis.go/pkg/beat"
+	"github.com/Twister915/vis.go/pkg/fft"
+	"github.com/Twister915/vis.go/pkg/onset"
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+const testSampleRate = 22050
+
+// a drum loop, a kick (a low sine with a click at the start) on every beat starting at first and a quieter hi-hat
+// between them
+func drums(seconds, bpm float64, first time.Duration) audio.Input {
+	random := rand.New(rand.NewSource(1))
+	samples := util.Create2DFloats(int(seconds*testSampleRate), 1)
+	period := 60 / bpm
+	for i := range samples {
+		t := float64(i) / testSampleRate
+		samples[i][0] = 0.001 * random.NormFloat64()
+		if t < first.Seconds() {
+			continue
+		}
+
+		sinceBeat := math.Mod(t-first.Seconds(), period)
+		samples[i][0] += 0.5*math.Exp(-sinceBeat*20)*math.Sin(2*math.Pi*60*sinceBeat) + 0.3*math.Exp(-sinceBeat*80)*random.NormFloat64()
+		if sinceHat := sinceBeat - period/2; sinceHat >= 0 {
+			samples[i][0] += 0.1 * math.Exp(-sinceHat*60) * random.NormFloat64()
+		}
+	}
+
+	return audio.ToInput(audio.NewMemoryReader(audio.Format{SampleRate: testSampleRate, Channels: 1, BitDepth: 16}, samples))
+}
+
+func envelope(t *testing.T, input audio.Input) beat.Envelope {
+	frames := fft.NewFFTByFrameOptions(input, 1024*input.Timebase(), 256*input.Timebase(), fft.HannWindow, fft.Options{})
+	defer frames.Close()
+
+	e, err := beat.NewEnvelope(frames, onset.SpectralFlux)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return e
+}
+
+func TestTrack(t *testing.T) {
+	for _, bpm := range []float64{90, 120, 128, 150} {
+		const first = 500 * time.Millisecond
+		result := beat.Track(envelope(t, drums(12, bpm, first)), beat.Config{})
+		if math.Abs(result.BPM-bpm) > bpm*0.02 {
+			t.Fatalf("%v BPM estimated as %v", bpm, result.BPM)
+		}
+
+		if len(result.Beats) < int(11*bpm/60)-2 {
+			t.Fatalf("%v BPM: only %d beats", bpm, len(result.Beats))
+		}
+
+		period := time.Duration(float64(time.Minute) / bpm)
+		for i, b := range result.Beats {
+			// the nearest kick
+			n := math.Round(float64(b-first) / float64(period))
+			if d := b - first - time.Duration(n*float64(period)); d < -30*time.Millisecond || d > 30*time.Millisecond {
+				t.Fatalf("%v BPM: beat %d at %v is %v off", bpm, i, b, d)
+			}
+
+			if len(result.Frames) != len(result.Beats) {
+				t.Fatal("frames & beats differ")
+			}
+		}
+	}
+}
+
+func TestEstimateTempoPrior(t *testing.T) {
+	e := envelope(t, drums(10, 120, 0))
+	if bpm := beat.EstimateTempo(e, beat.Config{}); math.Abs(bpm-120) > 2 {
+		t.Fatalf("estimated %v", bpm)
+	}
+
+	// the half tempo lines up just as well, a prior around it picks it
+	if bpm := beat.EstimateTempo(e, beat.Config{StartBPM: 60, MaxBPM: 100}); math.Abs(bpm-60) > 1 {
+		t.Fatalf("estimated %v with a slow prior", bpm)
+	}
+
+	if bpm := beat.EstimateTempo(beat.Envelope{Values: make([]float64, 10), SampleRate: testSampleRate, Hop: 256}, beat.Config{}); bpm != 0 {
+		t.Fatalf("estimated %v from nothing", bpm)
+	}
+}
+
+func TestPredictor(t *testing.T) {
+	const bpm, first = 128.0, 250 * time.Millisecond
+	e := envelope(t, drums(15, bpm, first))
+	p := beat.NewPredictor(e.FrameRate(), beat.Config{})
+	period := time.Duration(float64(time.Minute) / bpm)
+
+	var beats []time.Duration
+	for i, v := range e.Values {
+		if p.Add(v) {
+			beats = append(beats, e.Time(i))
+		}
+
+		if phase := p.Phase(); phase < 0 || phase > 1 {
+			t.Fatalf("phase %v", phase)
+		}
+	}
+
+	if math.Abs(p.BPM()-bpm) > bpm*0.02 {
+		t.Fatalf("estimated %v BPM", p.BPM())
+	}
+
+	// warming up takes 4 seconds, after that every beat is predicted
+	if expected := int(math.Floor((15-5)*bpm/60)) - 1; len(beats) < expected {
+		t.Fatalf("%d beats, expected at least %d", len(beats), expected)
+	}
+
+	for i, b := range beats {
+		n := math.Round(float64(b-first) / float64(period))
+		if d := b - first - time.Duration(n*float64(period)); d < -35*time.Millisecond || d > 35*time.Millisecond {
+			t.Fatalf("beat %d at %v is %v off", i, b, d)
+		}
+
+		if i > 0 && beats[i]-beats[i-1] < period/2 {
+			t.Fatalf("beats %d and %d are %v apart", i-1, i, beats[i]-beats[i-1])
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package beat
+
+import "math"
+
+// predicts beats live from an onset strength envelope, one value at a time. Every second the tempo is estimated from the
+// last few seconds, and the beat phase from where the envelope's onsets line up best with it. Beats are then reported
+// on the frame they are predicted for, without waiting for the onset
+type Predictor struct {
+	frameRate float64
+	cfg       Config
+
+	// the last historyLen values, history[len(history) - 1] is frame - 1
+	history    []float64
+	historyLen int
+	frame      int
+
+	// in frames, 0 until there is enough history
+	period        float64
+	nextBeat      float64
+	lastFired     int
+	sinceEstimate int
+}
+
+const (
+	// how much of the envelope the tempo is estimated from
+	predictorHistory = 8.0
+	// the least history before the first estimate
+	predictorWarmup = 4.0
+	// seconds between estimates
+	predictorInterval = 1.0
+)
+
+// frameRate is envelope values per second
+func NewPredictor(frameRate float64, cfg Config) *Predictor {
+	return &Predictor{
+		frameRate:  frameRate,
+		cfg:        cfg.withDefaults(),
+		historyLen: int(predictorHistory * frameRate),
+		lastFired:  -1,
+	}
+}
+
+// adds the next frame's envelope value, beat is set when a beat is predicted on this frame
+func (p *Predictor) Add(value float64) (beat bool) {
+	p.history = append(p.history, value)
+	if over := len(p.history) - p.historyLen; over > p.historyLen {
+		p.history = append(p.history[:0], p.history[over:]...)
+	}
+
+	frame := p.frame
+	p.frame++
+	p.sinceEstimate++
+	if len(p.history) >= int(predictorWarmup*p.frameRate) && float64(p.sinceEstimate) >= predictorInterval*p.frameRate {
+		p.estimate()
+	}
+
+	if p.period <= 0 || float64(frame) < p.nextBeat-0.5 {
+		return
+	}
+
+	for p.nextBeat-0.5 <= float64(frame) {
+		p.nextBeat += p.period
+	}
+
+	// a new estimate can move the next beat closer to the last one, that is a phase correction and not another beat
+	if p.lastFired >= 0 && float64(frame-p.lastFired) < p.period/2 {
+		return
+	}
+
+	p.lastFired = frame
+	beat = true
+	return
+}
+
+// re-estimates the period and phase from the history
+func (p *Predictor) estimate() {
+	p.sinceEstimate = 0
+	history := p.history
+	if len(history) > p.historyLen {
+		history = history[len(history)-p.historyLen:]
+	}
+
+	period := estimatePeriod(history, p.frameRate, p.cfg)
+	if period <= 0 {
+		return
+	}
+
+	// the offset back from the last frame which lines up with the most onsets every period before it
+	var mean float64
+	for _, v := range history {
+		mean += v
+	}
+
+	mean /= float64(len(history))
+
+	bestOffset, bestScore := 0, math.Inf(-1)
+	for offset := 0; offset < int(math.Ceil(period)); offset++ {
+		var score float64
+		for m := 0; ; m++ {
+			i := len(history) - 1 - offset - int(math.Round(float64(m)*period))
+			if i < 0 {
+				break
+			}
+
+			score += history[i] - mean
+		}
+
+		if score > bestScore {
+			bestOffset, bestScore = offset, score
+		}
+	}
+
+	p.period = period
+	last := p.frame - 1
+	p.nextBeat = float64(last - bestOffset)
+	for p.nextBeat-0.5 < float64(last) {
+		p.nextBeat += period
+	}
+}
+
+// the estimated tempo, 0 until there is enough history
+func (p *Predictor) BPM() float64 {
+	if p.period <= 0 {
+		return 0
+	}
+
+	return 60 * p.frameRate / p.period
+}
+
+// how far through the current beat the last frame was, 0 on the beat and approaching 1 just before the next. For
+// pulsing visuals, 1 - Phase fades out over each beat. Always 0 until there is enough history
+func (p *Predictor) Phase() float64 {
+	if p.period <= 0 {
+		return 0
+	}
+
+	phase := 1 - (p.nextBeat-float64(p.frame-1))/p.period
+	return math.Max(0, math.Min(phase, 1))
+}
@@ -0,0 +1,88 @@
+package bin
+
+import "math"
+
+// float32 version of CachedBinsSpec.Bin
+func (c *CachedBinsSpec) Bin32(data [][]float32, to [][]float32) {
+	nan := float32(math.NaN())
+	for i := range to {
+		for n := range to[i] {
+			to[i][n] = nan
+		}
+	}
+
+	binI := 0
+	for n, fftBin := range data {
+		if n < c.Bins[binI] {
+			continue
+		}
+
+		if n >= c.Bins[binI+1] {
+			binI++
+		}
+
+		if binI >= (len(c.Bins) - 1) {
+			break
+		}
+
+		for c, value := range fftBin {
+			if isNaNOrInf32(value) {
+				continue
+			}
+
+			if isNaN32(to[c][binI]) {
+				to[c][binI] = 0
+			}
+
+			to[c][binI] += value
+		}
+	}
+}
+
+// float32 version of CombineChannelsAvg
+func CombineChannelsAvg32(in [][]float32, to []float32) (out []float32) {
+	cs := float32(len(in))
+
+	if to != nil {
+		out = to
+		if len(out) != len(in[0]) {
+			panic("bad to slice passed, bad length")
+		}
+	} else {
+		out = make([]float32, len(in[0]))
+	}
+
+	copy(out, in[0])
+
+	for _, ch := range in[1:] {
+		for i, val := range ch {
+			out[i] += val
+		}
+	}
+
+	for i := range out {
+		out[i] /= cs
+	}
+
+	return
+}
+
+func DBConversion32(in []float32) {
+	for i, v := range in {
+		in[i] = float32(math.Log10(float64(v)) * 10)
+	}
+}
+
+func DBConversionCs32(in [][]float32) {
+	for _, v := range in {
+		DBConversion32(v)
+	}
+}
+
+func isNaN32(v float32) bool {
+	return v != v
+}
+
+func isNaNOrInf32(v float32) bool {
+	return isNaN32(v) || math.IsInf(float64(v), 0)
+}
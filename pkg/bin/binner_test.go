@@ -119,4 +119,23 @@ var _ = Describe("Binner", func() {
 			}
 		}
 	})
+	It("bins float32 data the same as float64 data", func() {
+		spec := PrecomputeBinSpec(len(exampleData), sampleRate, bins, fmin, fmax, gamma)
+		spec.Bin(exampleData, resultData)
+
+		data32 := util.CreateNDTs(float32(0), N, channels).([][]float32)
+		for n, d := range exampleData {
+			for i, v := range d {
+				data32[n][i] = float32(v)
+			}
+		}
+
+		result32 := util.CreateNDTs(float32(0), channels, bins).([][]float32)
+		spec.Bin32(data32, result32)
+		for c, ch := range resultData {
+			for i, v := range ch {
+				Expect(float64(result32[c][i])).Should(BeNumerically("~", v, 1e-3*v))
+			}
+		}
+	})
 })
@@ -0,0 +1,193 @@
+package chroma
+
+import (
+	"math"
+
+	"github.com/Twister915/vis.go/pkg/fft"
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+// pitch class profiles (chroma), how much of each of the 12 notes of the octave a frame of audio contains regardless
+// of which octave it is in. Index 0 is C
+
+const PitchClasses = 12
+
+var PitchClassNames = [PitchClasses]string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+
+const (
+	DefaultA4      = 440.0
+	defaultMinFreq = 55.0
+	defaultMaxFreq = 5000.0
+)
+
+type Config struct {
+	// the tuning, the frequency of the A above middle C. 0 means 440 Hz, see TuningEstimator to find it
+	A4 float64
+
+	// only bins between these frequencies count, low bins are too coarse to tell notes apart and high ones are mostly
+	// overtones and noise. 0 means 55 Hz and 5 kHz
+	MinFreq, MaxFreq float64
+
+	// divide each frame by its largest value, so the strongest pitch class is 1
+	Normalize bool
+}
+
+func (c Config) a4() float64 {
+	if c.A4 <= 0 {
+		return DefaultA4
+	}
+
+	return c.A4
+}
+
+// maps FFT bins onto pitch classes. Each bin is split between the two pitch classes either side of it, in proportion
+// to how close it is to each
+type Filterbank struct {
+	bins      []chromaBin
+	normalize bool
+}
+
+// FFT bin k adds weight of itself to class, and the rest to the class above
+type chromaBin struct {
+	k      int
+	class  int
+	weight float64
+}
+
+// a filterbank for spectra of fftSize point transforms at sampleRate
+func NewFilterbank(sampleRate, fftSize int, cfg Config) *Filterbank {
+	minFreq, maxFreq := cfg.MinFreq, cfg.MaxFreq
+	if minFreq <= 0 {
+		minFreq = defaultMinFreq
+	}
+
+	if maxFreq <= 0 {
+		maxFreq = defaultMaxFreq
+	}
+
+	out := &Filterbank{normalize: cfg.Normalize}
+	binHz := float64(sampleRate) / float64(fftSize)
+	for k := 1; k <= fftSize/2; k++ {
+		hz := float64(k) * binHz
+		if hz < minFreq || hz > maxFreq {
+			continue
+		}
+
+		position := math.Mod(Pitch(hz, cfg.a4()), PitchClasses)
+		if position < 0 {
+			position += PitchClasses
+		}
+
+		class := int(math.Floor(position))
+		out.bins = append(out.bins, chromaBin{k: k, class: class % PitchClasses, weight: 1 - (position - float64(class))})
+	}
+
+	return out
+}
+
+// the MIDI note number of a frequency (69 is A4, 60 is middle C), fractional between notes
+func Pitch(hz, a4 float64) float64 {
+	return 69 + PitchClasses*math.Log2(hz/a4)
+}
+
+// folds a spectrum, indexed [bin][channel] like FFTByFrame.Compute, into dst (PitchClasses long). The channels are
+// mixed, chroma describes the harmony and is the same in every channel
+func (f *Filterbank) Apply(spectrum [][]float64, dst []float64) {
+	for i := range dst {
+		dst[i] = 0
+	}
+
+	for _, b := range f.bins {
+		if b.k >= len(spectrum) {
+			continue
+		}
+
+		var v float64
+		for _, c := range spectrum[b.k] {
+			v += c
+		}
+
+		dst[b.class] += v * b.weight
+		dst[(b.class+1)%PitchClasses] += v * (1 - b.weight)
+	}
+
+	if f.normalize {
+		var largest float64
+		for _, v := range dst {
+			largest = math.Max(largest, v)
+		}
+
+		if largest > 0 {
+			for i := range dst {
+				dst[i] /= largest
+			}
+		}
+	}
+}
+
+// the chroma of every frame of an FFTByFrame. Power spectra (Scaling: fft.Power) give the clearest result, and are what
+// librosa's chroma_stft uses
+type Analyzer struct {
+	frames   *fft.FFTByFrame
+	bank     *Filterbank
+	spectrum [][]float64
+}
+
+func NewAnalyzer(frames *fft.FFTByFrame, cfg Config) *Analyzer {
+	return &Analyzer{
+		frames:   frames,
+		bank:     NewFilterbank(frames.SampleRate(), frames.FFTSize(), cfg),
+		spectrum: util.Create2DFloats(frames.NumberOutputFrequencies(), frames.Channels()),
+	}
+}
+
+func (a *Analyzer) HasNext() bool {
+	return a.frames.HasNext()
+}
+
+// dst is PitchClasses long
+func (a *Analyzer) Compute(dst []float64) (err error) {
+	if err = a.frames.Compute(a.spectrum); err != nil {
+		return
+	}
+
+	a.bank.Apply(a.spectrum, dst)
+	return
+}
+
+// every remaining frame, indexed [frame][pitch class]
+func (a *Analyzer) ComputeAll() (all [][]float64, err error) {
+	for a.HasNext() {
+		frame := make([]float64, PitchClasses)
+		if err = a.Compute(frame); err != nil {
+			return
+		}
+
+		all = append(all, frame)
+	}
+
+	return
+}
+
+// closes the FFTByFrame
+func (a *Analyzer) Close() error {
+	return a.frames.Close()
+}
+
+// the average of every frame, indexed [frame][pitch class]. This is what EstimateKey takes for a whole track
+func Mean(frames [][]float64) (out []float64) {
+	out = make([]float64, PitchClasses)
+	for _, frame := range frames {
+		for i, v := range frame {
+			out[i] += v
+		}
+	}
+
+	if len(frames) > 0 {
+		for i := range out {
+			out[i] /= float64(len(frames))
+		}
+	}
+
+	return
+}
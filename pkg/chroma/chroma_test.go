@@ -0,0 +1,204 @@
+package chroma_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/audio"
+	"github.com/Twister915/vis.go/pkg/chroma"
+	"github.com/Twister915/vis.go/pkg/fft"
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+const testSampleRate = 22050
+
+// a mono recording of sinusoids at frequencies, seconds long
+func tones(seconds float64, frequencies ...float64) audio.Input {
+	samples := util.Create2DFloats(int(seconds*testSampleRate), 1)
+	for i := range samples {
+		for _, hz := range frequencies {
+			samples[i][0] += 0.25 * math.Sin(2*math.Pi*hz*float64(i)/testSampleRate)
+		}
+	}
+
+	return audio.ToInput(audio.NewMemoryReader(audio.Format{SampleRate: testSampleRate, Channels: 1, BitDepth: 16}, samples))
+}
+
+// the frequency of a MIDI note
+func note(pitch int, a4 float64) float64 {
+	return a4 * math.Pow(2, float64(pitch-69)/12)
+}
+
+func powerFrames(input audio.Input) *fft.FFTByFrame {
+	return fft.NewFFTByFrameOptions(input, time.Millisecond*186, time.Millisecond*93, fft.HannWindow, fft.Options{Scaling: fft.Power})
+}
+
+func loudest(frame []float64) (out int) {
+	for i, v := range frame {
+		if v > frame[out] {
+			out = i
+		}
+	}
+
+	return
+}
+
+func TestAnalyzerFindsPitchClass(t *testing.T) {
+	cases := []struct {
+		pitch int
+		a4    float64
+	}{
+		{69, 440},
+		{60, 440},
+		{64, 440},
+		{67, 440},
+		// a quarter tone flat, only right when the tuning is known
+		{69, 427.5},
+		{70, 427.5},
+	}
+
+	for _, c := range cases {
+		analyzer := chroma.NewAnalyzer(powerFrames(tones(1, note(c.pitch, c.a4))), chroma.Config{A4: c.a4, Normalize: true})
+		frames, err := analyzer.ComputeAll()
+		analyzer.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(frames) == 0 {
+			t.Fatal("no frames")
+		}
+
+		for _, frame := range frames {
+			if class := loudest(frame); class != c.pitch%12 || frame[class] != 1 {
+				t.Fatalf("MIDI %d (A4 = %v) came out as %s (%v)", c.pitch, c.a4, chroma.PitchClassNames[class], frame)
+			}
+		}
+	}
+}
+
+func TestEstimateKeyOfProfiles(t *testing.T) {
+	for _, profile := range []chroma.KeyProfile{chroma.KrumhanslKessler, chroma.Temperley} {
+		for tonic := 0; tonic < 12; tonic++ {
+			for _, minor := range []bool{false, true} {
+				template := profile.Major
+				if minor {
+					template = profile.Minor
+				}
+
+				vector := make([]float64, 12)
+				for i := range vector {
+					vector[(tonic+i)%12] = template[i]
+				}
+
+				key, keys, err := chroma.EstimateKey(vector, profile)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if key.Tonic != tonic || key.Minor != minor || math.Abs(key.Correlation-1) > 1e-12 {
+					t.Fatalf("%s %v came out as %s (%v)", chroma.PitchClassNames[tonic], minor, key, key.Correlation)
+				}
+
+				if len(keys) != 24 {
+					t.Fatalf("%d keys", len(keys))
+				}
+
+				if keys[0] != key {
+					t.Fatalf("the first key is %s, expected %s", keys[0], key)
+				}
+
+				seen := make(map[chroma.Key]bool)
+				for i, k := range keys {
+					if i > 0 && k.Correlation > keys[i-1].Correlation {
+						t.Fatalf("%s (%v) follows %s (%v)", k, k.Correlation, keys[i-1], keys[i-1].Correlation)
+					}
+
+					seen[chroma.Key{Tonic: k.Tonic, Minor: k.Minor}] = true
+				}
+
+				if len(seen) != 24 {
+					t.Fatalf("%d distinct keys", len(seen))
+				}
+			}
+		}
+	}
+}
+
+func TestEstimateKeyLength(t *testing.T) {
+	for _, n := range []int{0, 11, 13, 24} {
+		if _, _, err := chroma.EstimateKey(make([]float64, n), chroma.KrumhanslKessler); err == nil {
+			t.Fatalf("estimated a key from %d pitch classes", n)
+		}
+	}
+}
+
+func TestKeyString(t *testing.T) {
+	if s := (chroma.Key{Tonic: 9, Minor: true}).String(); s != "A minor" {
+		t.Fatal(s)
+	}
+
+	if s := (chroma.Key{Tonic: 1}).String(); s != "C# major" {
+		t.Fatal(s)
+	}
+}
+
+// the tonic triad of a key played over its scale
+func TestEstimateKeyOfTrack(t *testing.T) {
+	cases := []struct {
+		scale []int
+		triad []int
+		key   string
+	}{
+		{[]int{60, 62, 64, 65, 67, 69, 71}, []int{48, 52, 55}, "C major"},
+		{[]int{57, 59, 60, 62, 64, 65, 67}, []int{45, 48, 52}, "A minor"},
+		{[]int{62, 64, 66, 67, 69, 71, 73}, []int{50, 54, 57}, "D major"},
+	}
+
+	for _, c := range cases {
+		var frequencies []float64
+		for _, p := range append(c.scale, c.triad...) {
+			frequencies = append(frequencies, note(p, chroma.DefaultA4))
+		}
+
+		// the triad twice as loud
+		frequencies = append(frequencies, frequencies[len(c.scale):]...)
+
+		analyzer := chroma.NewAnalyzer(powerFrames(tones(1, frequencies...)), chroma.Config{})
+		frames, err := analyzer.ComputeAll()
+		analyzer.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		key, _, err := chroma.EstimateKey(chroma.Mean(frames), chroma.KrumhanslKessler)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if key.String() != c.key {
+			t.Fatalf("expected %s, got %s", c.key, key)
+		}
+	}
+}
+
+func TestTuningEstimator(t *testing.T) {
+	for _, a4 := range []float64{440, 445, 432, 452} {
+		frames := powerFrames(tones(1, note(57, a4), note(64, a4), note(69, a4), note(73, a4)))
+		estimator := chroma.NewTuningEstimator(frames.SampleRate(), frames.FFTSize())
+		spectrum := util.Create2DFloats(frames.NumberOutputFrequencies(), frames.Channels())
+		for frames.HasNext() {
+			if err := frames.Compute(spectrum); err != nil {
+				t.Fatal(err)
+			}
+
+			estimator.Add(spectrum)
+		}
+
+		frames.Close()
+		if estimate := estimator.A4(); math.Abs(estimate-a4) > 0.5 {
+			t.Fatalf("tuned to %v, estimated %v", a4, estimate)
+		}
+	}
+}
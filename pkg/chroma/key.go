@@ -0,0 +1,101 @@
+package chroma
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// how strongly each scale degree (starting from the tonic) suggests a key, for major and minor keys
+type KeyProfile struct {
+	Major, Minor [PitchClasses]float64
+}
+
+var (
+	// from probe tone experiments, Krumhansl & Kessler (1982)
+	KrumhanslKessler = KeyProfile{
+		Major: [PitchClasses]float64{6.35, 2.23, 3.48, 2.33, 4.38, 4.09, 2.52, 5.19, 2.39, 3.66, 2.29, 2.88},
+		Minor: [PitchClasses]float64{6.33, 2.68, 3.52, 5.38, 2.60, 3.53, 2.54, 4.75, 3.98, 2.69, 3.34, 3.17},
+	}
+
+	// from note counts in the Kostka-Payne corpus, Temperley (1999)
+	Temperley = KeyProfile{
+		Major: [PitchClasses]float64{5.0, 2.0, 3.5, 2.0, 4.5, 4.0, 2.0, 4.5, 2.0, 3.5, 1.5, 4.0},
+		Minor: [PitchClasses]float64{5.0, 2.0, 3.5, 4.5, 2.0, 4.0, 2.0, 4.5, 3.5, 2.0, 1.5, 4.0},
+	}
+)
+
+type Key struct {
+	// the pitch class of the tonic, 0 is C
+	Tonic int
+	Minor bool
+
+	// Pearson correlation of the chroma with the key's profile, from -1 to 1
+	Correlation float64
+}
+
+func (k Key) String() string {
+	mode := "major"
+	if k.Minor {
+		mode = "minor"
+	}
+
+	return fmt.Sprintf("%s %s", PitchClassNames[k.Tonic], mode)
+}
+
+// the key whose profile best correlates with chroma (Krumhansl-Schmuckler), for a whole track pass the Mean of its
+// frames. keys has all 24 keys, best first (ties keep C to B, majors before minors). chroma must have exactly
+// PitchClasses values
+func EstimateKey(chroma []float64, profile KeyProfile) (best Key, keys []Key, err error) {
+	if len(chroma) != PitchClasses {
+		err = fmt.Errorf("chroma has %d pitch classes, expected %d", len(chroma), PitchClasses)
+		return
+	}
+
+	keys = make([]Key, 0, 2*PitchClasses)
+	for _, minor := range []bool{false, true} {
+		template := profile.Major
+		if minor {
+			template = profile.Minor
+		}
+
+		for tonic := 0; tonic < PitchClasses; tonic++ {
+			// the profile starts at the tonic
+			rotated := make([]float64, PitchClasses)
+			for i := range rotated {
+				rotated[(tonic+i)%PitchClasses] = template[i]
+			}
+
+			keys = append(keys, Key{Tonic: tonic, Minor: minor, Correlation: correlation(chroma, rotated)})
+		}
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].Correlation > keys[j].Correlation })
+	best = keys[0]
+	return
+}
+
+func correlation(a, b []float64) float64 {
+	var meanA, meanB float64
+	for i := range a {
+		meanA += a[i]
+		meanB += b[i]
+	}
+
+	meanA /= float64(len(a))
+	meanB /= float64(len(b))
+
+	var ab, aa, bb float64
+	for i := range a {
+		da, db := a[i]-meanA, b[i]-meanB
+		ab += da * db
+		aa += da * da
+		bb += db * db
+	}
+
+	if aa == 0 || bb == 0 {
+		return 0
+	}
+
+	return ab / math.Sqrt(aa*bb)
+}
@@ -0,0 +1,74 @@
+package chroma
+
+import "math"
+
+// estimates how far a recording's tuning is from A4 = 440 Hz, from the peaks of its spectra. Every peak's distance to
+// the nearest equal tempered note is averaged (on a circle, so -0.5 and +0.5 semitones agree), weighted by the peak's
+// size
+type TuningEstimator struct {
+	sampleRate, fftSize int
+
+	// the sum of each deviation as a unit vector, times its weight
+	x, y float64
+}
+
+func NewTuningEstimator(sampleRate, fftSize int) *TuningEstimator {
+	return &TuningEstimator{sampleRate: sampleRate, fftSize: fftSize}
+}
+
+// peaks smaller than this fraction of the frame's largest are ignored
+const tuningPeakThreshold = 0.1
+
+// adds the peaks of a magnitude or power spectrum, indexed [bin][channel] like FFTByFrame.Compute
+func (t *TuningEstimator) Add(spectrum [][]float64) {
+	mixed := make([]float64, len(spectrum))
+	var largest float64
+	for k, bin := range spectrum {
+		for _, v := range bin {
+			mixed[k] += v
+		}
+
+		largest = math.Max(largest, mixed[k])
+	}
+
+	binHz := float64(t.sampleRate) / float64(t.fftSize)
+	for k := 2; k < len(mixed)-1; k++ {
+		a, b, c := mixed[k-1], mixed[k], mixed[k+1]
+		if b <= a || b < c || b < largest*tuningPeakThreshold {
+			continue
+		}
+
+		hz := float64(k) * binHz
+		if hz < defaultMinFreq || hz > defaultMaxFreq {
+			continue
+		}
+
+		// the vertex of the parabola through the logs of the peak and its neighbours, which is exact for a Gaussian and
+		// close for most windows
+		if a > 0 && c > 0 {
+			la, lb, lc := math.Log(a), math.Log(b), math.Log(c)
+			if curvature := la - 2*lb + lc; curvature != 0 {
+				hz += 0.5 * (la - lc) / curvature * binHz
+			}
+		}
+
+		p := Pitch(hz, DefaultA4)
+		deviation := p - math.Round(p)
+		t.x += b * math.Cos(2*math.Pi*deviation)
+		t.y += b * math.Sin(2*math.Pi*deviation)
+	}
+}
+
+// the estimated deviation from A4 = 440 Hz in semitones, in (-0.5, 0.5]
+func (t *TuningEstimator) Deviation() float64 {
+	if t.x == 0 && t.y == 0 {
+		return 0
+	}
+
+	return math.Atan2(t.y, t.x) / (2 * math.Pi)
+}
+
+// the estimated frequency of A4, to pass as Config.A4
+func (t *TuningEstimator) A4() float64 {
+	return DefaultA4 * math.Pow(2, t.Deviation()/PitchClasses)
+}
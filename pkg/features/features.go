@@ -0,0 +1,253 @@
+package features
+
+import (
+	"math"
+
+	"github.com/Twister915/vis.go/pkg/fft"
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+// timbral descriptors of each frame, from its magnitude spectrum (FFTByFrame with Scaling: fft.Magnitude, the default)
+// and its samples. The channels are averaged first. Definitions follow librosa where it has them, and Peeters' "A large
+// set of audio features" (2004) otherwise
+
+type Frame struct {
+	// the magnitude weighted mean frequency in Hz, where the "center of mass" of the spectrum is. Higher is brighter
+	Centroid float64
+
+	// the magnitude weighted standard deviation of the frequency around Centroid, in Hz
+	Spread float64
+
+	// the frequency in Hz below which Config.RolloffPercent of the magnitudes are
+	Rolloff float64
+
+	// the geometric mean of the power over its arithmetic mean, from 0 for a pure tone to 1 for white noise
+	Flatness float64
+
+	// the largest magnitude over their mean, high for tonal frames
+	Crest float64
+
+	// the Euclidean distance between this frame's magnitudes and the last frame's, each divided by their sum so loudness
+	// does not count. 0 for the first frame
+	Flux float64
+
+	// the slope of a line fitted to the magnitudes over frequency, divided by their sum, per Hz. Negative when the
+	// magnitudes fall towards high frequencies, as they mostly do
+	Slope float64
+
+	// the fraction of neighbouring samples with different signs, NaN when there are no samples (see
+	// fft.Options.KeepSamples)
+	ZeroCrossingRate float64
+}
+
+type Config struct {
+	// 0 means 0.85, librosa's default
+	RolloffPercent float64
+}
+
+const (
+	defaultRolloffPercent = 0.85
+
+	// powers are clipped to this before taking the geometric mean, like librosa's amin (squared)
+	minPower = 1e-20
+)
+
+// computes Frames from spectra of fftSize point transforms at sampleRate, keeping the previous frame for Flux
+type Extractor struct {
+	rolloff float64
+
+	// the frequency of each bin, and the sums for Slope's fit which only depend on them
+	frequencies              []float64
+	sumFrequency, sumSquares float64
+
+	magnitudes, last []float64
+	hasLast          bool
+}
+
+func NewExtractor(sampleRate, fftSize int, cfg Config) *Extractor {
+	rolloff := cfg.RolloffPercent
+	if rolloff <= 0 {
+		rolloff = defaultRolloffPercent
+	}
+
+	bins := (fftSize / 2) + 1
+	out := &Extractor{
+		rolloff:     rolloff,
+		frequencies: make([]float64, bins),
+		magnitudes:  make([]float64, bins),
+		last:        make([]float64, bins),
+	}
+
+	for k := range out.frequencies {
+		f := float64(k) * float64(sampleRate) / float64(fftSize)
+		out.frequencies[k] = f
+		out.sumFrequency += f
+		out.sumSquares += f * f
+	}
+
+	return out
+}
+
+// the descriptors of one frame, spectrum is indexed [bin][channel] and samples [sample][channel] (both like
+// FFTByFrame). samples can be nil, which leaves ZeroCrossingRate NaN
+func (e *Extractor) Compute(spectrum [][]float64, samples [][]float64) (out Frame) {
+	mags := e.magnitudes[:len(spectrum)]
+	for k, bin := range spectrum {
+		var sum float64
+		for _, v := range bin {
+			sum += v
+		}
+
+		mags[k] = sum / float64(len(bin))
+	}
+
+	out = e.spectral(mags)
+	out.ZeroCrossingRate = ZeroCrossingRate(samples)
+
+	e.magnitudes, e.last = e.last, e.magnitudes
+	e.hasLast = true
+	return
+}
+
+// every descriptor but ZeroCrossingRate, in a few passes over the magnitudes
+func (e *Extractor) spectral(mags []float64) (out Frame) {
+	var sum, weighted, largest, logPower, power float64
+	for k, m := range mags {
+		f := e.frequencies[k]
+		sum += m
+		weighted += m * f
+		largest = math.Max(largest, m)
+
+		p := math.Max(m*m, minPower)
+		power += p
+		logPower += math.Log(p)
+	}
+
+	n := float64(len(mags))
+	if sum <= 0 {
+		// silence is flat and has no center
+		out.Flatness = 1
+		return
+	}
+
+	out.Centroid = weighted / sum
+	out.Crest = largest / (sum / n)
+	out.Flatness = math.Exp(logPower/n) / (power / n)
+
+	var variance, cumulative float64
+	threshold := e.rolloff * sum
+	out.Rolloff = e.frequencies[len(mags)-1]
+	rolled := false
+	for k, m := range mags {
+		d := e.frequencies[k] - out.Centroid
+		variance += m * d * d
+
+		if cumulative += m; !rolled && cumulative >= threshold {
+			out.Rolloff = e.frequencies[k]
+			rolled = true
+		}
+	}
+
+	out.Spread = math.Sqrt(variance / sum)
+
+	// least squares over the bins present, the frequency sums are precomputed for the whole spectrum
+	sumFrequency, sumSquares := e.sumFrequency, e.sumSquares
+	if len(mags) != len(e.frequencies) {
+		sumFrequency, sumSquares = 0, 0
+		for _, f := range e.frequencies[:len(mags)] {
+			sumFrequency += f
+			sumSquares += f * f
+		}
+	}
+
+	if denominator := n*sumSquares - sumFrequency*sumFrequency; denominator != 0 {
+		out.Slope = (n*weighted - sumFrequency*sum) / denominator / sum
+	}
+
+	if e.hasLast {
+		var lastSum float64
+		for _, m := range e.last[:len(mags)] {
+			lastSum += m
+		}
+
+		var flux float64
+		for k, m := range mags {
+			d := m / sum
+			if lastSum > 0 {
+				d -= e.last[k] / lastSum
+			}
+
+			flux += d * d
+		}
+
+		out.Flux = math.Sqrt(flux)
+	}
+
+	return
+}
+
+// the fraction of neighbouring samples (indexed [sample][channel]) with different signs, averaged over the channels.
+// Zero counts as positive, like librosa
+func ZeroCrossingRate(samples [][]float64) float64 {
+	if len(samples) < 2 {
+		return math.NaN()
+	}
+
+	var crossings int
+	for i := 1; i < len(samples); i++ {
+		for ch, v := range samples[i] {
+			if (v < 0) != (samples[i-1][ch] < 0) {
+				crossings++
+			}
+		}
+	}
+
+	return float64(crossings) / float64((len(samples)-1)*len(samples[0]))
+}
+
+// the descriptors of every frame of an FFTByFrame. For ZeroCrossingRate it needs Options.KeepSamples
+type Analyzer struct {
+	frames    *fft.FFTByFrame
+	extractor *Extractor
+	spectrum  [][]float64
+}
+
+func NewAnalyzer(frames *fft.FFTByFrame, cfg Config) *Analyzer {
+	return &Analyzer{
+		frames:    frames,
+		extractor: NewExtractor(frames.SampleRate(), frames.FFTSize(), cfg),
+		spectrum:  util.Create2DFloats(frames.NumberOutputFrequencies(), frames.Channels()),
+	}
+}
+
+func (a *Analyzer) HasNext() bool {
+	return a.frames.HasNext()
+}
+
+func (a *Analyzer) Compute() (out Frame, err error) {
+	if err = a.frames.Compute(a.spectrum); err != nil {
+		return
+	}
+
+	out = a.extractor.Compute(a.spectrum, a.frames.Samples())
+	return
+}
+
+// every remaining frame
+func (a *Analyzer) ComputeAll() (all []Frame, err error) {
+	for a.HasNext() {
+		var frame Frame
+		if frame, err = a.Compute(); err != nil {
+			return
+		}
+
+		all = append(all, frame)
+	}
+
+	return
+}
+
+// closes the FFTByFrame
+func (a *Analyzer) Close() error {
+	return a.frames.Close()
+}
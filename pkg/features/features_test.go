@@ -0,0 +1,132 @@
+package features_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/audio"
+	"github.com/Twister915/vis.go/pkg/features"
+	"github.com/Twister915/vis.go/pkg/fft"
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+const testSampleRate = 16000
+
+func analyze(t *testing.T, samples [][]float64) []features.Frame {
+	input := audio.ToInput(audio.NewMemoryReader(audio.Format{SampleRate: testSampleRate, Channels: len(samples[0]), BitDepth: 16}, samples))
+	frames := fft.NewFFTByFrameOptions(input, 64*time.Millisecond, 32*time.Millisecond, fft.HannWindow, fft.Options{KeepSamples: true})
+	a := features.NewAnalyzer(frames, features.Config{})
+	defer a.Close()
+
+	all, err := a.ComputeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return all
+}
+
+func sine(hz float64, seconds float64) [][]float64 {
+	samples := util.Create2DFloats(int(seconds*testSampleRate), 2)
+	for i := range samples {
+		v := 0.5 * math.Sin(2*math.Pi*hz*float64(i)/testSampleRate)
+		samples[i][0], samples[i][1] = v, v
+	}
+
+	return samples
+}
+
+func noise(seconds float64) [][]float64 {
+	random := rand.New(rand.NewSource(5))
+	samples := util.Create2DFloats(int(seconds*testSampleRate), 1)
+	for i := range samples {
+		samples[i][0] = 0.2 * random.NormFloat64()
+	}
+
+	return samples
+}
+
+func TestSine(t *testing.T) {
+	for _, frame := range analyze(t, sine(1000, 1)) {
+		if math.Abs(frame.Centroid-1000) > 5 {
+			t.Fatalf("centroid %v", frame.Centroid)
+		}
+
+		// the Hann window's main lobe is 2 bins (31 Hz) either side
+		if frame.Spread > 40 {
+			t.Fatalf("spread %v", frame.Spread)
+		}
+
+		if math.Abs(frame.Rolloff-1000) > 20 {
+			t.Fatalf("rolloff %v", frame.Rolloff)
+		}
+
+		if frame.Flatness > 0.01 || frame.Crest < 100 {
+			t.Fatalf("flatness %v, crest %v", frame.Flatness, frame.Crest)
+		}
+
+		// a steady tone barely changes
+		if frame.Flux > 0.01 {
+			t.Fatalf("flux %v", frame.Flux)
+		}
+
+		// 2 crossings per period
+		if math.Abs(frame.ZeroCrossingRate-2*1000.0/testSampleRate) > 0.002 {
+			t.Fatalf("zero crossing rate %v", frame.ZeroCrossingRate)
+		}
+	}
+}
+
+func TestNoise(t *testing.T) {
+	for _, frame := range analyze(t, noise(1)) {
+		// white noise is spread evenly up to the Nyquist frequency
+		if math.Abs(frame.Centroid-4000) > 300 || math.Abs(frame.Rolloff-6800) > 300 {
+			t.Fatalf("centroid %v, rolloff %v", frame.Centroid, frame.Rolloff)
+		}
+
+		// the spread of a uniform distribution over 8 kHz
+		if expected := 8000 / math.Sqrt(12); math.Abs(frame.Spread-expected) > 200 {
+			t.Fatalf("spread %v, expected %v", frame.Spread, expected)
+		}
+
+		// Rayleigh distributed magnitudes (exponential powers) have a flatness of about e^-γ (0.56), the window smooths
+		// neighbouring bins together which raises it a little
+		if frame.Flatness < 0.45 || frame.Flatness > 0.7 {
+			t.Fatalf("flatness %v", frame.Flatness)
+		}
+
+		if math.Abs(frame.Slope) > 2e-6 {
+			t.Fatalf("slope %v", frame.Slope)
+		}
+
+		if math.Abs(frame.ZeroCrossingRate-0.5) > 0.05 {
+			t.Fatalf("zero crossing rate %v", frame.ZeroCrossingRate)
+		}
+	}
+}
+
+func TestSlopeAndFlux(t *testing.T) {
+	e := features.NewExtractor(testSampleRate, 8, features.Config{})
+	falling := [][]float64{{4}, {3}, {2}, {1}, {0}}
+	frame := e.Compute(falling, nil)
+
+	// magnitudes fall by 1 per 2 kHz and sum to 10
+	if math.Abs(frame.Slope-(-1.0/2000/10)) > 1e-12 {
+		t.Fatalf("slope %v", frame.Slope)
+	}
+
+	if !math.IsNaN(frame.ZeroCrossingRate) || frame.Flux != 0 {
+		t.Fatalf("zero crossing rate %v, flux %v", frame.ZeroCrossingRate, frame.Flux)
+	}
+
+	// the same shape louder has no flux, moving the energy has
+	if frame = e.Compute([][]float64{{8}, {6}, {4}, {2}, {0}}, nil); frame.Flux > 1e-12 {
+		t.Fatalf("flux %v", frame.Flux)
+	}
+
+	if frame = e.Compute([][]float64{{0}, {0}, {0}, {0}, {1}}, nil); math.Abs(frame.Flux-math.Sqrt(0.16+0.09+0.04+0.01+1)) > 1e-12 {
+		t.Fatalf("flux %v", frame.Flux)
+	}
+}
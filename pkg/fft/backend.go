@@ -0,0 +1,108 @@
+package fft
+
+// a transform implementation, FFTByFrame and FFTComputeAll only talk to the FFT library through this
+//
+// buffers passed to a plan should come from the same backend's Alloc methods (FFTW wants its own aligned memory) and
+// must outlive the plan
+type Backend interface {
+	// used in log messages
+	Name() string
+
+	AllocReals(n int) []float64
+	AllocComplexes(n int) []complex128
+	AllocFloats(n int) []float32
+	AllocFloatComplexes(n int) []complex64
+
+	// releases a slice returned by one of the Alloc methods
+	Free(buf interface{})
+
+	// plans howmany real-to-complex transforms of size n, with the same layout parameters as fftw_plan_many_dft_r2c.
+	// Each transform reads n reals and writes (n / 2) + 1 complex values
+	PlanR2C(n, howmany int, in []float64, istride, idist int, out []complex128, ostride, odist int, p Planning) Plan
+
+	// single precision version of PlanR2C
+	PlanR2C32(n, howmany int, in []float32, istride, idist int, out []complex64, ostride, odist int, p Planning) Plan
+
+	// plans howmany complex-to-real transforms of size n, the inverse of PlanR2C. Each transform reads (n / 2) + 1
+	// complex values and writes n reals. Like FFTW the result is not normalized (it is n times the original signal),
+	// the imaginary parts of the first and (for even n) last values are ignored, and the input may be overwritten
+	PlanC2R(n, howmany int, in []complex128, istride, idist int, out []float64, ostride, odist int, p Planning) Plan
+
+	// single precision version of PlanC2R
+	PlanC2R32(n, howmany int, in []complex64, istride, idist int, out []float32, ostride, odist int, p Planning) Plan
+}
+
+// a planned batch of transforms, bound to the buffers it was planned with
+type Plan interface {
+	Execute()
+
+	// runs the plan on other buffers with the same layout (and from the same backend's Alloc methods, so they have the
+	// same alignment). The types are those of the Plan method which made it, []float64 & []complex128 for PlanR2C.
+	// Unlike Execute this can be called from several goroutines at once, with different buffers
+	ExecuteOn(in, out interface{})
+
+	// destroys the plan, but not the buffers
+	Close()
+}
+
+// used when Options.Backend is nil. This is FFTW when built with cgo, and GoBackend when built with CGO_ENABLED=0 or
+// the purego tag
+var DefaultBackend Backend = GoBackend{}
+
+// optional settings for FFTByFrame and FFTComputeAll (and their single precision versions). The zero value gives the
+// same behaviour as NewFFTByFrame, NewFFTComputeAll is the same as Scaling: Power with RemoveDC
+type Options struct {
+	// round the window up to the next power of two samples, the same as Sizing: NextPow2Size
+	PowTwo bool
+
+	// how the window length in samples is picked from the window duration, see Sizing
+	Sizing Sizing
+
+	// transform size in samples. When it is longer than the window each frame is zero padded to it, which samples the
+	// spectrum on finer bins without changing the window's length in time. The window is only applied to the real
+	// samples. 0 (or anything shorter than the window) transforms just the window
+	FFTSize int
+
+	// what Compute & ComputeAll output for each bin
+	Scaling Scaling
+
+	// subtract the mean of each windowed frame before transforming, which zeroes the DC bin. This applies to every
+	// output, including the complex ones
+	RemoveDC bool
+
+	Backend Backend
+
+	Planning Planning
+
+	// reuse plans & buffers between analyzers, when nil every analyzer plans (and frees) its own
+	Cache *PlanCache
+
+	// keep a copy of each frame's samples from before windowing, for time domain features (see FFTByFrame.Samples).
+	// Only FFTByFrame and FFTByFrame32 use this
+	KeepSamples bool
+}
+
+// the transform size for a window of windowSamples samples
+func transformSize(windowSamples, fftSize int) int {
+	if fftSize > windowSamples {
+		return fftSize
+	}
+
+	return windowSamples
+}
+
+func (o Options) sizing() Sizing {
+	if o.PowTwo {
+		return NextPow2Size
+	}
+
+	return o.Sizing
+}
+
+func (o Options) backend() Backend {
+	if o.Backend == nil {
+		return DefaultBackend
+	}
+
+	return o.Backend
+}
@@ -0,0 +1,336 @@
+//go:build cgo && !purego
+// +build cgo,!purego
+
+package fft
+
+//#include "fftw3.h"
+import "C"
+
+import (
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/rs/zerolog/log"
+)
+
+func init() {
+	DefaultBackend = FFTWBackend{}
+}
+
+// transforms done by libfftw3 (and libfftw3f for single precision), the default whenever cgo is available
+type FFTWBackend struct{}
+
+func (FFTWBackend) Name() string {
+	return "fftw"
+}
+
+func (FFTWBackend) AllocReals(n int) []float64 {
+	return AllocFFTWDoubles(n)
+}
+
+func (FFTWBackend) AllocComplexes(n int) []complex128 {
+	return AllocFFTWComplexes(n)
+}
+
+func (FFTWBackend) AllocFloats(n int) []float32 {
+	return AllocFFTWFloats(n)
+}
+
+func (FFTWBackend) AllocFloatComplexes(n int) []complex64 {
+	return AllocFFTWFloatComplexes(n)
+}
+
+func (FFTWBackend) Free(buf interface{}) {
+	switch b := buf.(type) {
+	case []float64:
+		FFTWDoubles(b).Free()
+	case []complex128:
+		FFTWComplexes(b).Free()
+	case []float32:
+		FFTWFloats(b).Free()
+	case []complex64:
+		FFTWFloatComplexes(b).Free()
+	default:
+		panic("cannot free this type")
+	}
+}
+
+func (FFTWBackend) PlanR2C(n, howmany int, in []float64, istride, idist int, out []complex128, ostride, odist int, p Planning) Plan {
+	scratch := func() (input, output unsafe.Pointer, free func()) {
+		scratchIn, scratchOut := AllocFFTWDoubles(len(in)), AllocFFTWComplexes(len(out))
+		return unsafe.Pointer(&scratchIn[0]), unsafe.Pointer(&scratchOut[0]), func() {
+			scratchIn.Free()
+			scratchOut.Free()
+		}
+	}
+
+	return planDouble(false, n, howmany, in, istride, idist, out, ostride, odist, p, scratch)
+}
+
+func (FFTWBackend) PlanR2C32(n, howmany int, in []float32, istride, idist int, out []complex64, ostride, odist int, p Planning) Plan {
+	scratch := func() (input, output unsafe.Pointer, free func()) {
+		scratchIn, scratchOut := AllocFFTWFloats(len(in)), AllocFFTWFloatComplexes(len(out))
+		return unsafe.Pointer(&scratchIn[0]), unsafe.Pointer(&scratchOut[0]), func() {
+			scratchIn.Free()
+			scratchOut.Free()
+		}
+	}
+
+	return planSingle(false, n, howmany, in, istride, idist, out, ostride, odist, p, scratch)
+}
+
+func (FFTWBackend) PlanC2R(n, howmany int, in []complex128, istride, idist int, out []float64, ostride, odist int, p Planning) Plan {
+	scratch := func() (input, output unsafe.Pointer, free func()) {
+		scratchIn, scratchOut := AllocFFTWComplexes(len(in)), AllocFFTWDoubles(len(out))
+		return unsafe.Pointer(&scratchIn[0]), unsafe.Pointer(&scratchOut[0]), func() {
+			scratchIn.Free()
+			scratchOut.Free()
+		}
+	}
+
+	return planDouble(true, n, howmany, in, istride, idist, out, ostride, odist, p, scratch)
+}
+
+func (FFTWBackend) PlanC2R32(n, howmany int, in []complex64, istride, idist int, out []float32, ostride, odist int, p Planning) Plan {
+	scratch := func() (input, output unsafe.Pointer, free func()) {
+		scratchIn, scratchOut := AllocFFTWFloatComplexes(len(in)), AllocFFTWFloats(len(out))
+		return unsafe.Pointer(&scratchIn[0]), unsafe.Pointer(&scratchOut[0]), func() {
+			scratchIn.Free()
+			scratchOut.Free()
+		}
+	}
+
+	return planSingle(true, n, howmany, in, istride, idist, out, ostride, odist, p, scratch)
+}
+
+// scratch returns buffers the same size as in & out, which background measurement can overwrite
+type scratchBuffers func() (input, output unsafe.Pointer, free func())
+
+// one FFTW plan in either precision
+type fftwHandle struct {
+	execute   func()
+	executeOn func(in, out interface{})
+	destroy   func()
+}
+
+// in & out are []float64 and []complex128, swapped when inverse
+func planDouble(inverse bool, n, howmany int, in interface{}, istride, idist int, out interface{}, ostride, odist int, p Planning, scratch scratchBuffers) Plan {
+	build := func(input, output unsafe.Pointer, flags C.uint) (h fftwHandle, ok bool) {
+		plan := fft_plan(
+			p.timeLimit(), runtime.NumCPU(), n, howmany,
+			input, istride, idist,
+			output, ostride, odist,
+			inverse, flags|C.FFTW_DESTROY_INPUT,
+		)
+
+		if plan == nil {
+			return
+		}
+
+		h.execute = func() { C.fftw_execute(plan) }
+		h.destroy = func() { fft_destroy_plan(plan) }
+		h.executeOn = func(i, o interface{}) {
+			checkSameLengths(in, out, i, o)
+			if inverse {
+				reals, complexes := o.([]float64), i.([]complex128)
+				C.fftw_execute_dft_c2r(plan, (*C.fftw_complex)(unsafe.Pointer(&complexes[0])), (*C.double)(&reals[0]))
+			} else {
+				reals, complexes := i.([]float64), o.([]complex128)
+				C.fftw_execute_dft_r2c(plan, (*C.double)(&reals[0]), (*C.fftw_complex)(unsafe.Pointer(&complexes[0])))
+			}
+		}
+
+		ok = true
+		return
+	}
+
+	return newFFTWPlan(build, firstElement(in), firstElement(out), scratch, p)
+}
+
+// in & out are []float32 and []complex64, swapped when inverse
+func planSingle(inverse bool, n, howmany int, in interface{}, istride, idist int, out interface{}, ostride, odist int, p Planning, scratch scratchBuffers) Plan {
+	build := func(input, output unsafe.Pointer, flags C.uint) (h fftwHandle, ok bool) {
+		plan := fftf_plan(
+			p.timeLimit(), runtime.NumCPU(), n, howmany,
+			input, istride, idist,
+			output, ostride, odist,
+			inverse, flags|C.FFTW_DESTROY_INPUT,
+		)
+
+		if plan == nil {
+			return
+		}
+
+		h.execute = func() { C.fftwf_execute(plan) }
+		h.destroy = func() { fftf_destroy_plan(plan) }
+		h.executeOn = func(i, o interface{}) {
+			checkSameLengths(in, out, i, o)
+			if inverse {
+				reals, complexes := o.([]float32), i.([]complex64)
+				C.fftwf_execute_dft_c2r(plan, (*C.fftwf_complex)(unsafe.Pointer(&complexes[0])), (*C.float)(&reals[0]))
+			} else {
+				reals, complexes := i.([]float32), o.([]complex64)
+				C.fftwf_execute_dft_r2c(plan, (*C.float)(&reals[0]), (*C.fftwf_complex)(unsafe.Pointer(&complexes[0])))
+			}
+		}
+
+		ok = true
+		return
+	}
+
+	return newFFTWPlan(build, firstElement(in), firstElement(out), scratch, p)
+}
+
+func firstElement(buf interface{}) unsafe.Pointer {
+	switch b := buf.(type) {
+	case []float64:
+		return unsafe.Pointer(&b[0])
+	case []complex128:
+		return unsafe.Pointer(&b[0])
+	case []float32:
+		return unsafe.Pointer(&b[0])
+	case []complex64:
+		return unsafe.Pointer(&b[0])
+	default:
+		panic("not an FFTW buffer")
+	}
+}
+
+// FFTW cannot check the buffers passed to the new-array execute functions, so at least make sure they are as long as
+// the ones planned with
+func checkSameLengths(plannedIn, plannedOut, in, out interface{}) {
+	length := func(buf interface{}) int {
+		return reflect.ValueOf(buf).Len()
+	}
+
+	if reflect.TypeOf(in) != reflect.TypeOf(plannedIn) || reflect.TypeOf(out) != reflect.TypeOf(plannedOut) {
+		panic("buffer types do not match the plan")
+	}
+
+	if length(in) < length(plannedIn) || length(out) < length(plannedOut) {
+		panic("buffers are smaller than the plan's")
+	}
+}
+
+func rigorFlags(r Rigor) C.uint {
+	switch r {
+	case Measure:
+		return C.FFTW_MEASURE
+	case Patient:
+		return C.FFTW_PATIENT
+	case Exhaustive:
+		return C.FFTW_EXHAUSTIVE
+	default:
+		return C.FFTW_ESTIMATE
+	}
+}
+
+// either precision of FFTW plan. When the wisdom did not have the problem at the requested rigor this starts out as
+// an estimated plan, and is swapped for the plan from wisdom once the background measurement is done
+type fftwPlan struct {
+	// held for reading while executing, and for writing to swap or destroy the plan
+	mutex *sync.RWMutex
+
+	handle fftwHandle
+
+	// set while a background measurement is pending
+	fromWisdom func() (fftwHandle, bool)
+	// 1 once the measurement is done, 2 once the plan has been swapped
+	measured int32
+}
+
+// build plans on the given buffers with the given flags. Background measurement plans on scratch buffers (so the real
+// ones are not overwritten while they are in use) just to put the result in the wisdom
+func newFFTWPlan(build func(input, output unsafe.Pointer, flags C.uint) (fftwHandle, bool), in, out unsafe.Pointer, scratch scratchBuffers, p Planning) *fftwPlan {
+	must := func(h fftwHandle, ok bool) *fftwPlan {
+		if !ok {
+			panic("could not construct plan")
+		}
+
+		return &fftwPlan{mutex: new(sync.RWMutex), handle: h}
+	}
+
+	if p.Rigor == Estimate {
+		return must(build(in, out, C.FFTW_ESTIMATE))
+	}
+
+	flags := rigorFlags(p.Rigor)
+	if h, ok := build(in, out, flags|C.FFTW_WISDOM_ONLY); ok {
+		log.Info().Str("rigor", p.Rigor.String()).Msg("plan found in wisdom")
+		return must(h, ok)
+	}
+
+	if p.Wait {
+		return must(build(in, out, flags))
+	}
+
+	plan := must(build(in, out, C.FFTW_ESTIMATE))
+	plan.fromWisdom = func() (fftwHandle, bool) {
+		return build(in, out, flags|C.FFTW_WISDOM_ONLY)
+	}
+
+	go func() {
+		scratchIn, scratchOut, free := scratch()
+		defer free()
+
+		if h, ok := build(scratchIn, scratchOut, flags); ok {
+			h.destroy()
+		}
+
+		atomic.StoreInt32(&plan.measured, 1)
+	}()
+
+	return plan
+}
+
+// swaps in the measured plan once the background measurement has put it in the wisdom
+func (p *fftwPlan) upgrade() {
+	if atomic.LoadInt32(&p.measured) != 1 {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.fromWisdom == nil {
+		return
+	}
+
+	// planning from wisdom does not measure, so it leaves the buffers alone
+	if h, ok := p.fromWisdom(); ok {
+		p.handle.destroy()
+		p.handle = h
+		log.Info().Msg("switched to measured plan")
+	}
+
+	p.fromWisdom = nil
+	atomic.StoreInt32(&p.measured, 2)
+}
+
+func (p *fftwPlan) Execute() {
+	p.upgrade()
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	p.handle.execute()
+}
+
+func (p *fftwPlan) ExecuteOn(in, out interface{}) {
+	p.upgrade()
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	p.handle.executeOn(in, out)
+}
+
+func (p *fftwPlan) Close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.handle.destroy()
+}
@@ -0,0 +1,244 @@
+package fft
+
+import (
+	"fmt"
+	"sync"
+)
+
+// transforms done in pure Go, this is slower than FFTW but needs no C library (so it works with CGO_ENABLED=0 and when
+// cross compiling)
+type GoBackend struct{}
+
+func (GoBackend) Name() string {
+	return "go"
+}
+
+func (GoBackend) AllocReals(n int) []float64 {
+	return make([]float64, n)
+}
+
+func (GoBackend) AllocComplexes(n int) []complex128 {
+	return make([]complex128, n)
+}
+
+func (GoBackend) AllocFloats(n int) []float32 {
+	return make([]float32, n)
+}
+
+func (GoBackend) AllocFloatComplexes(n int) []complex64 {
+	return make([]complex64, n)
+}
+
+// the garbage collector owns the memory
+func (GoBackend) Free(buf interface{}) {}
+
+func (GoBackend) PlanR2C(n, howmany int, in []float64, istride, idist int, out []complex128, ostride, odist int, p Planning) Plan {
+	plan := newGoPlan(false, n, howmany, istride, idist, ostride, odist)
+	plan.buffers = goBuffers{reals: in, complexes: out}
+	plan.checkBounds(plan.buffers)
+	return plan
+}
+
+func (GoBackend) PlanR2C32(n, howmany int, in []float32, istride, idist int, out []complex64, ostride, odist int, p Planning) Plan {
+	plan := newGoPlan(false, n, howmany, istride, idist, ostride, odist)
+	plan.buffers = goBuffers{reals32: in, complexes32: out}
+	plan.checkBounds(plan.buffers)
+	return plan
+}
+
+func (GoBackend) PlanC2R(n, howmany int, in []complex128, istride, idist int, out []float64, ostride, odist int, p Planning) Plan {
+	plan := newGoPlan(true, n, howmany, istride, idist, ostride, odist)
+	plan.buffers = goBuffers{reals: out, complexes: in}
+	plan.checkBounds(plan.buffers)
+	return plan
+}
+
+func (GoBackend) PlanC2R32(n, howmany int, in []complex64, istride, idist int, out []float32, ostride, odist int, p Planning) Plan {
+	plan := newGoPlan(true, n, howmany, istride, idist, ostride, odist)
+	plan.buffers = goBuffers{reals32: out, complexes32: in}
+	plan.checkBounds(plan.buffers)
+	return plan
+}
+
+type goPlan struct {
+	transform *realFFT
+	inverse   bool
+
+	n, howmany                 int
+	realStride, realDist       int
+	complexStride, complexDist int
+
+	// the planned buffers, and the scratch Execute uses with them
+	buffers goBuffers
+	scratch *goScratch
+
+	// scratch for ExecuteOn, which can run on several goroutines at once
+	pool *sync.Pool
+}
+
+// only one real & one complex buffer is set, depending on precision
+type goBuffers struct {
+	reals       []float64
+	complexes   []complex128
+	reals32     []float32
+	complexes32 []complex64
+}
+
+type goScratch struct {
+	transform *realScratch
+
+	// one transform's worth of reals & complexes, gathered from / scattered to the strided buffers
+	realBuf    []float64
+	complexBuf []complex128
+}
+
+// the input layout is for the reals when transforming forward, and for the complexes when inverse
+func newGoPlan(inverse bool, n, howmany, istride, idist, ostride, odist int) *goPlan {
+	if n < 1 {
+		panic(fmt.Sprintf("could not construct plan of size %d", n))
+	}
+
+	out := &goPlan{
+		transform: newRealFFT(n),
+		inverse:   inverse,
+		n:         n,
+		howmany:   howmany,
+	}
+
+	if inverse {
+		out.complexStride, out.complexDist, out.realStride, out.realDist = istride, idist, ostride, odist
+	} else {
+		out.realStride, out.realDist, out.complexStride, out.complexDist = istride, idist, ostride, odist
+	}
+
+	out.scratch = out.newScratch()
+	out.pool = &sync.Pool{New: func() interface{} {
+		return out.newScratch()
+	}}
+
+	return out
+}
+
+func (p *goPlan) newScratch() *goScratch {
+	return &goScratch{
+		transform:  p.transform.newScratch(),
+		realBuf:    make([]float64, p.n),
+		complexBuf: make([]complex128, p.n/2+1),
+	}
+}
+
+func (p *goPlan) checkBounds(b goBuffers) {
+	realLen, complexLen := len(b.reals)+len(b.reals32), len(b.complexes)+len(b.complexes32)
+	lastReal := (p.howmany-1)*p.realDist + (p.n-1)*p.realStride
+	lastComplex := (p.howmany-1)*p.complexDist + (p.n/2)*p.complexStride
+	if lastReal >= realLen || lastComplex >= complexLen {
+		panic("could not construct plan")
+	}
+}
+
+func (p *goPlan) Execute() {
+	p.run(p.buffers, p.scratch)
+}
+
+func (p *goPlan) ExecuteOn(in, out interface{}) {
+	var b goBuffers
+	ok := true
+	switch {
+	case p.inverse && p.buffers.reals32 != nil:
+		b.complexes32, ok = in.([]complex64)
+		if ok {
+			b.reals32, ok = out.([]float32)
+		}
+	case p.inverse:
+		b.complexes, ok = in.([]complex128)
+		if ok {
+			b.reals, ok = out.([]float64)
+		}
+	case p.buffers.reals32 != nil:
+		b.reals32, ok = in.([]float32)
+		if ok {
+			b.complexes32, ok = out.([]complex64)
+		}
+	default:
+		b.reals, ok = in.([]float64)
+		if ok {
+			b.complexes, ok = out.([]complex128)
+		}
+	}
+
+	if !ok {
+		panic("buffer types do not match the plan")
+	}
+
+	p.checkBounds(b)
+
+	s := p.pool.Get().(*goScratch)
+	defer p.pool.Put(s)
+
+	p.run(b, s)
+}
+
+func (p *goPlan) run(b goBuffers, s *goScratch) {
+	for h := 0; h < p.howmany; h++ {
+		realAt, complexAt := h*p.realDist, h*p.complexDist
+		if p.inverse {
+			p.gatherComplexes(b, s, complexAt)
+			p.transform.inverse(s.complexBuf, s.realBuf, s.transform)
+			p.scatterReals(b, s, realAt)
+		} else {
+			p.gatherReals(b, s, realAt)
+			p.transform.transform(s.realBuf, s.complexBuf, s.transform)
+			p.scatterComplexes(b, s, complexAt)
+		}
+	}
+}
+
+func (p *goPlan) gatherReals(b goBuffers, s *goScratch, at int) {
+	if b.reals32 != nil {
+		for i := range s.realBuf {
+			s.realBuf[i] = float64(b.reals32[at+i*p.realStride])
+		}
+	} else {
+		for i := range s.realBuf {
+			s.realBuf[i] = b.reals[at+i*p.realStride]
+		}
+	}
+}
+
+func (p *goPlan) scatterReals(b goBuffers, s *goScratch, at int) {
+	if b.reals32 != nil {
+		for i, v := range s.realBuf {
+			b.reals32[at+i*p.realStride] = float32(v)
+		}
+	} else {
+		for i, v := range s.realBuf {
+			b.reals[at+i*p.realStride] = v
+		}
+	}
+}
+
+func (p *goPlan) gatherComplexes(b goBuffers, s *goScratch, at int) {
+	if b.complexes32 != nil {
+		for k := range s.complexBuf {
+			s.complexBuf[k] = complex128(b.complexes32[at+k*p.complexStride])
+		}
+	} else {
+		for k := range s.complexBuf {
+			s.complexBuf[k] = b.complexes[at+k*p.complexStride]
+		}
+	}
+}
+
+func (p *goPlan) scatterComplexes(b goBuffers, s *goScratch, at int) {
+	if b.complexes32 != nil {
+		for k, v := range s.complexBuf {
+			b.complexes32[at+k*p.complexStride] = complex64(v)
+		}
+	} else {
+		for k, v := range s.complexBuf {
+			b.complexes[at+k*p.complexStride] = v
+		}
+	}
+}
+
+func (p *goPlan) Close() {}
@@ -0,0 +1,135 @@
+package fft_test
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+
+	"github.com/Twister915/vis.go/pkg/fft"
+)
+
+func naiveDFT(in []float64) []complex128 {
+	n := len(in)
+	out := make([]complex128, n/2+1)
+	for k := range out {
+		for t, v := range in {
+			out[k] += complex(v, 0) * cmplx.Rect(1, -2*math.Pi*float64(k*t)/float64(n))
+		}
+	}
+
+	return out
+}
+
+func TestGoBackendMatchesDFT(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	// powers of two, mixed small factors, odd sizes, generic radix primes and Bluestein primes
+	sizes := []int{1, 2, 3, 4, 5, 6, 7, 8, 12, 15, 16, 30, 49, 64, 97, 100, 210, 256, 1000, 1024, 4096, 4099, 7276, 2 * 131}
+
+	var backend fft.GoBackend
+	for _, n := range sizes {
+		in := backend.AllocReals(n)
+		out := backend.AllocComplexes(n/2 + 1)
+		for i := range in {
+			in[i] = rnd.Float64()*2 - 1
+		}
+
+		expected := naiveDFT(in)
+
+		plan := backend.PlanR2C(n, 1, in, 1, n, out, 1, n/2+1, fft.Planning{})
+		plan.Execute()
+		plan.Close()
+
+		for k, v := range expected {
+			if cmplx.Abs(out[k]-v) > 1e-9*float64(n) {
+				t.Fatalf("n = %d, bin %d: got %v, expected %v", n, k, out[k], v)
+			}
+		}
+	}
+}
+
+func TestGoBackendStridedBatch(t *testing.T) {
+	const (
+		n        = 48
+		channels = 3
+		outN     = n/2 + 1
+	)
+
+	var backend fft.GoBackend
+	// interleaved input (like FFTByFrame), one channel per output row
+	in := backend.AllocReals(n * channels)
+	out := backend.AllocComplexes(outN * channels)
+	for i := range in {
+		in[i] = math.Sin(float64(i) * 0.37)
+	}
+
+	channelData := make([][]float64, channels)
+	for c := range channelData {
+		channelData[c] = make([]float64, n)
+		for i := range channelData[c] {
+			channelData[c][i] = in[i*channels+c]
+		}
+	}
+
+	plan := backend.PlanR2C(n, channels, in, channels, 1, out, 1, outN, fft.Planning{})
+	plan.Execute()
+
+	for c := range channelData {
+		for k, v := range naiveDFT(channelData[c]) {
+			if cmplx.Abs(out[c*outN+k]-v) > 1e-9 {
+				t.Fatalf("channel %d, bin %d: got %v, expected %v", c, k, out[c*outN+k], v)
+			}
+		}
+	}
+}
+
+func TestGoBackendSinglePrecision(t *testing.T) {
+	const n = 60
+
+	var backend fft.GoBackend
+	in := backend.AllocFloats(n)
+	in64 := make([]float64, n)
+	out := backend.AllocFloatComplexes(n/2 + 1)
+	for i := range in {
+		in[i] = float32(math.Cos(float64(i) * 0.21))
+		in64[i] = float64(in[i])
+	}
+
+	backend.PlanR2C32(n, 1, in, 1, n, out, 1, n/2+1, fft.Planning{}).Execute()
+
+	for k, v := range naiveDFT(in64) {
+		if cmplx.Abs(complex128(out[k])-v) > 1e-4 {
+			t.Fatalf("bin %d: got %v, expected %v", k, out[k], v)
+		}
+	}
+}
+
+// an empty transform is an error, not an endless search for its factors
+func TestGoBackendZeroSize(t *testing.T) {
+	var backend fft.GoBackend
+	defer func() {
+		if recover() == nil {
+			t.Fatal("planned a transform of size 0")
+		}
+	}()
+
+	backend.PlanR2C(0, 1, backend.AllocReals(1), 1, 1, backend.AllocComplexes(1), 1, 1, fft.Planning{})
+}
+
+func BenchmarkGoBackend7276(b *testing.B) {
+	benchmarkGoBackend(b, 7276)
+}
+
+func BenchmarkGoBackend8192(b *testing.B) {
+	benchmarkGoBackend(b, 8192)
+}
+
+func benchmarkGoBackend(b *testing.B, n int) {
+	var backend fft.GoBackend
+	in := backend.AllocReals(n)
+	out := backend.AllocComplexes(n/2 + 1)
+	plan := backend.PlanR2C(n, 1, in, 1, n, out, 1, n/2+1, fft.Planning{})
+	for i := 0; i < b.N; i++ {
+		plan.Execute()
+	}
+}
@@ -0,0 +1,228 @@
+package fft
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog/log"
+)
+
+// a batch of transforms and the layout of its buffers, the same parameters as fftw_plan_many_dft_r2c (or c2r when
+// Inverse is set, where the input layout describes the complexes)
+type PlanKey struct {
+	// transform size
+	N int
+	// transforms per execute, for FFTByFrame this is the channel count
+	HowMany int
+
+	IStride, IDist int
+	OStride, ODist int
+
+	// float32 buffers & PlanR2C32 instead of float64 & PlanR2C
+	Single bool
+	// complex-to-real (PlanC2R), reading Complexes and writing Reals
+	Inverse bool
+
+	Planning Planning
+}
+
+// length of the real buffer the layout needs, each transform has N reals
+func (k PlanKey) realLen() int {
+	if k.Inverse {
+		return (k.HowMany-1)*k.ODist + (k.N-1)*k.OStride + 1
+	}
+
+	return (k.HowMany-1)*k.IDist + (k.N-1)*k.IStride + 1
+}
+
+// length of the complex buffer the layout needs, each transform has (N / 2) + 1 complexes
+func (k PlanKey) complexLen() int {
+	if k.Inverse {
+		return (k.HowMany-1)*k.IDist + (k.N/2)*k.IStride + 1
+	}
+
+	return (k.HowMany-1)*k.ODist + (k.N/2)*k.OStride + 1
+}
+
+// plans with their buffers, kept between analyzers so that analyzing a playlist (or a batch of files) with the same
+// settings only allocates and plans once. Set Options.Cache to use one
+//
+// a plan is only handed out again once every reference to it has been released, so holders never share buffers
+type PlanCache struct {
+	// released plans kept for reuse, beyond this the least recently released are freed. 0 keeps none
+	MaxIdle int
+
+	mutex sync.Mutex
+	idle  []*CachedPlan
+}
+
+func NewPlanCache(maxIdle int) *PlanCache {
+	return &PlanCache{MaxIdle: maxIdle}
+}
+
+// returns an idle plan matching key and backend, or plans a new one. The result holds one reference, which must be
+// released. A nil cache always plans a new one, which is freed on release
+func (c *PlanCache) Acquire(backend Backend, key PlanKey) (p *CachedPlan) {
+	if c != nil {
+		c.mutex.Lock()
+		for i, idle := range c.idle {
+			if idle.key == key && idle.backend.Name() == backend.Name() {
+				c.idle = append(c.idle[:i], c.idle[i+1:]...)
+				p = idle
+				break
+			}
+		}
+		c.mutex.Unlock()
+	}
+
+	if p == nil {
+		p = newCachedPlan(backend, key)
+		p.cache = c
+	} else {
+		log.Debug().Int("n", key.N).Int("howmany", key.HowMany).Msg("reusing cached plan")
+	}
+
+	p.refs = 1
+	return
+}
+
+// number of released plans waiting to be reused, always 0 for a nil cache
+func (c *PlanCache) Idle() int {
+	if c == nil {
+		return 0
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return len(c.idle)
+}
+
+// frees every idle plan, plans still held are freed when they are released (if the cache does not keep them)
+func (c *PlanCache) Purge() {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	idle := c.idle
+	c.idle = nil
+	c.mutex.Unlock()
+
+	for _, p := range idle {
+		p.free()
+	}
+}
+
+func (c *PlanCache) put(p *CachedPlan) {
+	if c == nil {
+		p.free()
+		return
+	}
+
+	c.mutex.Lock()
+	c.idle = append(c.idle, p)
+	var evicted []*CachedPlan
+	if over := len(c.idle) - c.MaxIdle; over > 0 {
+		evicted = append(evicted, c.idle[:over]...)
+		c.idle = append([]*CachedPlan(nil), c.idle[over:]...)
+	}
+	c.mutex.Unlock()
+
+	for _, e := range evicted {
+		e.free()
+	}
+}
+
+// a plan bound to buffers allocated by its backend. Forward plans read Reals and write Complexes, inverse plans the
+// other way around. Only the pair matching PlanKey.Single is set, and the contents are whatever the previous holder left
+// there
+type CachedPlan struct {
+	Reals       []float64
+	Complexes   []complex128
+	Reals32     []float32
+	Complexes32 []complex64
+
+	plan    Plan
+	backend Backend
+	key     PlanKey
+	cache   *PlanCache
+	refs    int32
+}
+
+func newCachedPlan(backend Backend, key PlanKey) (p *CachedPlan) {
+	log.Info().
+		Str("backend", backend.Name()).
+		Int("n", key.N).
+		Int("howmany", key.HowMany).
+		Bool("single", key.Single).
+		Bool("inverse", key.Inverse).
+		Msg("allocating buffers & planning")
+
+	p = &CachedPlan{backend: backend, key: key}
+	if key.Single {
+		p.Reals32 = backend.AllocFloats(key.realLen())
+		p.Complexes32 = backend.AllocFloatComplexes(key.complexLen())
+	} else {
+		p.Reals = backend.AllocReals(key.realLen())
+		p.Complexes = backend.AllocComplexes(key.complexLen())
+	}
+
+	switch {
+	case key.Single && key.Inverse:
+		p.plan = backend.PlanC2R32(key.N, key.HowMany, p.Complexes32, key.IStride, key.IDist, p.Reals32, key.OStride, key.ODist, key.Planning)
+	case key.Single:
+		p.plan = backend.PlanR2C32(key.N, key.HowMany, p.Reals32, key.IStride, key.IDist, p.Complexes32, key.OStride, key.ODist, key.Planning)
+	case key.Inverse:
+		p.plan = backend.PlanC2R(key.N, key.HowMany, p.Complexes, key.IStride, key.IDist, p.Reals, key.OStride, key.ODist, key.Planning)
+	default:
+		p.plan = backend.PlanR2C(key.N, key.HowMany, p.Reals, key.IStride, key.IDist, p.Complexes, key.OStride, key.ODist, key.Planning)
+	}
+
+	return
+}
+
+func (p *CachedPlan) Key() PlanKey {
+	return p.key
+}
+
+func (p *CachedPlan) Execute() {
+	p.plan.Execute()
+}
+
+// see Plan.ExecuteOn, buffers from the same backend as the plan can be used by several goroutines sharing it
+func (p *CachedPlan) ExecuteOn(in, out interface{}) {
+	p.plan.ExecuteOn(in, out)
+}
+
+func (p *CachedPlan) Backend() Backend {
+	return p.backend
+}
+
+// adds a reference, for handing the plan to something which will release it separately
+func (p *CachedPlan) Retain() {
+	if atomic.AddInt32(&p.refs, 1) <= 1 {
+		panic("retain of released plan")
+	}
+}
+
+// drops a reference, the last one returns the plan to its cache (or frees it)
+func (p *CachedPlan) Release() {
+	switch refs := atomic.AddInt32(&p.refs, -1); {
+	case refs == 0:
+		p.cache.put(p)
+	case refs < 0:
+		panic("plan released too many times")
+	}
+}
+
+func (p *CachedPlan) free() {
+	p.plan.Close()
+	if p.key.Single {
+		p.backend.Free(p.Reals32)
+		p.backend.Free(p.Complexes32)
+	} else {
+		p.backend.Free(p.Reals)
+		p.backend.Free(p.Complexes)
+	}
+}
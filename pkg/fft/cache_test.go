@@ -0,0 +1,139 @@
+package fft_test
+
+import (
+	"testing"
+
+	"github.com/Twister915/vis.go/pkg/fft"
+)
+
+func frameKey(n, channels int) fft.PlanKey {
+	return fft.PlanKey{N: n, HowMany: channels, IStride: channels, IDist: 1, OStride: 1, ODist: n/2 + 1}
+}
+
+func TestPlanCacheReuse(t *testing.T) {
+	cache := fft.NewPlanCache(2)
+	defer cache.Purge()
+
+	backend := fft.DefaultBackend
+	a := cache.Acquire(backend, frameKey(256, 2))
+	if len(a.Reals) != 512 || len(a.Complexes) != 2*129 {
+		t.Fatalf("buffer sizes %d, %d", len(a.Reals), len(a.Complexes))
+	}
+
+	// held plans are never handed out twice
+	b := cache.Acquire(backend, frameKey(256, 2))
+	if &a.Reals[0] == &b.Reals[0] {
+		t.Fatal("same buffers handed to two holders")
+	}
+
+	a.Release()
+	b.Release()
+	if idle := cache.Idle(); idle != 2 {
+		t.Fatalf("expected 2 idle plans, got %d", idle)
+	}
+
+	c := cache.Acquire(backend, frameKey(256, 2))
+	if &c.Reals[0] != &a.Reals[0] && &c.Reals[0] != &b.Reals[0] {
+		t.Fatal("released plan was not reused")
+	}
+
+	// a retained plan stays out of the cache until the last release
+	c.Retain()
+	c.Release()
+	if idle := cache.Idle(); idle != 1 {
+		t.Fatalf("expected 1 idle plan while retained, got %d", idle)
+	}
+
+	c.Release()
+	if idle := cache.Idle(); idle != 2 {
+		t.Fatalf("expected 2 idle plans, got %d", idle)
+	}
+}
+
+func TestPlanCacheKeys(t *testing.T) {
+	cache := fft.NewPlanCache(4)
+	defer cache.Purge()
+
+	backend := fft.DefaultBackend
+	a := cache.Acquire(backend, frameKey(256, 2))
+	a.Release()
+
+	single := frameKey(256, 2)
+	single.Single = true
+	for _, key := range []fft.PlanKey{frameKey(512, 2), frameKey(256, 1), single} {
+		p := cache.Acquire(backend, key)
+		if p.Key() != key {
+			t.Fatalf("got plan for %+v, expected %+v", p.Key(), key)
+		}
+
+		if key.Single && (p.Reals32 == nil || p.Reals != nil) {
+			t.Fatal("single precision plan has the wrong buffers")
+		}
+
+		p.Release()
+	}
+
+	if idle := cache.Idle(); idle != 4 {
+		t.Fatalf("expected 4 idle plans, got %d", idle)
+	}
+}
+
+func TestPlanCacheEviction(t *testing.T) {
+	cache := fft.NewPlanCache(1)
+	defer cache.Purge()
+
+	backend := fft.DefaultBackend
+	a := cache.Acquire(backend, frameKey(64, 1))
+	b := cache.Acquire(backend, frameKey(128, 1))
+	a.Release()
+	b.Release()
+
+	if idle := cache.Idle(); idle != 1 {
+		t.Fatalf("expected 1 idle plan, got %d", idle)
+	}
+
+	// the most recently released one is kept
+	p := cache.Acquire(backend, frameKey(128, 1))
+	if &p.Reals[0] != &b.Reals[0] {
+		t.Fatal("expected the most recently released plan")
+	}
+
+	p.Release()
+	cache.Purge()
+	if idle := cache.Idle(); idle != 0 {
+		t.Fatalf("expected no idle plans after purge, got %d", idle)
+	}
+}
+
+func TestNilPlanCache(t *testing.T) {
+	var cache *fft.PlanCache
+	p := cache.Acquire(fft.DefaultBackend, frameKey(16, 1))
+	p.Release()
+
+	cache.Purge()
+	if idle := cache.Idle(); idle != 0 {
+		t.Fatalf("expected a nil cache to have no idle plans, got %d", idle)
+	}
+}
+
+func TestCachedPlanExecute(t *testing.T) {
+	const n = 64
+	p := (*fft.PlanCache)(nil).Acquire(fft.DefaultBackend, frameKey(n, 1))
+	defer p.Release()
+
+	samples := make([]float64, n)
+	for i := range samples {
+		samples[i] = float64(i%7) - 3
+	}
+
+	copy(p.Reals, samples)
+	p.Execute()
+
+	expected := naiveDFT(samples)
+	for k, v := range expected {
+		d := p.Complexes[k] - v
+		if real(d)*real(d)+imag(d)*imag(d) > 1e-18 {
+			t.Fatalf("bin %d = %v, expected %v", k, p.Complexes[k], v)
+		}
+	}
+}
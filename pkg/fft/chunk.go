@@ -0,0 +1,37 @@
+package fft
+
+import "github.com/Twister915/vis.go/pkg/util"
+
+// a batch of consecutive frames from a chunked analysis
+type Chunk struct {
+	// index of the first frame in Data
+	First int
+
+	// indexed [frame][bin][channel], the same as ComputeAll
+	Data [][][]float64
+
+	// set on the last chunk sent by StreamChunks if the analysis failed, Data is nil then
+	Err error
+}
+
+// runs compute (a ComputeChunked method) and sends every chunk to the channel, closing it at the end. Each chunk sent
+// has its own copy of the data so the receiver can keep it, memory use is bounded by the channel's capacity
+func streamChunks(compute func(int, func(Chunk) error) error, chunkFrames int, to chan<- Chunk) {
+	defer close(to)
+
+	err := compute(chunkFrames, func(c Chunk) error {
+		data := util.CreateNDFloat64(len(c.Data), len(c.Data[0]), len(c.Data[0][0])).([][][]float64)
+		for i, frame := range c.Data {
+			for j, bin := range frame {
+				copy(data[i][j], bin)
+			}
+		}
+
+		to <- Chunk{First: c.First, Data: data}
+		return nil
+	})
+
+	if err != nil {
+		to <- Chunk{Err: err}
+	}
+}
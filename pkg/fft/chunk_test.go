@@ -0,0 +1,131 @@
+package fft_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/fft"
+)
+
+const chunkTestRate = 1000
+
+func chunkTestAnalyzers(samples [][]float64) (*fft.FFTComputeAll, *fft.FFTByFrame) {
+	opts := fft.Options{Scaling: fft.Power, RemoveDC: true}
+	all := fft.NewFFTComputeAllOptions(newMemoryInput(chunkTestRate, samples), time.Millisecond*64, time.Millisecond*16, fft.BlackmanNuttallWindow, opts)
+	byFrame := fft.NewFFTByFrameOptions(newMemoryInput(chunkTestRate, samples), time.Millisecond*64, time.Millisecond*16, fft.BlackmanNuttallWindow, opts)
+	return all, byFrame
+}
+
+func TestComputeChunkedMatchesComputeAll(t *testing.T) {
+	samples := stereoTestSignal(1000)
+	computeAll, _ := chunkTestAnalyzers(samples)
+	expected, err := computeAll.ComputeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, chunkFrames := range []int{1, 7, len(expected), len(expected) + 10} {
+		computeAll, byFrame := chunkTestAnalyzers(samples)
+		for name, compute := range map[string]func(int, func(fft.Chunk) error) error{
+			"FFTComputeAll": computeAll.ComputeChunked,
+			"FFTByFrame":    byFrame.ComputeChunked,
+		} {
+			next := 0
+			err := compute(chunkFrames, func(c fft.Chunk) error {
+				if c.First != next {
+					t.Fatalf("%s: chunk starts at %d, expected %d", name, c.First, next)
+				}
+
+				if len(c.Data) > chunkFrames {
+					t.Fatalf("%s: chunk of %d frames, expected at most %d", name, len(c.Data), chunkFrames)
+				}
+
+				for fI, frame := range c.Data {
+					if c.First+fI >= len(expected) {
+						// FFTByFrame keeps going while a whole frame is left, FFTComputeAll stops one frame earlier
+						continue
+					}
+
+					for i := range expected[c.First+fI] {
+						for ch, want := range expected[c.First+fI][i] {
+							if !closeTo(frame[i][ch], want, 1e-9) {
+								t.Fatalf("%s chunk size %d: frame %d bin %d: %v != %v", name, chunkFrames, c.First+fI, i, frame[i][ch], want)
+							}
+						}
+					}
+				}
+
+				next += len(c.Data)
+				return nil
+			})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if next < len(expected) {
+				t.Fatalf("%s: only %d of %d frames", name, next, len(expected))
+			}
+		}
+
+		byFrame.Close()
+	}
+}
+
+func TestComputeChunkedStops(t *testing.T) {
+	computeAll, byFrame := chunkTestAnalyzers(stereoTestSignal(1000))
+	defer byFrame.Close()
+
+	stop := errors.New("stop")
+	for _, compute := range []func(int, func(fft.Chunk) error) error{computeAll.ComputeChunked, byFrame.ComputeChunked} {
+		calls := 0
+		err := compute(4, func(fft.Chunk) error {
+			calls++
+			return stop
+		})
+
+		if err != stop || calls != 1 {
+			t.Fatalf("expected one call and the callback's error, got %d calls and %v", calls, err)
+		}
+	}
+}
+
+func TestStreamChunks(t *testing.T) {
+	samples := stereoTestSignal(1000)
+	computeAll, byFrame := chunkTestAnalyzers(samples)
+	defer byFrame.Close()
+	expected, err := computeAll.ComputeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	computeAll, _ = chunkTestAnalyzers(samples)
+	to := make(chan fft.Chunk, 2)
+	go computeAll.StreamChunks(5, to)
+
+	var chunks []fft.Chunk
+	for c := range to {
+		if c.Err != nil {
+			t.Fatal(c.Err)
+		}
+
+		chunks = append(chunks, c)
+	}
+
+	// every chunk received keeps its own data
+	frames := 0
+	for _, c := range chunks {
+		for fI, frame := range c.Data {
+			if frame[3][1] != expected[c.First+fI][3][1] {
+				t.Fatalf("frame %d changed after it was sent", c.First+fI)
+			}
+		}
+
+		frames += len(c.Data)
+	}
+
+	if frames != len(expected) {
+		t.Fatalf("streamed %d frames, expected %d", frames, len(expected))
+	}
+}
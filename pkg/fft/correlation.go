@@ -0,0 +1,85 @@
+package fft
+
+import "math/cmplx"
+
+// cross-correlation of a short block with a longer one through the FFT, r[τ] = Σ a[j] b[j + τ] for every lag at which a
+// still fits inside b. Both are zero padded to a power of two at least as long as b, which is enough for the lags kept
+// not to wrap around. YIN's difference function is built on this (see pkg/pitch)
+type Correlator struct {
+	short, long int
+	n           int
+
+	// forward transforms a and b together, the inverse transforms their cross spectrum
+	forward, inverse *CachedPlan
+}
+
+func NewCorrelator(short, long int, opts Options) *Correlator {
+	n := NextPower2(long)
+	bins := (n / 2) + 1
+	return &Correlator{
+		short: short,
+		long:  long,
+		n:     n,
+		forward: opts.Cache.Acquire(opts.backend(), PlanKey{
+			N:        n,
+			HowMany:  2,
+			IStride:  1,
+			IDist:    n,
+			OStride:  1,
+			ODist:    bins,
+			Planning: opts.Planning,
+		}),
+		inverse: opts.Cache.Acquire(opts.backend(), PlanKey{
+			N:        n,
+			HowMany:  1,
+			IStride:  1,
+			IDist:    bins,
+			OStride:  1,
+			ODist:    n,
+			Inverse:  true,
+			Planning: opts.Planning,
+		}),
+	}
+}
+
+// the number of lags, long - short + 1
+func (c *Correlator) Lags() int {
+	return c.long - c.short + 1
+}
+
+// a is short samples long and b long samples, r gets up to Lags values
+func (c *Correlator) Correlate(a, b, r []float64) {
+	reals := c.forward.Reals
+	for i := range reals {
+		reals[i] = 0
+	}
+
+	copy(reals[:c.short], a[:c.short])
+	copy(reals[c.n:c.n+c.long], b[:c.long])
+	c.forward.Execute()
+
+	// Σ a[j] b[j + τ] is the inverse transform of conj(A) B
+	bins := (c.n / 2) + 1
+	spectra := c.forward.Complexes
+	for k := 0; k < bins; k++ {
+		c.inverse.Complexes[k] = cmplx.Conj(spectra[k]) * spectra[bins+k]
+	}
+
+	c.inverse.Execute()
+
+	// the inverse is not normalized
+	scale := 1 / float64(c.n)
+	for lag := range r {
+		if lag >= c.Lags() {
+			break
+		}
+
+		r[lag] = c.inverse.Reals[lag] * scale
+	}
+}
+
+// releases the plans
+func (c *Correlator) Close() {
+	c.forward.Release()
+	c.inverse.Release()
+}
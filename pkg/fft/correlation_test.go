@@ -0,0 +1,40 @@
+package fft_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/Twister915/vis.go/pkg/fft"
+)
+
+func TestCorrelatorMatchesDirect(t *testing.T) {
+	random := rand.New(rand.NewSource(7))
+	for _, sizes := range [][2]int{{1, 1}, {5, 12}, {100, 100}, {300, 777}, {512, 1024}} {
+		short, long := sizes[0], sizes[1]
+		a, b := make([]float64, short), make([]float64, long)
+		for i := range a {
+			a[i] = random.NormFloat64()
+		}
+
+		for i := range b {
+			b[i] = random.NormFloat64()
+		}
+
+		c := fft.NewCorrelator(short, long, fft.Options{})
+		r := make([]float64, c.Lags())
+		c.Correlate(a, b, r)
+		c.Close()
+
+		for lag, v := range r {
+			var expected float64
+			for j := range a {
+				expected += a[j] * b[j+lag]
+			}
+
+			if math.Abs(v-expected) > 1e-9*float64(long) {
+				t.Fatalf("%v, lag %d: %v, expected %v", sizes, lag, v, expected)
+			}
+		}
+	}
+}
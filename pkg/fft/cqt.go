@@ -0,0 +1,295 @@
+package fft
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/audio"
+	"github.com/rs/zerolog/log"
+)
+
+// settings for a constant-Q transform, where bin k is centered on MinFreq * 2^(k / BinsPerOctave) and every bin is the
+// same number of periods long, so low bins get long windows (fine frequency resolution) and high bins short ones
+type ConstantQConfig struct {
+	MinFreq       float64
+	BinsPerOctave int
+	// 0 means as many as fit below the Nyquist frequency
+	Bins int
+
+	// applied to each bin's kernel, nil means HannWindow
+	Window WindowingFunction
+
+	// kernel values smaller than this fraction of their bin's largest are dropped, 0 means 1e-3
+	Sparsity float64
+}
+
+const defaultCQTSparsity = 1e-3
+
+// the spectral kernels of a constant-Q transform (Brown & Puckette, 1992). Each bin's kernel is a windowed complex
+// sinusoid centered in a frame of FFTSize samples, and its transform is mostly zero, so a constant-Q bin is a short sum
+// over the bins of one ordinary transform of the frame
+type ConstantQKernel struct {
+	fftSize     int
+	frequencies []float64
+	bins        []cqtBin
+}
+
+// the non-zero part of one kernel's spectrum, scaled so a sinusoid's amplitude comes out as the bin's magnitude
+type cqtBin struct {
+	first  int
+	values []complex128
+}
+
+// returns an error when cfg does not describe at least one bin below the Nyquist frequency
+func NewConstantQKernel(sampleRate int, cfg ConstantQConfig) (out *ConstantQKernel, err error) {
+	fs := float64(sampleRate)
+	switch {
+	case sampleRate <= 0:
+		err = fmt.Errorf("sample rate %d must be positive", sampleRate)
+	case cfg.BinsPerOctave <= 0:
+		err = fmt.Errorf("%d bins per octave must be positive", cfg.BinsPerOctave)
+	case cfg.MinFreq <= 0 || cfg.MinFreq >= fs/2:
+		err = fmt.Errorf("minimum frequency %vHz must be in (0, %v)", cfg.MinFreq, fs/2)
+	}
+
+	if err != nil {
+		return
+	}
+
+	window := cfg.Window
+	if window == nil {
+		window = HannWindow
+	}
+
+	sparsity := cfg.Sparsity
+	if sparsity <= 0 {
+		sparsity = defaultCQTSparsity
+	}
+
+	bins := cfg.Bins
+	if fit := int(float64(cfg.BinsPerOctave) * math.Log2(fs/2/cfg.MinFreq)); bins <= 0 || bins > fit {
+		bins = fit
+	}
+
+	if bins == 0 {
+		err = fmt.Errorf("no bin fits between %vHz and %vHz at %d bins per octave", cfg.MinFreq, fs/2, cfg.BinsPerOctave)
+		return
+	}
+
+	// the ratio of each bin's frequency to its bandwidth, the same for all of them
+	q := 1 / (math.Pow(2, 1/float64(cfg.BinsPerOctave)) - 1)
+
+	out = &ConstantQKernel{
+		fftSize:     NextPower2(int(math.Ceil(q * fs / cfg.MinFreq))),
+		frequencies: make([]float64, bins),
+		bins:        make([]cqtBin, bins),
+	}
+
+	log.Info().
+		Int("bins", bins).
+		Int("binsPerOctave", cfg.BinsPerOctave).
+		Float64("q", q).
+		Int("fftSize", out.fftSize).
+		Msg("computing constant-Q kernel")
+
+	transform := newComplexFFT(out.fftSize)
+	scratch := transform.newScratch()
+	temporal := make([]complex128, out.fftSize)
+	spectral := make([]complex128, out.fftSize)
+	for k := range out.bins {
+		frequency := cfg.MinFreq * math.Pow(2, float64(k)/float64(cfg.BinsPerOctave))
+		out.frequencies[k] = frequency
+
+		length := int(math.Ceil(q * fs / frequency))
+		if length > out.fftSize {
+			length = out.fftSize
+		}
+
+		// centered in the frame, with the phase measured from the center
+		for i := range temporal {
+			temporal[i] = 0
+		}
+
+		var sum float64
+		start := (out.fftSize - length) / 2
+		center := float64(length-1) / 2
+		for i := 0; i < length; i++ {
+			w := window(float64(i), float64(length))
+			sum += w
+			temporal[start+i] = complex(w, 0) * cmplx.Rect(1, 2*math.Pi*frequency*(float64(i)-center)/fs)
+		}
+
+		transform.transform(temporal, spectral, scratch)
+
+		// Σ x k* = (1 / N) Σ X K*, and only the positive frequencies are used. A cosine of amplitude A puts A / 2 there,
+		// so the 2 makes the result A
+		scale := 2 / (sum * float64(out.fftSize))
+		positive := spectral[:out.fftSize/2+1]
+		var largest float64
+		for _, v := range positive {
+			largest = math.Max(largest, cmplx.Abs(v))
+		}
+
+		first, last := -1, -1
+		for i, v := range positive {
+			if cmplx.Abs(v) >= largest*sparsity {
+				if first < 0 {
+					first = i
+				}
+
+				last = i
+			}
+		}
+
+		b := cqtBin{first: first, values: make([]complex128, last-first+1)}
+		for i := range b.values {
+			b.values[i] = cmplx.Conj(positive[first+i]) * complex(scale, 0)
+		}
+
+		out.bins[k] = b
+	}
+
+	return
+}
+
+// the frame length the kernel applies to, frames must be this long and unwindowed (the kernels are windowed)
+func (k *ConstantQKernel) FFTSize() int {
+	return k.fftSize
+}
+
+// the center frequency of each bin in Hz
+func (k *ConstantQKernel) Frequencies() []float64 {
+	return k.frequencies
+}
+
+func (k *ConstantQKernel) Bins() int {
+	return len(k.bins)
+}
+
+// computes the constant-Q bins from one transformed frame. spectrum is indexed [bin][channel] with (FFTSize / 2) + 1
+// bins (like FFTByFrame.ComputeComplex), and dst is indexed [constant-Q bin][channel]
+func (k *ConstantQKernel) Apply(spectrum [][]complex128, dst [][]complex128) {
+	for i, b := range k.bins {
+		for ch := range dst[i] {
+			var sum complex128
+			for j, v := range b.values {
+				sum += spectrum[b.first+j][ch] * v
+			}
+
+			dst[i][ch] = sum
+		}
+	}
+}
+
+// a constant-Q analyzer with the same interface as FFTByFrame, the bins are spaced like musical notes instead of
+// linearly. Frames are FFTSize samples long (long enough for the lowest bin) and start every windowMove, and every bin
+// refers to the frame's center. The errors are NewConstantQKernel's
+func NewConstantQ(input audio.Input, windowMove time.Duration, cfg ConstantQConfig, opts Options) (out *ConstantQ, err error) {
+	var kernel *ConstantQKernel
+	if kernel, err = NewConstantQKernel(input.SampleRate(), cfg); err != nil {
+		return
+	}
+
+	// the kernel fixes the frame, and does the windowing
+	opts.PowTwo = false
+	opts.Sizing = ExactSize
+	opts.FFTSize = 0
+
+	out = &ConstantQ{
+		kernel:  kernel,
+		scaling: opts.Scaling,
+		frames:  NewFFTByFrameOptions(input, time.Duration(kernel.FFTSize())*input.Timebase(), windowMove, NoWindow, opts),
+	}
+
+	out.spectrum = make([][]complex128, out.frames.NumberOutputFrequencies())
+	spectrumN := make([]complex128, len(out.spectrum)*input.Channels())
+	for i := range out.spectrum {
+		out.spectrum[i], spectrumN = spectrumN[:input.Channels()], spectrumN[input.Channels():]
+	}
+
+	out.bins = make([][]complex128, kernel.Bins())
+	binsN := make([]complex128, kernel.Bins()*input.Channels())
+	for i := range out.bins {
+		out.bins[i], binsN = binsN[:input.Channels()], binsN[input.Channels():]
+	}
+
+	return
+}
+
+type ConstantQ struct {
+	kernel  *ConstantQKernel
+	frames  *FFTByFrame
+	scaling Scaling
+
+	spectrum [][]complex128
+	bins     [][]complex128
+}
+
+func (c *ConstantQ) HasNext() bool {
+	return c.frames.HasNext()
+}
+
+// dst is indexed [bin][channel]. With Magnitude scaling a sinusoid on a bin's frequency comes out as its amplitude,
+// Power is that squared and DBFS in dB relative to a full scale sinusoid. PSD is treated as Power, the bins have no
+// common bandwidth to divide by
+func (c *ConstantQ) Compute(dst [][]float64) (err error) {
+	if err = c.ComputeComplex(c.bins); err != nil {
+		return
+	}
+
+	for i, bin := range c.bins {
+		for ch, v := range bin {
+			amplitude := cmplx.Abs(v)
+			switch c.scaling {
+			case Power, PSD:
+				dst[i][ch] = amplitude * amplitude
+			case DBFS:
+				dst[i][ch] = math.Max(20*math.Log10(amplitude), MinDBFS)
+			default:
+				dst[i][ch] = amplitude
+			}
+		}
+	}
+
+	return
+}
+
+// like Compute, but keeps the complex values (indexed [bin][channel]), with the phase measured at the frame's center
+func (c *ConstantQ) ComputeComplex(dst [][]complex128) (err error) {
+	if err = c.frames.ComputeComplex(c.spectrum); err != nil {
+		return
+	}
+
+	c.kernel.Apply(c.spectrum, dst)
+	return
+}
+
+// the number of constant-Q bins
+func (c *ConstantQ) NumberOutputFrequencies() int {
+	return c.kernel.Bins()
+}
+
+// the center frequency of each bin in Hz
+func (c *ConstantQ) Frequencies() []float64 {
+	return c.kernel.Frequencies()
+}
+
+func (c *ConstantQ) Kernel() *ConstantQKernel {
+	return c.kernel
+}
+
+// samples in each frame, the kernel's FFTSize
+func (c *ConstantQ) FrameSize() int {
+	return c.frames.FrameSize()
+}
+
+// samples between the starts of two frames
+func (c *ConstantQ) Hop() int {
+	return c.frames.Hop()
+}
+
+func (c *ConstantQ) Close() error {
+	return c.frames.Close()
+}
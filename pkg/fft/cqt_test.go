@@ -0,0 +1,107 @@
+package fft_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/fft"
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+func TestConstantQPeaks(t *testing.T) {
+	const sampleRate, amplitude = 8000, 0.5
+	cfg := fft.ConstantQConfig{MinFreq: 100, BinsPerOctave: 12, Bins: 48}
+	for _, k := range []int{0, 7, 24, 47} {
+		kernel, err := fft.NewConstantQKernel(sampleRate, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		frequency := kernel.Frequencies()[k]
+
+		samples := util.Create2DFloats(sampleRate, 1)
+		for i := range samples {
+			samples[i][0] = amplitude * math.Cos(2*math.Pi*frequency*float64(i)/sampleRate)
+		}
+
+		for _, scaling := range []fft.Scaling{fft.Magnitude, fft.DBFS} {
+			cqt, err := fft.NewConstantQ(newMemoryInput(sampleRate, samples), time.Millisecond*100, cfg, fft.Options{Scaling: scaling})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cqt.FrameSize() != kernel.FFTSize() || cqt.NumberOutputFrequencies() != 48 {
+				t.Fatalf("frame size %d, %d bins", cqt.FrameSize(), cqt.NumberOutputFrequencies())
+			}
+
+			out := util.Create2DFloats(cqt.NumberOutputFrequencies(), 1)
+			frames := 0
+			for cqt.HasNext() {
+				if err := cqt.Compute(out); err != nil {
+					t.Fatal(err)
+				}
+
+				peak := 0
+				for i := range out {
+					if out[i][0] > out[peak][0] {
+						peak = i
+					}
+				}
+
+				expected := amplitude
+				if scaling == fft.DBFS {
+					expected = 20 * math.Log10(amplitude)
+				}
+
+				if peak != k || math.Abs(out[k][0]-expected) > 0.01*math.Abs(expected) {
+					t.Fatalf("%v Hz (%v): peak at bin %d with %v, expected bin %d with %v", frequency, scaling, peak, out[peak][0], k, expected)
+				}
+
+				frames++
+			}
+
+			cqt.Close()
+			if frames == 0 {
+				t.Fatal("no frames")
+			}
+		}
+	}
+}
+
+// the bins are geometrically spaced, and stop below the Nyquist frequency when Bins is 0
+func TestConstantQFrequencies(t *testing.T) {
+	kernel, err := fft.NewConstantQKernel(8000, fft.ConstantQConfig{MinFreq: 110, BinsPerOctave: 24})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frequencies := kernel.Frequencies()
+	if len(frequencies) == 0 || frequencies[len(frequencies)-1] >= 4000 {
+		t.Fatalf("%d bins, the last at %v Hz", len(frequencies), frequencies[len(frequencies)-1])
+	}
+
+	if octave := frequencies[24]; math.Abs(octave-220) > 1e-9 {
+		t.Fatalf("bin 24 is %v Hz", octave)
+	}
+}
+
+func TestConstantQInvalidConfig(t *testing.T) {
+	for _, cfg := range []fft.ConstantQConfig{
+		{MinFreq: 5000, BinsPerOctave: 12},
+		{MinFreq: 4000, BinsPerOctave: 12},
+		{MinFreq: 0, BinsPerOctave: 12},
+		{MinFreq: -10, BinsPerOctave: 12},
+		{MinFreq: 110, BinsPerOctave: 0},
+		// the next bin up is above the Nyquist frequency
+		{MinFreq: 3900, BinsPerOctave: 12},
+	} {
+		if _, err := fft.NewConstantQKernel(8000, cfg); err == nil {
+			t.Fatalf("%+v: no error", cfg)
+		}
+
+		if _, err := fft.NewConstantQ(newMemoryInput(8000, util.Create2DFloats(8000, 1)), time.Millisecond*100, cfg, fft.Options{}); err == nil {
+			t.Fatalf("%+v: no error from NewConstantQ", cfg)
+		}
+	}
+}
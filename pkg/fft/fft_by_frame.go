@@ -1,30 +1,43 @@
 package fft
 
-//#include "fftw3.h"
-import "C"
-
 import (
 	"math"
-	"runtime"
 	"time"
-	"unsafe"
 
 	"github.com/Twister915/vis.go/pkg/audio"
+	"github.com/Twister915/vis.go/pkg/util"
 	"github.com/rs/zerolog/log"
 )
 
 // given some input audio, read blocks of data
 func NewFFTByFrame(input audio.Input, windowSize time.Duration, windowMove time.Duration, window WindowingFunction, powTwo bool) *FFTByFrame {
+	return NewFFTByFrameOptions(input, windowSize, windowMove, window, Options{PowTwo: powTwo})
+}
+
+func NewFFTByFrameOptions(input audio.Input, windowSize time.Duration, windowMove time.Duration, window WindowingFunction, opts Options) *FFTByFrame {
+	return newFFTByFrame(input, windowSize, windowMove, window, opts, nil)
+}
+
+// with a shared plan the analyzer takes a reference to it, and executes it on its own buffers (see Plan.ExecuteOn)
+func newFFTByFrame(input audio.Input, windowSize time.Duration, windowMove time.Duration, window WindowingFunction, opts Options, shared *CachedPlan) *FFTByFrame {
 	out := &FFTByFrame{
 		input:      input,
 		windowSize: windowSize,
 		windowMove: windowMove,
 		window:     window,
-		powTwo:     powTwo,
+		sizing:     opts.sizing(),
+		fftSize:    opts.FFTSize,
+		backend:    opts.backend(),
+		planning:   opts.Planning,
+		cache:      opts.Cache,
+		scaling:    opts.Scaling,
+		removeDC:   opts.RemoveDC,
+		keep:       opts.KeepSamples,
+		plan:       shared,
+		ownBuffers: shared != nil,
 	}
 
 	out.initBuffer()
-	out.initPlan()
 	return out
 }
 
@@ -34,102 +47,215 @@ type FFTByFrame struct {
 	windowSize time.Duration
 	windowMove time.Duration
 	window     WindowingFunction
-	powTwo     bool
+	sizing     Sizing
+	fftSize    int
+
+	backend  Backend
+	planning Planning
+	cache    *PlanCache
+	plan     *CachedPlan
+	// set when the plan is shared, and this analyzer allocated its own frameData & resultData
+	ownBuffers bool
 
-	plan C.fftw_plan
+	scaling  Scaling
+	removeDC bool
+	scaler   scaler
 
 	at time.Duration
 
-	frameBuffer  FFTWDoubles2D
-	resultBuffer FFTWComplexes2D
+	// samples read for each frame, frameBuffer is longer when frames are zero padded
+	windowLen int
+
+	frameData    []float64
+	resultData   []complex128
+	frameBuffer  [][]float64
+	resultBuffer [][]complex128
+
+	// the last frame before windowing, when keep is set
+	keep    bool
+	samples [][]float64
 
 	windowPrecomputed []float64
 }
 
 func (f *FFTByFrame) initBuffer() {
 	channels := f.input.Channels()
-	desiredSamples := int(f.windowSize / f.input.Timebase())
-	if f.powTwo {
-		desiredSamples = NextPower2(desiredSamples)
+	f.windowLen = f.sizing.Size(int(f.windowSize / f.input.Timebase()))
+
+	desiredSamples := transformSize(f.windowLen, f.fftSize)
+	log.Info().Str("backend", f.backend.Name()).Int("samples", f.windowLen).Int("fftSize", desiredSamples).Msg("creating frame & result buffer")
+
+	if f.ownBuffers {
+		f.plan.Retain()
+		f.backend = f.plan.Backend()
+		f.frameData = f.backend.AllocReals(len(f.plan.Reals))
+		f.resultData = f.backend.AllocComplexes(len(f.plan.Complexes))
+	} else {
+		f.plan = f.cache.Acquire(f.backend, frameKey(desiredSamples, channels, f.planning))
+		f.frameData = f.plan.Reals
+		f.resultData = f.plan.Complexes
 	}
-	f.frameBuffer = Alloc2DDoubles(desiredSamples, channels)
-	f.resultBuffer = Alloc2DComplexes(channels, (desiredSamples/2)+1)
-
-	a := CheckAlignment(f.frameBuffer, f.resultBuffer)
-	log.Info().Bool("isAligned", a).Msg("alignment of frame & result buffer")
+	f.frameBuffer = util.Reshape2DFloats(desiredSamples, channels, f.frameData)
+	f.resultBuffer = util.Reshape2DComplex(channels, (desiredSamples/2)+1, f.resultData)
 
-	if !a {
-		panic("did not produce aligned arrays")
+	if f.keep {
+		f.samples = util.Create2DFloats(f.windowLen, channels)
 	}
 
-	f.windowPrecomputed = make([]float64, desiredSamples)
-	N := float64(desiredSamples)
+	f.windowPrecomputed = make([]float64, f.windowLen)
+	N := float64(f.windowLen)
 	for i := range f.windowPrecomputed {
 		f.windowPrecomputed[i] = f.window(float64(i), N)
 	}
-}
-
-func (f *FFTByFrame) initPlan() {
-	f.plan = fft_plan(
-		time.Second*2, runtime.NumCPU(),
-		len(f.frameBuffer), f.input.Channels(),
 
-		unsafe.Pointer(&f.frameBuffer[0][0]), f.input.Channels(), 1,
-		unsafe.Pointer(&f.resultBuffer[0][0]), 1, len(f.resultBuffer[0]),
-		C.FFTW_ESTIMATE|C.FFTW_DESTROY_INPUT,
-	)
+	f.scaler = newScaler(f.scaling, f.windowPrecomputed, desiredSamples, f.input.SampleRate())
 }
+
 func (f *FFTByFrame) HasNext() bool {
-	return f.input.Has(len(f.frameBuffer))
+	return f.input.Has(f.windowLen)
 }
 
-// the argument passed is a destination
+// the argument passed is a destination, indexed [bin][channel], and receives the bins scaled by Options.Scaling
 func (f *FFTByFrame) Compute(fftData [][]float64) (err error) {
+	if err = f.transformFrame(); err != nil {
+		return
+	}
+
+	// read data from the resultBuffer (which is [][]complex128 and needs to become [][]float64 stored in fftData param)
+	for cN, ch := range f.resultBuffer {
+		for i, v := range ch {
+			fftData[i][cN] = f.scaler.scale(i, v)
+		}
+	}
+
+	return
+}
+
+// like Compute, but keeps the complex bins instead of their magnitudes
+func (f *FFTByFrame) ComputeComplex(dst [][]complex128) (err error) {
+	if err = f.transformFrame(); err != nil {
+		return
+	}
+
+	for cN, ch := range f.resultBuffer {
+		for i, v := range ch {
+			dst[i][cN] = v
+		}
+	}
+
+	return
+}
+
+// like Compute, but also writes the phase of each bin (in radians, unwrapped across the bins of each channel). Either
+// destination can be nil to skip it
+func (f *FFTByFrame) ComputePolar(magnitude, phase [][]float64) (err error) {
+	if err = f.transformFrame(); err != nil {
+		return
+	}
+
+	for cN, ch := range f.resultBuffer {
+		var last, unwrapped float64
+		for i, v := range ch {
+			if magnitude != nil {
+				magnitude[i][cN] = math.Hypot(real(v), imag(v))
+			}
+
+			if phase != nil {
+				p := math.Atan2(imag(v), real(v))
+				if i == 0 {
+					unwrapped = p
+				} else {
+					unwrapped += WrapPhase(p - last)
+				}
+
+				last = p
+				phase[i][cN] = unwrapped
+			}
+		}
+	}
+
+	return
+}
+
+// reads, windows and transforms the next frame into f.resultBuffer
+func (f *FFTByFrame) transformFrame() (err error) {
 	// read samples to fill the "frame buffer" (buffer which contains sample data for this frame)
-	_, err = f.input.ReadSamples(f.frameBuffer)
+	samples := f.frameBuffer[:f.windowLen]
+	_, err = f.input.ReadSamples(samples)
 	if err != nil {
 		return
 	}
 
 	// now seek the file backwards so that we're ready to compute the next frame on the next call
-	if err = f.input.Seek(int(f.windowMove/f.input.Timebase()) - len(f.frameBuffer)); err != nil {
+	if err = f.input.Seek(int(f.windowMove/f.input.Timebase()) - f.windowLen); err != nil {
 		return
 	}
 
+	for i, frame := range f.samples {
+		copy(frame, samples[i])
+	}
+
 	// now go through the frame buffer, and apply the windowing function to the values
-	for i, frame := range f.frameBuffer {
+	for i, frame := range samples {
 		for chI, value := range frame {
 			frame[chI] = f.windowPrecomputed[i] * value
 		}
 	}
 
-	// finally, now that the data in f.frameBuffer is ready, perform the FFT on it (this plan will dump result
-	// to f.resultBuffer)
-	C.fftw_execute(f.plan)
+	// the channels are interleaved, so the mean is taken down each column
+	if f.removeDC {
+		for chI := range samples[0] {
+			sum := 0.0
+			for _, frame := range samples {
+				sum += frame[chI]
+			}
+
+			mean := sum / float64(len(samples))
+			for _, frame := range samples {
+				frame[chI] -= mean
+			}
+		}
+	}
 
-	// read data from the resultBuffer (which is [][]complex128 and needs to become [][]float64 stored in fftData param)
-	for cN, ch := range f.resultBuffer {
-		for i, v := range ch {
-			fftData[i][cN] = math.Hypot(real(v), imag(v))
+	// the padding is cleared every time, the buffers may come from a cache or be overwritten by the backend
+	for _, frame := range f.frameBuffer[f.windowLen:] {
+		for chI := range frame {
+			frame[chI] = 0
 		}
 	}
 
+	// finally, now that the data in f.frameBuffer is ready, perform the FFT on it (this plan will dump result
+	// to f.resultBuffer)
+	if f.ownBuffers {
+		f.plan.ExecuteOn(f.frameData, f.resultData)
+	} else {
+		f.plan.Execute()
+	}
+
 	return
 }
 
-func (f *FFTByFrame) Close() error {
-	defer f.resultBuffer.Free()
-	defer f.frameBuffer.Free()
-
-	func() {
-		runtime.LockOSThread()
-		defer runtime.UnlockOSThread()
+// samples are interleaved, and each channel's output is contiguous
+func frameKey(samples, channels int, planning Planning) PlanKey {
+	return PlanKey{
+		N:        samples,
+		HowMany:  channels,
+		IStride:  channels,
+		IDist:    1,
+		OStride:  1,
+		ODist:    (samples / 2) + 1,
+		Planning: planning,
+	}
+}
 
-		planMutex.Lock()
-		defer planMutex.Unlock()
+func (f *FFTByFrame) Close() error {
+	if f.ownBuffers {
+		f.backend.Free(f.frameData)
+		f.backend.Free(f.resultData)
+	}
 
-		C.fftw_destroy_plan(f.plan)
-	}()
+	// the plan's buffers go back with it
+	f.plan.Release()
 
 	return f.input.Close()
 }
@@ -161,11 +287,77 @@ func (f *FFTByFrame) ComputeAll() (all [][][]float64, err error) {
 	return
 }
 
+// like ComputeAll, but passes the frames to fn chunkFrames at a time so memory use does not depend on the length of the
+// input. Chunk.Data is reused for the next chunk. Returning an error from fn stops the analysis and returns that error
+func (f *FFTByFrame) ComputeChunked(chunkFrames int, fn func(Chunk) error) (err error) {
+	if chunkFrames <= 0 {
+		return
+	}
+
+	data := util.CreateNDFloat64(chunkFrames, f.NumberOutputFrequencies(), f.input.Channels()).([][][]float64)
+	first, count := 0, 0
+	for f.HasNext() {
+		if err = f.Compute(data[count]); err != nil {
+			return
+		}
+
+		count++
+		if count == chunkFrames {
+			if err = fn(Chunk{First: first, Data: data}); err != nil {
+				return
+			}
+
+			first += count
+			count = 0
+		}
+	}
+
+	if count > 0 {
+		err = fn(Chunk{First: first, Data: data[:count]})
+	}
+
+	return
+}
+
+// ComputeChunked to a channel, see StreamChunks
+func (f *FFTByFrame) StreamChunks(chunkFrames int, to chan<- Chunk) {
+	streamChunks(f.ComputeChunked, chunkFrames, to)
+}
+
 // for each window, this is the number of frequencies we can detect (this is the sample rate, divided by two, plus one)
 func (f *FFTByFrame) NumberOutputFrequencies() int {
 	return len(f.resultBuffer[0])
 }
 
+// samples in each frame, the window length
+func (f *FFTByFrame) FrameSize() int {
+	return f.windowLen
+}
+
+// the transform size, FrameSize plus any zero padding (see Options.FFTSize)
+func (f *FFTByFrame) FFTSize() int {
+	return len(f.frameBuffer)
+}
+
+// samples between the starts of two frames
+func (f *FFTByFrame) Hop() int {
+	return int(f.windowMove / f.input.Timebase())
+}
+
+// the samples of the last computed frame before windowing, indexed [sample][channel]. nil unless Options.KeepSamples
+// is set
+func (f *FFTByFrame) Samples() [][]float64 {
+	return f.samples
+}
+
+func (f *FFTByFrame) SampleRate() int {
+	return f.input.SampleRate()
+}
+
+func (f *FFTByFrame) Channels() int {
+	return f.input.Channels()
+}
+
 func NextPower2(i int) int {
 	return int(math.Pow(2, math.Ceil(math.Log2(float64(i)))))
 }
@@ -0,0 +1,263 @@
+package fft
+
+import (
+	"math"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/audio"
+	"github.com/Twister915/vis.go/pkg/util"
+	"github.com/rs/zerolog/log"
+)
+
+// single precision version of NewFFTByFrame, samples are read as float32 and the transform is done in float32 (fftwf
+// when using the FFTW backend)
+func NewFFTByFrame32(input audio.Input, windowSize time.Duration, windowMove time.Duration, window WindowingFunction, powTwo bool) *FFTByFrame32 {
+	return NewFFTByFrame32Options(input, windowSize, windowMove, window, Options{PowTwo: powTwo})
+}
+
+func NewFFTByFrame32Options(input audio.Input, windowSize time.Duration, windowMove time.Duration, window WindowingFunction, opts Options) *FFTByFrame32 {
+	out := &FFTByFrame32{
+		input:      input,
+		reader:     audio.To32(audio.FromInput(input)),
+		windowSize: windowSize,
+		windowMove: windowMove,
+		window:     window,
+		sizing:     opts.sizing(),
+		fftSize:    opts.FFTSize,
+		backend:    opts.backend(),
+		planning:   opts.Planning,
+		cache:      opts.Cache,
+		scaling:    opts.Scaling,
+		removeDC:   opts.RemoveDC,
+		keep:       opts.KeepSamples,
+	}
+
+	out.initBuffer()
+	return out
+}
+
+type FFTByFrame32 struct {
+	input  audio.Input
+	reader audio.Reader32
+
+	windowSize time.Duration
+	windowMove time.Duration
+	window     WindowingFunction
+	sizing     Sizing
+	fftSize    int
+
+	backend  Backend
+	planning Planning
+	cache    *PlanCache
+	plan     *CachedPlan
+
+	scaling  Scaling
+	removeDC bool
+	scaler   scaler
+
+	// samples read for each frame, frameBuffer is longer when frames are zero padded
+	windowLen int
+
+	frameData    []float32
+	resultData   []complex64
+	frameBuffer  [][]float32
+	resultBuffer [][]complex64
+
+	// the last frame before windowing, when keep is set
+	keep    bool
+	samples [][]float32
+
+	windowPrecomputed []float32
+}
+
+func (f *FFTByFrame32) initBuffer() {
+	channels := f.input.Channels()
+	f.windowLen = f.sizing.Size(int(f.windowSize / f.input.Timebase()))
+
+	desiredSamples := transformSize(f.windowLen, f.fftSize)
+	log.Info().Str("backend", f.backend.Name()).Int("samples", f.windowLen).Int("fftSize", desiredSamples).Msg("creating single precision frame & result buffer")
+
+	// samples are interleaved, and each channel's output is contiguous
+	f.plan = f.cache.Acquire(f.backend, PlanKey{
+		N:        desiredSamples,
+		HowMany:  channels,
+		IStride:  channels,
+		IDist:    1,
+		OStride:  1,
+		ODist:    (desiredSamples / 2) + 1,
+		Single:   true,
+		Planning: f.planning,
+	})
+	f.frameData = f.plan.Reals32
+	f.resultData = f.plan.Complexes32
+	f.frameBuffer = util.RearrangeNDTs(f.frameData, desiredSamples, channels).([][]float32)
+	f.resultBuffer = util.RearrangeNDTs(f.resultData, channels, (desiredSamples/2)+1).([][]complex64)
+
+	if f.keep {
+		f.samples = util.CreateNDTs(float32(0), f.windowLen, channels).([][]float32)
+	}
+
+	f.windowPrecomputed = make([]float32, f.windowLen)
+	window := make([]float64, f.windowLen)
+	N := float64(f.windowLen)
+	for i := range f.windowPrecomputed {
+		window[i] = f.window(float64(i), N)
+		f.windowPrecomputed[i] = float32(window[i])
+	}
+
+	f.scaler = newScaler(f.scaling, window, desiredSamples, f.input.SampleRate())
+}
+
+func (f *FFTByFrame32) HasNext() bool {
+	return f.input.Has(f.windowLen)
+}
+
+// the argument passed is a destination, indexed [bin][channel], and receives the bins scaled by Options.Scaling
+func (f *FFTByFrame32) Compute(fftData [][]float32) (err error) {
+	if err = f.transformFrame(); err != nil {
+		return
+	}
+
+	for cN, ch := range f.resultBuffer {
+		for i, v := range ch {
+			fftData[i][cN] = float32(f.scaler.scale(i, complex128(v)))
+		}
+	}
+
+	return
+}
+
+// see FFTByFrame.ComputeComplex
+func (f *FFTByFrame32) ComputeComplex(dst [][]complex64) (err error) {
+	if err = f.transformFrame(); err != nil {
+		return
+	}
+
+	for cN, ch := range f.resultBuffer {
+		for i, v := range ch {
+			dst[i][cN] = v
+		}
+	}
+
+	return
+}
+
+// see FFTByFrame.ComputePolar, the phase is unwrapped in float64 before being stored
+func (f *FFTByFrame32) ComputePolar(magnitude, phase [][]float32) (err error) {
+	if err = f.transformFrame(); err != nil {
+		return
+	}
+
+	for cN, ch := range f.resultBuffer {
+		var last, unwrapped float64
+		for i, v := range ch {
+			r, im := float64(real(v)), float64(imag(v))
+			if magnitude != nil {
+				magnitude[i][cN] = float32(math.Sqrt(r*r + im*im))
+			}
+
+			if phase != nil {
+				p := math.Atan2(im, r)
+				if i == 0 {
+					unwrapped = p
+				} else {
+					unwrapped += WrapPhase(p - last)
+				}
+
+				last = p
+				phase[i][cN] = float32(unwrapped)
+			}
+		}
+	}
+
+	return
+}
+
+func (f *FFTByFrame32) transformFrame() (err error) {
+	samples := f.frameBuffer[:f.windowLen]
+	_, err = f.reader.ReadSamplesDir32(samples, audio.ReadSampleByChannel)
+	if err != nil {
+		return
+	}
+
+	if err = f.input.Seek(int(f.windowMove/f.input.Timebase()) - f.windowLen); err != nil {
+		return
+	}
+
+	for i, frame := range f.samples {
+		copy(frame, samples[i])
+	}
+
+	for i, frame := range samples {
+		for chI, value := range frame {
+			frame[chI] = f.windowPrecomputed[i] * value
+		}
+	}
+
+	if f.removeDC {
+		for chI := range samples[0] {
+			var sum float32
+			for _, frame := range samples {
+				sum += frame[chI]
+			}
+
+			mean := sum / float32(len(samples))
+			for _, frame := range samples {
+				frame[chI] -= mean
+			}
+		}
+	}
+
+	for _, frame := range f.frameBuffer[f.windowLen:] {
+		for chI := range frame {
+			frame[chI] = 0
+		}
+	}
+
+	f.plan.Execute()
+	return
+}
+
+func (f *FFTByFrame32) Close() error {
+	// the buffers go back with the plan
+	f.plan.Release()
+
+	return f.input.Close()
+}
+
+func (f *FFTByFrame32) ComputeAll() (all [][][]float32, err error) {
+	frames := int(f.input.Length() / f.windowMove)
+	all = util.CreateNDTs(float32(0), frames, f.NumberOutputFrequencies(), f.input.Channels()).([][][]float32)
+
+	i := 0
+	for f.HasNext() {
+		if err = f.Compute(all[i]); err != nil {
+			return
+		}
+
+		i++
+	}
+
+	return
+}
+
+// for each window, this is the number of frequencies we can detect (this is the sample rate, divided by two, plus one)
+func (f *FFTByFrame32) NumberOutputFrequencies() int {
+	return len(f.resultBuffer[0])
+}
+
+// samples in each frame, the window length
+func (f *FFTByFrame32) FrameSize() int {
+	return f.windowLen
+}
+
+// the transform size, FrameSize plus any zero padding (see Options.FFTSize)
+func (f *FFTByFrame32) FFTSize() int {
+	return len(f.frameBuffer)
+}
+
+// the samples of the last computed frame before windowing, indexed [sample][channel]. nil unless Options.KeepSamples
+// is set
+func (f *FFTByFrame32) Samples() [][]float32 {
+	return f.samples
+}
@@ -0,0 +1,196 @@
+package fft_test
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/fft"
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+func stereoTestSignal(frames int) [][]float64 {
+	samples := util.Create2DFloats(frames, 2)
+	for i := range samples {
+		samples[i][0] = math.Sin(2 * math.Pi * 50 * float64(i) / 1000)
+		samples[i][1] = 0.5*math.Cos(2*math.Pi*120*float64(i)/1000) + 0.1
+	}
+
+	return samples
+}
+
+func TestComputeComplexMatchesCompute(t *testing.T) {
+	samples := stereoTestSignal(512)
+	byMagnitude := fft.NewFFTByFrame(newMemoryInput(1000, samples), time.Millisecond*128, time.Millisecond*64, fft.BlackmanNuttallWindow, false)
+	defer byMagnitude.Close()
+	byComplex := fft.NewFFTByFrame(newMemoryInput(1000, samples), time.Millisecond*128, time.Millisecond*64, fft.BlackmanNuttallWindow, false)
+	defer byComplex.Close()
+
+	bins := byMagnitude.NumberOutputFrequencies()
+	magnitudes := util.Create2DFloats(bins, 2)
+	polarMagnitudes := util.Create2DFloats(bins, 2)
+	complexes := make([][]complex128, bins)
+	for i := range complexes {
+		complexes[i] = make([]complex128, 2)
+	}
+
+	frames := 0
+	for byMagnitude.HasNext() {
+		if err := byMagnitude.Compute(magnitudes); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := byComplex.ComputeComplex(complexes); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := range magnitudes {
+			for ch := range magnitudes[i] {
+				if d := math.Abs(cmplx.Abs(complexes[i][ch]) - magnitudes[i][ch]); d > 1e-9 {
+					t.Fatalf("frame %d bin %d channel %d: |%v| != %v", frames, i, ch, complexes[i][ch], magnitudes[i][ch])
+				}
+			}
+		}
+
+		frames++
+	}
+
+	if frames == 0 {
+		t.Fatal("no frames computed")
+	}
+
+	// the polar form gives the same magnitudes
+	byPolar := fft.NewFFTByFrame(newMemoryInput(1000, samples), time.Millisecond*128, time.Millisecond*64, fft.BlackmanNuttallWindow, false)
+	defer byPolar.Close()
+	byMagnitude2 := fft.NewFFTByFrame(newMemoryInput(1000, samples), time.Millisecond*128, time.Millisecond*64, fft.BlackmanNuttallWindow, false)
+	defer byMagnitude2.Close()
+	if err := byPolar.ComputePolar(polarMagnitudes, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := byMagnitude2.Compute(magnitudes); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range magnitudes {
+		for ch := range magnitudes[i] {
+			if polarMagnitudes[i][ch] != magnitudes[i][ch] {
+				t.Fatalf("bin %d channel %d: polar magnitude %v != %v", i, ch, polarMagnitudes[i][ch], magnitudes[i][ch])
+			}
+		}
+	}
+}
+
+// an impulse delayed by d samples has the linear phase -2πkd/N, which wraps many times over the spectrum
+func TestComputePolarUnwrapsPhase(t *testing.T) {
+	const n, delay = 64, 5
+	samples := util.Create2DFloats(n, 1)
+	samples[delay][0] = 1
+
+	f := fft.NewFFTByFrame(newMemoryInput(1000, samples), time.Millisecond*n, time.Millisecond*n, fft.NoWindow, false)
+	defer f.Close()
+
+	bins := f.NumberOutputFrequencies()
+	magnitude := util.Create2DFloats(bins, 1)
+	phase := util.Create2DFloats(bins, 1)
+	if err := f.ComputePolar(magnitude, phase); err != nil {
+		t.Fatal(err)
+	}
+
+	for k := 0; k < bins; k++ {
+		if math.Abs(magnitude[k][0]-1) > 1e-9 {
+			t.Fatalf("bin %d magnitude %v, expected 1", k, magnitude[k][0])
+		}
+
+		expected := -2 * math.Pi * float64(k*delay) / n
+		if math.Abs(phase[k][0]-expected) > 1e-9 {
+			t.Fatalf("bin %d phase %v, expected %v", k, phase[k][0], expected)
+		}
+	}
+}
+
+func TestComputeComplex32(t *testing.T) {
+	samples := stereoTestSignal(256)
+	f64 := fft.NewFFTByFrame(newMemoryInput(1000, samples), time.Millisecond*128, time.Millisecond*64, fft.BlackmanNuttallWindow, false)
+	defer f64.Close()
+	f32 := fft.NewFFTByFrame32(newMemoryInput(1000, samples), time.Millisecond*128, time.Millisecond*64, fft.BlackmanNuttallWindow, false)
+	defer f32.Close()
+
+	bins := f64.NumberOutputFrequencies()
+	expected := make([][]complex128, bins)
+	got := make([][]complex64, bins)
+	for i := range expected {
+		expected[i] = make([]complex128, 2)
+		got[i] = make([]complex64, 2)
+	}
+
+	if err := f64.ComputeComplex(expected); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f32.ComputeComplex(got); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range expected {
+		for ch := range expected[i] {
+			if d := cmplx.Abs(complex128(got[i][ch]) - expected[i][ch]); d > 1e-3 {
+				t.Fatalf("bin %d channel %d: %v != %v", i, ch, got[i][ch], expected[i][ch])
+			}
+		}
+	}
+}
+
+func TestUnwrapPhase(t *testing.T) {
+	phase := make([]float64, 20)
+	for i := range phase {
+		phase[i] = fft.WrapPhase(0.9 * float64(i))
+	}
+
+	fft.UnwrapPhase(phase)
+	for i, p := range phase {
+		if math.Abs(p-0.9*float64(i)) > 1e-12 {
+			t.Fatalf("phase[%d] = %v, expected %v", i, p, 0.9*float64(i))
+		}
+	}
+
+	if p := fft.WrapPhase(-math.Pi); p != math.Pi {
+		t.Fatalf("WrapPhase(-π) = %v, expected π", p)
+	}
+}
+
+func TestKeepSamples(t *testing.T) {
+	samples := stereoTestSignal(512)
+	opts := fft.Options{KeepSamples: true, RemoveDC: true}
+	byFrame := fft.NewFFTByFrameOptions(newMemoryInput(1000, samples), time.Millisecond*128, time.Millisecond*64, fft.HannWindow, opts)
+	defer byFrame.Close()
+	byFrame32 := fft.NewFFTByFrame32Options(newMemoryInput(1000, samples), time.Millisecond*128, time.Millisecond*64, fft.HannWindow, opts)
+	defer byFrame32.Close()
+
+	if byFrame.Samples() == nil || fft.NewFFTByFrame(newMemoryInput(1000, samples), time.Millisecond*128, time.Millisecond*64, fft.HannWindow, false).Samples() != nil {
+		t.Fatal("samples are only kept with KeepSamples")
+	}
+
+	out := util.Create2DFloats(byFrame.NumberOutputFrequencies(), 2)
+	out32 := util.CreateNDTs(float32(0), byFrame32.NumberOutputFrequencies(), 2).([][]float32)
+	for frame := 0; byFrame.HasNext(); frame++ {
+		if err := byFrame.Compute(out); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := byFrame32.Compute(out32); err != nil {
+			t.Fatal(err)
+		}
+
+		// unwindowed, and with the DC still there
+		for i, s := range byFrame.Samples() {
+			for ch, v := range s {
+				expected := samples[frame*64+i][ch]
+				if math.Abs(v-expected) > 1e-4 || math.Abs(float64(byFrame32.Samples()[i][ch])-expected) > 1e-4 {
+					t.Fatalf("frame %d sample %d channel %d: %v & %v, expected %v", frame, i, ch, v, byFrame32.Samples()[i][ch], expected)
+				}
+			}
+		}
+	}
+}
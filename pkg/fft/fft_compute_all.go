@@ -1,12 +1,8 @@
 package fft
 
-//#include "fftw3.h"
-import "C"
-
 import (
 	"runtime"
 	"time"
-	"unsafe"
 
 	"github.com/Twister915/vis.go/pkg/audio"
 	"github.com/Twister915/vis.go/pkg/util"
@@ -14,13 +10,23 @@ import (
 )
 
 func NewFFTComputeAll(input audio.Input, windowSize time.Duration, windowMove time.Duration, window WindowingFunction, powTwo bool) *FFTComputeAll {
+	return NewFFTComputeAllOptions(input, windowSize, windowMove, window, Options{PowTwo: powTwo, Scaling: Power, RemoveDC: true})
+}
+
+func NewFFTComputeAllOptions(input audio.Input, windowSize time.Duration, windowMove time.Duration, window WindowingFunction, opts Options) *FFTComputeAll {
 	out := &FFTComputeAll{
 		input: input,
 
 		windowSize: windowSize,
 		windowMove: windowMove,
 		window:     window,
-		powTwo:     powTwo,
+		sizing:     opts.sizing(),
+		fftSize:    opts.FFTSize,
+		backend:    opts.backend(),
+		planning:   opts.Planning,
+		cache:      opts.Cache,
+		scaling:    opts.Scaling,
+		removeDC:   opts.RemoveDC,
 	}
 
 	out.initSizes()
@@ -33,66 +39,103 @@ type FFTComputeAll struct {
 	windowSize time.Duration
 	windowMove time.Duration
 	window     WindowingFunction
-	powTwo     bool
+	sizing     Sizing
+	fftSize    int
+	backend    Backend
+	planning   Planning
+	cache      *PlanCache
+	scaling    Scaling
+	removeDC   bool
 
-	frameCount      int
+	frameCount int
+	// samples read for each frame, fftSize is larger when frames are zero padded
 	samplesPerFrame int
+
+	windowPrecomputed []float64
+	scaler            scaler
 }
 
 func (f *FFTComputeAll) initSizes() {
-	frameSamples := int(f.windowSize / f.input.Timebase())
-	if f.powTwo {
-		frameSamples = NextPower2(frameSamples)
+	requested := int(f.windowSize / f.input.Timebase())
+	frameSamples := f.sizing.Size(requested)
+	if frameSamples != requested {
 		f.windowSize = time.Duration(frameSamples) * f.input.Timebase()
-		if f.windowMove < f.windowSize {
+		// frames have never overlapped when rounding up to a power of two
+		if f.sizing == NextPow2Size && f.windowMove < f.windowSize {
 			f.windowMove = f.windowSize
 		}
 	}
 
 	f.samplesPerFrame = frameSamples
+	f.fftSize = transformSize(frameSamples, f.fftSize)
 	f.frameCount = int((f.input.Length() - f.windowSize) / f.windowMove)
+
+	f.windowPrecomputed = make([]float64, frameSamples)
+	for i := range f.windowPrecomputed {
+		f.windowPrecomputed[i] = f.window(float64(i), float64(frameSamples))
+	}
+
+	f.scaler = newScaler(f.scaling, f.windowPrecomputed, f.fftSize, f.input.SampleRate())
 }
 
-func (f *FFTComputeAll) initPlan(frameBuffer [][][]float64, resultBuffer [][][]complex128) C.fftw_plan {
-	return fft_plan(
-		time.Second,
-		runtime.NumCPU(), f.samplesPerFrame, f.input.Channels()*f.frameCount,
-		unsafe.Pointer(&frameBuffer[0][0][0]), 1, f.samplesPerFrame,
-		unsafe.Pointer(&resultBuffer[0][0][0]), 1, f.samplesPerFrame/2,
-		C.FFTW_ESTIMATE|C.FFTW_DESTROY_INPUT,
-	)
+// each transform outputs (fftSize / 2) + 1 values
+func (f *FFTComputeAll) outputSize() int {
+	return (f.fftSize / 2) + 1
 }
 
-func (f *FFTComputeAll) closePlan(plan C.fftw_plan) {
-	runtime.LockOSThread()
-	defer runtime.UnlockOSThread()
+// samples in each frame, the window length
+func (f *FFTComputeAll) FrameSize() int {
+	return f.samplesPerFrame
+}
 
-	planMutex.Lock()
-	defer planMutex.Unlock()
+// the transform size, FrameSize plus any zero padding (see Options.FFTSize)
+func (f *FFTComputeAll) FFTSize() int {
+	return f.fftSize
+}
 
-	C.fftw_destroy_plan(plan)
+// samples between the starts of two frames
+func (f *FFTComputeAll) Hop() int {
+	return int(f.windowMove / f.input.Timebase())
+}
+
+// every channel of every frame is one contiguous transform
+func (f *FFTComputeAll) planKey(frames int) PlanKey {
+	return PlanKey{
+		N:        f.fftSize,
+		HowMany:  f.input.Channels() * frames,
+		IStride:  1,
+		IDist:    f.fftSize,
+		OStride:  1,
+		ODist:    f.outputSize(),
+		Planning: f.planning,
+	}
 }
 
 func (f *FFTComputeAll) readAudioToFrames(frameBuffer [][][]float64) (err error) {
 	// arrange the audio data into the right shape
 	back := int((f.windowSize - f.windowMove) / f.input.Timebase())
+	// the part of each channel the samples are read into, the rest is zero padding
+	samples := make([][]float64, f.input.Channels())
 	for i := range frameBuffer {
-		if _, err = f.input.ReadSamplesDir(frameBuffer[i], audio.ReadChannelBySample); err != nil {
+		for chI, ch := range frameBuffer[i] {
+			samples[chI] = ch[:f.samplesPerFrame]
+			padding := ch[f.samplesPerFrame:]
+			for j := range padding {
+				padding[j] = 0
+			}
+		}
+
+		if _, err = f.input.ReadSamplesDir(samples, audio.ReadChannelBySample); err != nil {
 			return
 		}
 
-		for _, ch := range frameBuffer[i] {
-			sum := 0.0
+		for _, ch := range samples {
 			for i, v := range ch {
-				v *= f.window(float64(i), float64(f.samplesPerFrame))
-				ch[i] = v
-				sum += v
+				ch[i] = v * f.windowPrecomputed[i]
 			}
 
-			mean := sum / float64(len(ch))
-
-			for i := range ch {
-				ch[i] = ch[i] - mean
+			if f.removeDC {
+				removeMean(ch)
 			}
 		}
 
@@ -104,14 +147,124 @@ func (f *FFTComputeAll) readAudioToFrames(frameBuffer [][][]float64) (err error)
 	return
 }
 
+// transforms the whole input at once, the output is indexed [frame][bin][channel] with FFTSize / 2 bins (the Nyquist bin
+// is left out) scaled by Options.Scaling
 func (f *FFTComputeAll) ComputeAll() (all [][][]float64, err error) {
+	err = f.transformAll(func(resultBuffer [][][]complex128) {
+		log.Info().Msg("writing output data")
+		// output data, organized by frames, then samples, then channels
+		all = util.CreateNDFloat64(f.frameCount, f.fftSize/2, f.input.Channels()).([][][]float64)
+		f.scaleFrames(resultBuffer, all)
+	})
+
+	if err == nil {
+		log.Info().Msg("computed all frames")
+	}
+
+	return
+}
+
+// like ComputeAll, but keeps all (FFTSize / 2) + 1 complex bins, indexed [frame][bin][channel]. With RemoveDC
+// (which NewFFTComputeAll sets) frames have their mean removed after windowing, so resynthesizing them (see ISTFT) loses
+// each frame's DC offset
+func (f *FFTComputeAll) ComputeAllComplex() (all [][][]complex128, err error) {
+	err = f.transformAll(func(resultBuffer [][][]complex128) {
+		all = make([][][]complex128, f.frameCount)
+		allN := make([]complex128, f.frameCount*f.outputSize()*f.input.Channels())
+		for fI, frame := range resultBuffer {
+			all[fI] = make([][]complex128, f.outputSize())
+			for i := range all[fI] {
+				all[fI][i], allN = allN[:len(frame)], allN[len(frame):]
+			}
+
+			for chI, channel := range frame {
+				for i, v := range channel {
+					all[fI][i][chI] = v
+				}
+			}
+		}
+	})
+
+	return
+}
+
+// like ComputeAll, but transforms chunkFrames frames at a time and passes each chunk to fn, so memory use depends on
+// the chunk size instead of the length of the input. Chunk.Data is reused for the next chunk. Returning an error from fn
+// stops the analysis and returns that error
+func (f *FFTComputeAll) ComputeChunked(chunkFrames int, fn func(Chunk) error) (err error) {
+	if chunkFrames > f.frameCount {
+		chunkFrames = f.frameCount
+	}
+
+	if chunkFrames <= 0 {
+		return
+	}
+
+	channels := f.input.Channels()
+	plan := f.cache.Acquire(f.backend, f.planKey(chunkFrames))
+	defer plan.Release()
+
+	// the same layout as ComputeAll, but only chunkFrames long
+	frameBuffer := util.RearrangeNDTs(plan.Reals, chunkFrames, channels, f.fftSize).([][][]float64)
+	resultBuffer := util.RearrangeNDTs(plan.Complexes, chunkFrames, channels, f.outputSize()).([][][]complex128)
+	data := util.CreateNDFloat64(chunkFrames, f.fftSize/2, channels).([][][]float64)
+
+	for first := 0; first < f.frameCount; first += chunkFrames {
+		count := f.frameCount - first
+		if count > chunkFrames {
+			count = chunkFrames
+		}
+
+		if err = f.readAudioToFrames(frameBuffer[:count]); err != nil {
+			return
+		}
+
+		// a short last chunk still runs the whole batch, the extra frames are ignored
+		plan.Execute()
+
+		f.scaleFrames(resultBuffer[:count], data)
+		if err = fn(Chunk{First: first, Data: data[:count]}); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// ComputeChunked to a channel, see StreamChunks
+func (f *FFTComputeAll) StreamChunks(chunkFrames int, to chan<- Chunk) {
+	streamChunks(f.ComputeChunked, chunkFrames, to)
+}
+
+// writes the scaled bins of resultBuffer (indexed [frame][channel][bin]) to out (indexed [frame][bin][channel])
+func (f *FFTComputeAll) scaleFrames(resultBuffer [][][]complex128, out [][][]float64) {
+	for fI, frame := range resultBuffer {
+		for chI, channel := range frame {
+			for i, v := range channel[:f.fftSize/2] {
+				// notice the indices are flipped
+				out[fI][i][chI] = f.scaler.scale(i, v)
+			}
+		}
+	}
+}
+
+// transforms every frame, and passes the results (indexed [frame][channel][bin]) to use before the buffers are
+// released
+func (f *FFTComputeAll) transformAll(use func(resultBuffer [][][]complex128)) (err error) {
 	channels := f.input.Channels()
 	log.Info().
 		Int("channels", channels).
 		Int("frames", f.frameCount).
 		Int("samplesPerFrame", f.samplesPerFrame).
+		Int("fftSize", f.fftSize).
 		Int("samplesMove", int(f.windowMove/f.input.Timebase())).
 		Msg("start compute all")
+
+	log.Info().Msg("init fft planning...")
+	plan := f.cache.Acquire(f.backend, f.planKey(f.frameCount))
+	log.Info().Msg("complete fft planning")
+	defer plan.Release()
+
 	// the data is organized like this
 	// each [][]float64 contains all the channels & samples for one FFT frame
 	// each []float64 is a channel, with a series of samples for that channel
@@ -120,53 +273,34 @@ func (f *FFTComputeAll) ComputeAll() (all [][][]float64, err error) {
 	// if you did framesBuffer[10][0][99], you would get the 100th sample on the 1st channel for the 10th frame
 	// the length of the [][][]float64 is the number of frames
 	// the length of each [][]float64 is the number of channels
-	// the length of each []float64 is the samples per frame
-	frameBuffer := Alloc3DDoubles(f.frameCount, channels, f.samplesPerFrame)
-	defer frameBuffer.Free()
+	// the length of each []float64 is the fft size, the samples per frame followed by any zero padding
+	frameData := plan.Reals
+	frameBuffer := util.RearrangeNDTs(frameData, f.frameCount, channels, f.fftSize).([][][]float64)
 	// this data is organized like this:
 	// each [][]complex128 is a frame of FFT output
 	// each []complex128 is a channel of FFT output
 	// each complex128 is a value from the FFT
 	// the earliest data is always at the lowest index
-	// also, the size of the FFT output is sampleRate / 2
+	// also, the size of the FFT output is (fftSize / 2) + 1, of which the first fftSize / 2 are used
 	// if you did resultsBuffer[11][1][199], you would get the 200th bin from the 2nd channel of the 12th frame
-	resultBuffer := Alloc3DComplexes(f.frameCount, channels, f.samplesPerFrame/2)
-	defer resultBuffer.Free()
+	resultData := plan.Complexes
+	resultBuffer := util.RearrangeNDTs(resultData, f.frameCount, channels, f.outputSize()).([][][]complex128)
 	if err = f.readAudioToFrames(frameBuffer); err != nil {
 		return
 	}
 
-	log.Info().Msg("init fft planning...")
-	plan := f.initPlan(frameBuffer, resultBuffer)
-	log.Info().Msg("complete fft planning")
-	defer f.closePlan(plan)
-
 	log.Info().
 		Int("ffts", f.input.Channels()*f.frameCount).
-		Int("size", f.samplesPerFrame).
+		Int("size", f.fftSize).
+		Str("backend", f.backend.Name()).
 		Msg("executing plan")
 
-	C.fftw_execute(plan)
+	plan.Execute()
 
 	log.Info().Msg("finished ffts, doing GC")
 
 	runtime.GC()
 
-	log.Info().Msg("writing output data")
-	// output data, organized by frames, then samples, then channels
-	all = util.CreateNDFloat64(f.frameCount, f.samplesPerFrame/2, channels).([][][]float64)
-
-	// bring all the data to the all slice
-	for fI, frame := range resultBuffer {
-		for chI, channel := range frame {
-			for i, v := range channel {
-				// notice the indices are flipped
-				r, im := real(v), imag(v)
-				all[fI][i][chI] = (r * r) + (im * im)
-			}
-		}
-	}
-
-	log.Info().Msg("computed all frames")
+	use(resultBuffer)
 	return
 }
@@ -0,0 +1,176 @@
+package fft
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/audio"
+	"github.com/Twister915/vis.go/pkg/util"
+	"github.com/rs/zerolog/log"
+)
+
+// single precision version of NewFFTComputeAll, the whole-file buffers are half the size of the float64 version
+func NewFFTComputeAll32(input audio.Input, windowSize time.Duration, windowMove time.Duration, window WindowingFunction, powTwo bool) *FFTComputeAll32 {
+	return NewFFTComputeAll32Options(input, windowSize, windowMove, window, Options{PowTwo: powTwo, Scaling: Power, RemoveDC: true})
+}
+
+func NewFFTComputeAll32Options(input audio.Input, windowSize time.Duration, windowMove time.Duration, window WindowingFunction, opts Options) *FFTComputeAll32 {
+	out := &FFTComputeAll32{
+		input:  input,
+		reader: audio.To32(audio.FromInput(input)),
+
+		windowSize: windowSize,
+		windowMove: windowMove,
+		window:     window,
+		sizing:     opts.sizing(),
+		fftSize:    opts.FFTSize,
+		backend:    opts.backend(),
+		planning:   opts.Planning,
+		cache:      opts.Cache,
+		scaling:    opts.Scaling,
+		removeDC:   opts.RemoveDC,
+	}
+
+	out.initSizes()
+	return out
+}
+
+type FFTComputeAll32 struct {
+	input  audio.Input
+	reader audio.Reader32
+
+	windowSize time.Duration
+	windowMove time.Duration
+	window     WindowingFunction
+	sizing     Sizing
+	fftSize    int
+	backend    Backend
+	planning   Planning
+	cache      *PlanCache
+	scaling    Scaling
+	removeDC   bool
+
+	frameCount      int
+	samplesPerFrame int
+
+	windowPrecomputed []float64
+	scaler            scaler
+}
+
+func (f *FFTComputeAll32) initSizes() {
+	requested := int(f.windowSize / f.input.Timebase())
+	frameSamples := f.sizing.Size(requested)
+	if frameSamples != requested {
+		f.windowSize = time.Duration(frameSamples) * f.input.Timebase()
+		// frames have never overlapped when rounding up to a power of two
+		if f.sizing == NextPow2Size && f.windowMove < f.windowSize {
+			f.windowMove = f.windowSize
+		}
+	}
+
+	f.samplesPerFrame = frameSamples
+	f.fftSize = transformSize(frameSamples, f.fftSize)
+	f.frameCount = int((f.input.Length() - f.windowSize) / f.windowMove)
+
+	f.windowPrecomputed = make([]float64, frameSamples)
+	for i := range f.windowPrecomputed {
+		f.windowPrecomputed[i] = f.window(float64(i), float64(frameSamples))
+	}
+
+	f.scaler = newScaler(f.scaling, f.windowPrecomputed, f.fftSize, f.input.SampleRate())
+}
+
+func (f *FFTComputeAll32) outputSize() int {
+	return (f.fftSize / 2) + 1
+}
+
+// every channel of every frame is one contiguous transform
+func (f *FFTComputeAll32) planKey() PlanKey {
+	return PlanKey{
+		N:        f.fftSize,
+		HowMany:  f.input.Channels() * f.frameCount,
+		IStride:  1,
+		IDist:    f.fftSize,
+		OStride:  1,
+		ODist:    f.outputSize(),
+		Single:   true,
+		Planning: f.planning,
+	}
+}
+
+func (f *FFTComputeAll32) readAudioToFrames(frameBuffer [][][]float32) (err error) {
+	back := int((f.windowSize - f.windowMove) / f.input.Timebase())
+	samples := make([][]float32, f.input.Channels())
+	for i := range frameBuffer {
+		for chI, ch := range frameBuffer[i] {
+			samples[chI] = ch[:f.samplesPerFrame]
+			padding := ch[f.samplesPerFrame:]
+			for j := range padding {
+				padding[j] = 0
+			}
+		}
+
+		if _, err = f.reader.ReadSamplesDir32(samples, audio.ReadChannelBySample); err != nil {
+			return
+		}
+
+		for _, ch := range samples {
+			for i, v := range ch {
+				ch[i] = v * float32(f.windowPrecomputed[i])
+			}
+
+			if f.removeDC {
+				removeMean32(ch)
+			}
+		}
+
+		if err = f.input.Seek(-back); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (f *FFTComputeAll32) ComputeAll() (all [][][]float32, err error) {
+	channels := f.input.Channels()
+	log.Info().
+		Int("channels", channels).
+		Int("frames", f.frameCount).
+		Int("samplesPerFrame", f.samplesPerFrame).
+		Int("fftSize", f.fftSize).
+		Int("samplesMove", int(f.windowMove/f.input.Timebase())).
+		Msg("start single precision compute all")
+
+	log.Info().Msg("init fft planning...")
+	plan := f.cache.Acquire(f.backend, f.planKey())
+	log.Info().Msg("complete fft planning")
+	defer plan.Release()
+
+	// same layout as FFTComputeAll.ComputeAll
+	frameData := plan.Reals32
+	frameBuffer := util.RearrangeNDTs(frameData, f.frameCount, channels, f.fftSize).([][][]float32)
+	resultData := plan.Complexes32
+	resultBuffer := util.RearrangeNDTs(resultData, f.frameCount, channels, f.outputSize()).([][][]complex64)
+	if err = f.readAudioToFrames(frameBuffer); err != nil {
+		return
+	}
+
+	plan.Execute()
+
+	log.Info().Msg("finished ffts, doing GC")
+
+	runtime.GC()
+
+	all = util.CreateNDTs(float32(0), f.frameCount, f.fftSize/2, channels).([][][]float32)
+	for fI, frame := range resultBuffer {
+		for chI, channel := range frame {
+			for i, v := range channel[:f.fftSize/2] {
+				all[fI][i][chI] = float32(f.scaler.scale(i, complex128(v)))
+			}
+		}
+	}
+
+	log.Info().Msg("computed all frames")
+	return
+}
@@ -0,0 +1,165 @@
+package fft
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/audio"
+	"github.com/Twister915/vis.go/pkg/util"
+	"github.com/rs/zerolog/log"
+)
+
+// opens another Input on the same audio, every worker of an FFTParallel reads from its own so they each have their own
+// cursor. For a wav on an mmap this is wav.ReadWav on a new util.MMapSeeker of the same mmap
+type InputOpener func() (audio.Input, error)
+
+// offline analysis split across workers. Each worker is an FFTByFrame with its own input and buffers, and all of them
+// execute the same plan (see Plan.ExecuteOn). Results are put back in frame order, and are the same as FFTByFrame's
+func NewFFTParallel(open InputOpener, windowSize time.Duration, windowMove time.Duration, window WindowingFunction, workers int, opts Options) (out *FFTParallel, err error) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	out = new(FFTParallel)
+	defer func() {
+		if err != nil {
+			out.Close()
+			out = nil
+		}
+	}()
+
+	for i := 0; i < workers; i++ {
+		var input audio.Input
+		if input, err = open(); err != nil {
+			return
+		}
+
+		if i == 0 {
+			out.workers = append(out.workers, NewFFTByFrameOptions(input, windowSize, windowMove, window, opts))
+		} else {
+			out.workers = append(out.workers, newFFTByFrame(input, windowSize, windowMove, window, opts, out.workers[0].plan))
+		}
+	}
+
+	first := out.workers[0]
+	out.frameSize = first.FrameSize()
+	out.hop = first.Hop()
+	if frames := first.input.Frames(); frames >= out.frameSize {
+		out.frames = (frames-out.frameSize)/out.hop + 1
+	}
+
+	log.Info().Int("workers", workers).Int("frames", out.frames).Msg("created parallel analyzer")
+	return
+}
+
+type FFTParallel struct {
+	workers []*FFTByFrame
+
+	frameSize, hop int
+	frames         int
+}
+
+// the number of frames ComputeAll returns
+func (f *FFTParallel) Frames() int {
+	return f.frames
+}
+
+func (f *FFTParallel) NumberOutputFrequencies() int {
+	return f.workers[0].NumberOutputFrequencies()
+}
+
+// every frame, indexed [frame][bin][channel] and scaled by Options.Scaling
+func (f *FFTParallel) ComputeAll() (all [][][]float64, err error) {
+	all = util.CreateNDFloat64(f.frames, f.NumberOutputFrequencies(), f.workers[0].input.Channels()).([][][]float64)
+	err = f.computeRange(0, all)
+	return
+}
+
+// see FFTByFrame.ComputeChunked, each chunk is split between the workers
+func (f *FFTParallel) ComputeChunked(chunkFrames int, fn func(Chunk) error) (err error) {
+	if chunkFrames <= 0 {
+		return
+	}
+
+	data := util.CreateNDFloat64(chunkFrames, f.NumberOutputFrequencies(), f.workers[0].input.Channels()).([][][]float64)
+	for first := 0; first < f.frames; first += chunkFrames {
+		count := f.frames - first
+		if count > chunkFrames {
+			count = chunkFrames
+		}
+
+		if err = f.computeRange(first, data[:count]); err != nil {
+			return
+		}
+
+		if err = fn(Chunk{First: first, Data: data[:count]}); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// ComputeChunked to a channel, see StreamChunks
+func (f *FFTParallel) StreamChunks(chunkFrames int, to chan<- Chunk) {
+	streamChunks(f.ComputeChunked, chunkFrames, to)
+}
+
+// computes frames [first, first + len(out)) into out, each worker takes a contiguous run of them so it only seeks once
+func (f *FFTParallel) computeRange(first int, out [][][]float64) (err error) {
+	var wg sync.WaitGroup
+	errs := make([]error, len(f.workers))
+	per := (len(out) + len(f.workers) - 1) / len(f.workers)
+	for w, worker := range f.workers {
+		start := w * per
+		if start >= len(out) {
+			break
+		}
+
+		end := start + per
+		if end > len(out) {
+			end = len(out)
+		}
+
+		wg.Add(1)
+		go func(w int, worker *FFTByFrame, start, end int) {
+			defer wg.Done()
+
+			if errs[w] = worker.input.Reset(); errs[w] != nil {
+				return
+			}
+
+			if errs[w] = worker.input.Seek((first + start) * f.hop); errs[w] != nil {
+				return
+			}
+
+			for i := start; i < end; i++ {
+				if errs[w] = worker.Compute(out[i]); errs[w] != nil {
+					return
+				}
+			}
+		}(w, worker, start, end)
+	}
+
+	wg.Wait()
+
+	for _, err = range errs {
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// closes every worker's input, and releases the plan
+func (f *FFTParallel) Close() (err error) {
+	for _, worker := range f.workers {
+		if closeErr := worker.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	return
+}
@@ -0,0 +1,133 @@
+package fft_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/audio"
+	"github.com/Twister915/vis.go/pkg/fft"
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+func TestFFTParallelMatchesFFTByFrame(t *testing.T) {
+	const sampleRate = 1000
+	samples := stereoTestSignal(3000)
+	open := func() (audio.Input, error) {
+		return newMemoryInput(sampleRate, samples), nil
+	}
+
+	opts := fft.Options{Scaling: fft.Power}
+	sequential := fft.NewFFTByFrameOptions(newMemoryInput(sampleRate, samples), time.Millisecond*100, time.Millisecond*33, fft.BlackmanNuttallWindow, opts)
+	defer sequential.Close()
+
+	var expected [][][]float64
+	for sequential.HasNext() {
+		frame := util.Create2DFloats(sequential.NumberOutputFrequencies(), 2)
+		if err := sequential.Compute(frame); err != nil {
+			t.Fatal(err)
+		}
+
+		expected = append(expected, frame)
+	}
+
+	for _, workers := range []int{1, 3, 8, 200} {
+		parallel, err := fft.NewFFTParallel(open, time.Millisecond*100, time.Millisecond*33, fft.BlackmanNuttallWindow, workers, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if parallel.Frames() != len(expected) {
+			t.Fatalf("%d workers: %d frames, expected %d", workers, parallel.Frames(), len(expected))
+		}
+
+		all, err := parallel.ComputeAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		compare := func(fI int, frame [][]float64) {
+			for i := range frame {
+				for ch := range frame[i] {
+					if !closeTo(frame[i][ch], expected[fI][i][ch], 1e-12) {
+						t.Fatalf("%d workers: frame %d bin %d channel %d: %v != %v", workers, fI, i, ch, frame[i][ch], expected[fI][i][ch])
+					}
+				}
+			}
+		}
+
+		for fI, frame := range all {
+			compare(fI, frame)
+		}
+
+		// computing again (in chunks) starts over from the beginning
+		seen := 0
+		err = parallel.ComputeChunked(10, func(c fft.Chunk) error {
+			for fI, frame := range c.Data {
+				compare(c.First+fI, frame)
+			}
+
+			seen += len(c.Data)
+			return nil
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if seen != len(expected) {
+			t.Fatalf("%d workers: chunks covered %d frames, expected %d", workers, seen, len(expected))
+		}
+
+		if err = parallel.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// several goroutines executing one plan on their own buffers get the same results as executing it sequentially
+func TestExecuteOnConcurrent(t *testing.T) {
+	const n, goroutines = 256, 8
+	for _, backend := range []fft.Backend{fft.GoBackend{}, fft.DefaultBackend} {
+		reals, complexes := backend.AllocReals(n), backend.AllocComplexes(n/2+1)
+		plan := backend.PlanR2C(n, 1, reals, 1, n, complexes, 1, n/2+1, fft.Planning{})
+
+		done := make(chan error, goroutines)
+		for g := 0; g < goroutines; g++ {
+			go func(g int) {
+				in, out := backend.AllocReals(n), backend.AllocComplexes(n/2+1)
+				defer backend.Free(in)
+				defer backend.Free(out)
+
+				for round := 0; round < 20; round++ {
+					signal := make([]float64, n)
+					for i := range signal {
+						signal[i] = float64((i*(g+1)+round)%13) - 6
+					}
+
+					copy(in, signal)
+					plan.ExecuteOn(in, out)
+
+					for k, want := range naiveDFT(signal) {
+						if d := out[k] - want; real(d)*real(d)+imag(d)*imag(d) > 1e-12 {
+							done <- fmt.Errorf("%s: wrong value in bin %d", backend.Name(), k)
+							return
+						}
+					}
+				}
+
+				done <- nil
+			}(g)
+		}
+
+		for g := 0; g < goroutines; g++ {
+			if err := <-done; err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		plan.Close()
+		backend.Free(reals)
+		backend.Free(complexes)
+	}
+}
@@ -1,3 +1,6 @@
+//go:build cgo && !purego
+// +build cgo,!purego
+
 package fft
 
 //#cgo LDFLAGS: -lfftw3 -lfftw3_threads
@@ -21,11 +24,13 @@ func init() {
 	C.fftw_init_threads()
 }
 
+// plans real-to-complex transforms, or complex-to-real ones when inverse is set (input & output swap types). Returns
+// nil if FFTW could not create the plan (or, with FFTW_WISDOM_ONLY, if the wisdom has nothing for it)
 func fft_plan(timelimit time.Duration, nthreads, n int,
 	howmany int,
 	input unsafe.Pointer, istride, idist int,
 	output unsafe.Pointer, ostride, odist int,
-	flags C.uint) C.fftw_plan {
+	inverse bool, flags C.uint) C.fftw_plan {
 
 	ns := []int{n}
 	log.Info().
@@ -40,6 +45,7 @@ func fft_plan(timelimit time.Duration, nthreads, n int,
 		Int("odist", odist).
 		Int("cpus", nthreads).
 		Str("timelimit", timelimit.String()).
+		Bool("inverse", inverse).
 		Msg("planning FFT")
 
 	runtime.LockOSThread()
@@ -56,7 +62,27 @@ func fft_plan(timelimit time.Duration, nthreads, n int,
 
 	C.fftw_plan_with_nthreads(C.int(nthreads))
 
-	plan := C.fftw_plan_many_dft_r2c(
+	if inverse {
+		return C.fftw_plan_many_dft_c2r(
+			C.int(1),
+			(*C.int)(unsafe.Pointer(&ns[0])),
+			C.int(howmany),
+
+			(*C.fftw_complex)(input),
+			nil,
+			C.int(istride),
+			C.int(idist),
+
+			(*C.double)(output),
+			nil,
+			C.int(ostride),
+			C.int(odist),
+
+			flags,
+		)
+	}
+
+	return C.fftw_plan_many_dft_r2c(
 		C.int(1),
 		(*C.int)(unsafe.Pointer(&ns[0])),
 		C.int(howmany),
@@ -73,10 +99,14 @@ func fft_plan(timelimit time.Duration, nthreads, n int,
 
 		flags,
 	)
+}
 
-	if plan == nil {
-		panic("could not construct plan")
-	}
+func fft_destroy_plan(plan C.fftw_plan) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	planMutex.Lock()
+	defer planMutex.Unlock()
 
-	return plan
+	C.fftw_destroy_plan(plan)
 }
@@ -1,3 +1,6 @@
+//go:build cgo && !purego
+// +build cgo,!purego
+
 package fft_test
 
 import (
@@ -32,3 +35,16 @@ func TestAllocFFTWComplexes(t *testing.T) {
 
 	t.Logf(spew.Sdump(complexes))
 }
+
+func TestAllocFFTWFloats(t *testing.T) {
+	floats := fft.AllocFFTWFloats(256)
+	defer floats.Free()
+
+	t.Logf("ptr => %p", &floats[0])
+
+	for i := 0; i < len(floats); i++ {
+		floats[i] = float32(i + 1)
+	}
+
+	t.Logf(spew.Sdump(floats))
+}
@@ -0,0 +1,85 @@
+//go:build cgo && !purego
+// +build cgo,!purego
+
+package fft
+
+//#include <stdlib.h>
+//#include "fftw3.h"
+import "C"
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"unsafe"
+)
+
+// loads FFTW wisdom saved by ExportWisdom, double precision wisdom is read from file and single precision wisdom from
+// file + ".f32". Files which do not exist yet are skipped
+func ImportWisdom(file string) (err error) {
+	return withWisdomFiles(file, func(double, single *C.char) error {
+		for _, f := range []struct {
+			name    *C.char
+			path    string
+			fImport func(*C.char) C.int
+		}{
+			{double, file, func(n *C.char) C.int { return C.fftw_import_wisdom_from_filename(n) }},
+			{single, file + singleWisdomSuffix, func(n *C.char) C.int { return C.fftwf_import_wisdom_from_filename(n) }},
+		} {
+			if _, err := os.Stat(f.path); os.IsNotExist(err) {
+				continue
+			}
+
+			if f.fImport(f.name) == 0 {
+				return fmt.Errorf("could not import wisdom from %s", f.path)
+			}
+		}
+
+		return nil
+	})
+}
+
+// saves the wisdom accumulated by planning (see Planning) so a later run can import it
+func ExportWisdom(file string) (err error) {
+	return withWisdomFiles(file, func(double, single *C.char) error {
+		if C.fftw_export_wisdom_to_filename(double) == 0 {
+			return fmt.Errorf("could not export wisdom to %s", file)
+		}
+
+		if C.fftwf_export_wisdom_to_filename(single) == 0 {
+			return fmt.Errorf("could not export wisdom to %s", file+singleWisdomSuffix)
+		}
+
+		return nil
+	})
+}
+
+// drops all wisdom, both loaded and accumulated
+func ForgetWisdom() {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	planMutex.Lock()
+	defer planMutex.Unlock()
+
+	C.fftw_forget_wisdom()
+	C.fftwf_forget_wisdom()
+}
+
+const singleWisdomSuffix = ".f32"
+
+// the wisdom functions share the planner's state, so they hold the same lock as planning
+func withWisdomFiles(file string, f func(double, single *C.char) error) error {
+	double := C.CString(file)
+	defer C.free(unsafe.Pointer(double))
+	single := C.CString(file + singleWisdomSuffix)
+	defer C.free(unsafe.Pointer(single))
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	planMutex.Lock()
+	defer planMutex.Unlock()
+
+	return f(double, single)
+}
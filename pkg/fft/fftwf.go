@@ -0,0 +1,107 @@
+//go:build cgo && !purego
+// +build cgo,!purego
+
+package fft
+
+//#cgo LDFLAGS: -lfftw3f -lfftw3f_threads
+//#include "fftw3.h"
+import "C"
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+	"unsafe"
+
+	"github.com/rs/zerolog/log"
+)
+
+func init() {
+	C.fftwf_init_threads()
+}
+
+// single precision version of fft_plan, the reals are float32 and the complexes complex64, returns nil on failure
+func fftf_plan(timelimit time.Duration, nthreads, n int,
+	howmany int,
+	input unsafe.Pointer, istride, idist int,
+	output unsafe.Pointer, ostride, odist int,
+	inverse bool, flags C.uint) C.fftwf_plan {
+
+	ns := []int32{int32(n)}
+	log.Info().
+		Int("rank", 1).
+		Int("n", n).
+		Int("howmany", howmany).
+		Str("in", fmt.Sprintf("%p", input)).
+		Int("istride", istride).
+		Int("idist", idist).
+		Str("out", fmt.Sprintf("%p", output)).
+		Int("ostride", ostride).
+		Int("odist", odist).
+		Int("cpus", nthreads).
+		Str("timelimit", timelimit.String()).
+		Bool("inverse", inverse).
+		Msg("planning single precision FFT")
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	planMutex.Lock()
+	defer planMutex.Unlock()
+
+	if timelimit <= 0 {
+		C.fftwf_set_timelimit(C.FFTW_NO_TIMELIMIT)
+	} else {
+		C.fftwf_set_timelimit(C.double(float64(timelimit) / float64(time.Second)))
+	}
+
+	C.fftwf_plan_with_nthreads(C.int(nthreads))
+
+	if inverse {
+		return C.fftwf_plan_many_dft_c2r(
+			C.int(1),
+			(*C.int)(unsafe.Pointer(&ns[0])),
+			C.int(howmany),
+
+			(*C.fftwf_complex)(input),
+			nil,
+			C.int(istride),
+			C.int(idist),
+
+			(*C.float)(output),
+			nil,
+			C.int(ostride),
+			C.int(odist),
+
+			flags,
+		)
+	}
+
+	return C.fftwf_plan_many_dft_r2c(
+		C.int(1),
+		(*C.int)(unsafe.Pointer(&ns[0])),
+		C.int(howmany),
+
+		(*C.float)(input),
+		nil,
+		C.int(istride),
+		C.int(idist),
+
+		(*C.fftwf_complex)(output),
+		nil,
+		C.int(ostride),
+		C.int(odist),
+
+		flags,
+	)
+}
+
+func fftf_destroy_plan(plan C.fftwf_plan) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	planMutex.Lock()
+	defer planMutex.Unlock()
+
+	C.fftwf_destroy_plan(plan)
+}
@@ -0,0 +1,86 @@
+//go:build cgo && !purego
+// +build cgo,!purego
+
+package fft
+
+//#include "fftw3.h"
+import "C"
+
+import (
+	"reflect"
+	"unsafe"
+
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+type FFTWFloats3D [][][]float32
+
+func Alloc3DFloats(x, y, z int) FFTWFloats3D {
+	backing := AllocFFTWFloats(x * y * z)
+	return FFTWFloats3D(util.RearrangeNDTs([]float32(backing), x, y, z).([][][]float32))
+}
+
+func (f FFTWFloats3D) Free() {
+	C.fftwf_free(unsafe.Pointer(&f[0][0][0]))
+}
+
+type FFTWFloatComplexes3D [][][]complex64
+
+func Alloc3DFloatComplexes(x, y, z int) FFTWFloatComplexes3D {
+	backing := AllocFFTWFloatComplexes(x * y * z)
+	return FFTWFloatComplexes3D(util.RearrangeNDTs([]complex64(backing), x, y, z).([][][]complex64))
+}
+
+func (f FFTWFloatComplexes3D) Free() {
+	C.fftwf_free(unsafe.Pointer(&f[0][0][0]))
+}
+
+type FFTWFloats2D [][]float32
+
+func Alloc2DFloats(x, y int) FFTWFloats2D {
+	backing := AllocFFTWFloats(x * y)
+	return FFTWFloats2D(util.RearrangeNDTs([]float32(backing), x, y).([][]float32))
+}
+
+func (f FFTWFloats2D) Free() {
+	C.fftwf_free(unsafe.Pointer(&f[0][0]))
+}
+
+type FFTWFloatComplexes2D [][]complex64
+
+func Alloc2DFloatComplexes(x, y int) FFTWFloatComplexes2D {
+	backing := AllocFFTWFloatComplexes(x * y)
+	return FFTWFloatComplexes2D(util.RearrangeNDTs([]complex64(backing), x, y).([][]complex64))
+}
+
+func (f FFTWFloatComplexes2D) Free() {
+	C.fftwf_free(unsafe.Pointer(&f[0][0]))
+}
+
+type FFTWFloats []float32
+
+func AllocFFTWFloats(n int) FFTWFloats {
+	return FFTWFloats(*((*[]float32)(fftwf_malloc_slice(uintptr(n)*unsafe.Sizeof(float32(0)), n))))
+}
+
+func (f FFTWFloats) Free() {
+	C.fftwf_free(unsafe.Pointer(&f[0]))
+}
+
+type FFTWFloatComplexes []complex64
+
+func AllocFFTWFloatComplexes(n int) FFTWFloatComplexes {
+	return FFTWFloatComplexes(*((*[]complex64)(fftwf_malloc_slice(uintptr(n)*unsafe.Sizeof(complex64(0)), n))))
+}
+
+func (f FFTWFloatComplexes) Free() {
+	C.fftwf_free(unsafe.Pointer(&f[0]))
+}
+
+func fftwf_malloc_slice(size uintptr, len int) unsafe.Pointer {
+	return unsafe.Pointer(&reflect.SliceHeader{
+		Len:  len,
+		Cap:  len,
+		Data: uintptr(C.fftwf_malloc(C.size_t(size))),
+	})
+}
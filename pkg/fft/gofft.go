@@ -0,0 +1,385 @@
+package fft
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// pure Go transforms used by GoBackend
+//
+// complex transforms use a recursive mixed-radix decimation in time (radix 4 and 2 butterflies, and a generic
+// butterfly for any other factor). Sizes with a prime factor larger than maxRadix are done with Bluestein's algorithm
+// instead, which turns them into a convolution of power of two transforms. Real transforms of even size are packed
+// into a complex transform of half the size.
+
+const maxRadix = 64
+
+type complexFFT struct {
+	n       int
+	factors []int
+
+	// exp(-2πik/n) for k in [0, n)
+	twiddle []complex128
+
+	// set when n has a prime factor > maxRadix, factors & twiddle are unused
+	blue *bluestein
+}
+
+// scratch space for one transform at a time, plans are shared but scratch never is
+type complexScratch struct {
+	butterfly []complex128
+	blue      *bluesteinScratch
+}
+
+func newComplexFFT(n int) *complexFFT {
+	out := &complexFFT{n: n}
+
+	factors := factorize(n)
+	for _, f := range factors {
+		if f > maxRadix {
+			out.blue = newBluestein(n)
+			return out
+		}
+	}
+
+	out.factors = factors
+	out.twiddle = make([]complex128, n)
+	for k := range out.twiddle {
+		out.twiddle[k] = cmplx.Rect(1, -2*math.Pi*float64(k)/float64(n))
+	}
+
+	return out
+}
+
+// radix 4 first (cheapest butterfly), then 2, then odd primes in increasing order. Sizes below 2 have no factors
+func factorize(n int) (factors []int) {
+	if n <= 1 {
+		return
+	}
+
+	for n%4 == 0 {
+		factors = append(factors, 4)
+		n /= 4
+	}
+
+	for n%2 == 0 {
+		factors = append(factors, 2)
+		n /= 2
+	}
+
+	for p := 3; p*p <= n; p += 2 {
+		for n%p == 0 {
+			factors = append(factors, p)
+			n /= p
+		}
+	}
+
+	if n > 1 {
+		factors = append(factors, n)
+	}
+
+	return
+}
+
+func (c *complexFFT) newScratch() *complexScratch {
+	s := new(complexScratch)
+	if c.blue != nil {
+		s.blue = c.blue.newScratch()
+		return s
+	}
+
+	largest := 1
+	for _, f := range c.factors {
+		if f > largest {
+			largest = f
+		}
+	}
+
+	s.butterfly = make([]complex128, largest)
+	return s
+}
+
+// forward transform of in (length n) to out (length n), in and out must not overlap
+func (c *complexFFT) transform(in, out []complex128, s *complexScratch) {
+	if c.blue != nil {
+		c.blue.transform(in, out, s.blue)
+		return
+	}
+
+	if c.n == 1 {
+		out[0] = in[0]
+		return
+	}
+
+	c.work(out[:c.n], in, 1, c.factors, 1, s.butterfly)
+}
+
+// computes the DFT of in[0], in[stride], in[2*stride]... into out, len(out) being the size of this sub-transform
+func (c *complexFFT) work(out, in []complex128, stride int, factors []int, twStride int, tmp []complex128) {
+	radix := factors[0]
+	m := len(out) / radix
+
+	if m == 1 {
+		for q := 0; q < radix; q++ {
+			out[q] = in[q*stride]
+		}
+	} else {
+		for q := 0; q < radix; q++ {
+			c.work(out[q*m:(q+1)*m], in[q*stride:], stride*radix, factors[1:], twStride*radix, tmp)
+		}
+	}
+
+	switch radix {
+	case 2:
+		c.butterfly2(out, m, twStride)
+	case 4:
+		c.butterfly4(out, m, twStride)
+	default:
+		c.butterflyGeneric(out, m, radix, twStride, tmp)
+	}
+}
+
+func (c *complexFFT) butterfly2(out []complex128, m, twStride int) {
+	for k := 0; k < m; k++ {
+		a := out[k]
+		b := out[m+k] * c.twiddle[k*twStride]
+		out[k] = a + b
+		out[m+k] = a - b
+	}
+}
+
+func (c *complexFFT) butterfly4(out []complex128, m, twStride int) {
+	for k := 0; k < m; k++ {
+		t0 := out[k]
+		t1 := out[m+k] * c.twiddle[k*twStride]
+		t2 := out[2*m+k] * c.twiddle[2*k*twStride]
+		t3 := out[3*m+k] * c.twiddle[3*k*twStride]
+
+		s02, d02 := t0+t2, t0-t2
+		s13, d13 := t1+t3, t1-t3
+		// multiply by -i
+		d13 = complex(imag(d13), -real(d13))
+
+		out[k] = s02 + s13
+		out[m+k] = d02 + d13
+		out[2*m+k] = s02 - s13
+		out[3*m+k] = d02 - d13
+	}
+}
+
+func (c *complexFFT) butterflyGeneric(out []complex128, m, radix, twStride int, tmp []complex128) {
+	// W_radix = W_n^(n / radix)
+	rootStride := c.n / radix
+	for k := 0; k < m; k++ {
+		for q := 0; q < radix; q++ {
+			tmp[q] = out[q*m+k] * c.twiddle[q*k*twStride]
+		}
+
+		for u := 0; u < radix; u++ {
+			sum := tmp[0]
+			for q := 1; q < radix; q++ {
+				sum += tmp[q] * c.twiddle[((u*q)%radix)*rootStride]
+			}
+
+			out[u*m+k] = sum
+		}
+	}
+}
+
+// Bluestein's algorithm, X[k] = w[k] * sum(x[j] * w[j] * conj(w[k - j])) where w[k] = exp(-πik²/n), the sum being a
+// convolution done with power of two transforms
+type bluestein struct {
+	n int
+
+	chirp []complex128
+
+	// transform of the (wrapped) conjugate chirp, pre-scaled by 1 / m for the inverse
+	kernel []complex128
+
+	inner *complexFFT
+}
+
+type bluesteinScratch struct {
+	a, b  []complex128
+	inner *complexScratch
+}
+
+func newBluestein(n int) *bluestein {
+	m := NextPower2(2*n - 1)
+	out := &bluestein{
+		n:      n,
+		chirp:  make([]complex128, n),
+		kernel: make([]complex128, m),
+		inner:  newComplexFFT(m),
+	}
+
+	for k := range out.chirp {
+		// k² mod 2n keeps the angle small, so it stays accurate for large k
+		k2 := (k * k) % (2 * n)
+		out.chirp[k] = cmplx.Rect(1, -math.Pi*float64(k2)/float64(n))
+	}
+
+	b := make([]complex128, m)
+	b[0] = cmplx.Conj(out.chirp[0])
+	for k := 1; k < n; k++ {
+		b[k] = cmplx.Conj(out.chirp[k])
+		b[m-k] = b[k]
+	}
+
+	s := out.inner.newScratch()
+	out.inner.transform(b, out.kernel, s)
+	for i := range out.kernel {
+		out.kernel[i] /= complex(float64(m), 0)
+	}
+
+	return out
+}
+
+func (b *bluestein) newScratch() *bluesteinScratch {
+	m := len(b.kernel)
+	return &bluesteinScratch{
+		a:     make([]complex128, m),
+		b:     make([]complex128, m),
+		inner: b.inner.newScratch(),
+	}
+}
+
+func (b *bluestein) transform(in, out []complex128, s *bluesteinScratch) {
+	for k := 0; k < b.n; k++ {
+		s.a[k] = in[k] * b.chirp[k]
+	}
+
+	for k := b.n; k < len(s.a); k++ {
+		s.a[k] = 0
+	}
+
+	b.inner.transform(s.a, s.b, s.inner)
+
+	// inverse transform by conjugating on the way in and out
+	for i, v := range s.b {
+		s.b[i] = cmplx.Conj(v * b.kernel[i])
+	}
+
+	b.inner.transform(s.b, s.a, s.inner)
+
+	for k := 0; k < b.n; k++ {
+		out[k] = cmplx.Conj(s.a[k]) * b.chirp[k]
+	}
+}
+
+// real-to-complex transform of size n producing (n / 2) + 1 values
+type realFFT struct {
+	n int
+
+	// transform of size n / 2 when n is even, otherwise of size n
+	inner *complexFFT
+
+	// exp(-2πik/n) for k in [0, n / 2], only for even n
+	twiddle []complex128
+}
+
+type realScratch struct {
+	z, zOut []complex128
+	inner   *complexScratch
+}
+
+func newRealFFT(n int) *realFFT {
+	out := &realFFT{n: n}
+	if n%2 != 0 {
+		out.inner = newComplexFFT(n)
+		return out
+	}
+
+	half := n / 2
+	out.inner = newComplexFFT(half)
+	out.twiddle = make([]complex128, half+1)
+	for k := range out.twiddle {
+		out.twiddle[k] = cmplx.Rect(1, -2*math.Pi*float64(k)/float64(n))
+	}
+
+	return out
+}
+
+func (r *realFFT) newScratch() *realScratch {
+	return &realScratch{
+		z:     make([]complex128, r.inner.n),
+		zOut:  make([]complex128, r.inner.n),
+		inner: r.inner.newScratch(),
+	}
+}
+
+// transforms in (length n) to out (length (n / 2) + 1)
+func (r *realFFT) transform(in []float64, out []complex128, s *realScratch) {
+	if r.twiddle == nil {
+		for i, v := range in[:r.n] {
+			s.z[i] = complex(v, 0)
+		}
+
+		r.inner.transform(s.z, s.zOut, s.inner)
+		copy(out[:r.n/2+1], s.zOut)
+		return
+	}
+
+	// pack even samples into the real part and odd samples into the imaginary part, transform at half size, then
+	// separate the even & odd spectra: Z[k] = E[k] + iO[k], and X[k] = E[k] + W_n^k * O[k]
+	half := r.n / 2
+	for j := 0; j < half; j++ {
+		s.z[j] = complex(in[2*j], in[2*j+1])
+	}
+
+	r.inner.transform(s.z, s.zOut, s.inner)
+
+	Z := s.zOut
+	for k := 0; k <= half; k++ {
+		zk := Z[k%half]
+		zc := cmplx.Conj(Z[(half-k)%half])
+
+		even := (zk + zc) * 0.5
+		odd := (zk - zc) * complex(0, -0.5)
+		out[k] = even + r.twiddle[k]*odd
+	}
+}
+
+// the unnormalized inverse of transform, in (length (n / 2) + 1) to out (length n), which is n times the signal that
+// produced in. The imaginary parts of in[0] and (for even n) in[n / 2] are ignored
+func (r *realFFT) inverse(in []complex128, out []float64, s *realScratch) {
+	half := r.n / 2
+	if r.twiddle == nil {
+		// rebuild the full (conjugate symmetric) spectrum, the inverse is then conj(forward(conj(X)))
+		s.z[0] = complex(real(in[0]), 0)
+		for k := 1; k <= half; k++ {
+			s.z[k] = cmplx.Conj(in[k])
+			s.z[r.n-k] = in[k]
+		}
+
+		r.inner.transform(s.z, s.zOut, s.inner)
+		for i := range out[:r.n] {
+			out[i] = real(s.zOut[i])
+		}
+
+		return
+	}
+
+	// undo the separation done by transform: E[k] = X[k] + conj(X[h - k]) and O[k] = (X[k] - conj(X[h - k])) / W_n^k
+	// (both doubled so the half size inverse comes out scaled by n), then inverse transform Z[k] = E[k] + iO[k] at half
+	// size and unpack the even & odd samples
+	for k := 0; k < half; k++ {
+		xk, xc := in[k], cmplx.Conj(in[half-k])
+		if k == 0 {
+			xk, xc = complex(real(in[0]), 0), complex(real(in[half]), 0)
+		}
+
+		even := xk + xc
+		odd := (xk - xc) * cmplx.Conj(r.twiddle[k])
+		// conjugated on the way in, so the forward transform computes the inverse
+		s.z[k] = cmplx.Conj(even + complex(0, 1)*odd)
+	}
+
+	r.inner.transform(s.z, s.zOut, s.inner)
+
+	for j := 0; j < half; j++ {
+		z := s.zOut[j]
+		out[2*j] = real(z)
+		out[2*j+1] = -imag(z)
+	}
+}
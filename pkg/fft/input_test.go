@@ -0,0 +1,9 @@
+package fft_test
+
+import "github.com/Twister915/vis.go/pkg/audio"
+
+// samples are indexed [frame][channel]
+func newMemoryInput(sampleRate int, samples [][]float64) audio.Input {
+	format := audio.Format{BitDepth: 16, Channels: len(samples[0]), SampleRate: sampleRate}
+	return audio.ToInput(audio.NewMemoryReader(format, samples))
+}
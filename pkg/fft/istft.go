@@ -0,0 +1,251 @@
+package fft
+
+import (
+	"fmt"
+	"math"
+)
+
+// how ISTFT combines the inverse transformed frames
+type OverlapAdd int
+
+const (
+	// frames are added as they are, so the window only appears once (from the analysis) and must overlap-add to a
+	// constant at the hop
+	PlainOverlapAdd OverlapAdd = iota
+
+	// frames are windowed again before being added, which smooths the discontinuities spectral edits leave at the frame
+	// edges. The squared window must overlap-add to a constant
+	WeightedOverlapAdd
+)
+
+// largest COLA ripple NewISTFT accepts, windows defined over N - 1 points (like BlackmanWindow) are never exactly COLA
+// but come close at a small enough hop
+var COLATolerance = 0.01
+
+// returned by NewISTFT when the window does not overlap-add to a constant at the hop
+type COLAError struct {
+	N, Hop   int
+	Weighted bool
+	Ripple   float64
+}
+
+func (e *COLAError) Error() string {
+	return fmt.Sprintf("window of %d samples is not COLA at a hop of %d (weighted %v, ripple %.4f)", e.N, e.Hop, e.Weighted, e.Ripple)
+}
+
+// overlap-adds the window (squared when weighted) at every hop. gain is the mean of the sum, which is what overlap-add
+// scales the signal by, and ripple is the largest deviation from it relative to gain. A ripple of 0 means the window
+// satisfies the constant overlap-add (COLA) constraint at this hop
+func COLA(window WindowingFunction, n, hop int, weighted bool) (gain, ripple float64) {
+	sums := make([]float64, hop)
+	for i := 0; i < n; i++ {
+		w := window(float64(i), float64(n))
+		if weighted {
+			w *= w
+		}
+
+		sums[i%hop] += w
+	}
+
+	for _, s := range sums {
+		gain += s
+	}
+
+	gain /= float64(hop)
+	if gain == 0 {
+		ripple = math.Inf(1)
+		return
+	}
+
+	for _, s := range sums {
+		if d := math.Abs(s-gain) / gain; d > ripple {
+			ripple = d
+		}
+	}
+
+	return
+}
+
+// rebuilds audio from spectra (like those from FFTByFrame.ComputeComplex or FFTComputeAll.ComputeAllComplex) by inverse
+// transforming each frame and overlap-adding the results
+//
+// the output is divided by the sum of the overlapping windows at each sample, so unmodified spectra come back as the
+// original samples (apart from where that sum is 0, such as the very first sample under a Hann window)
+type ISTFT struct {
+	n, hop, channels int
+	mode             OverlapAdd
+
+	window []float64
+	plan   *CachedPlan
+
+	// overlap-add accumulators indexed [channel][sample], the first hop samples are complete after each frame
+	acc [][]float64
+	// sum of the windows (squared when weighted) added at each sample
+	norm []float64
+}
+
+// n and hop are in samples, and window must be the one the frames were analyzed with
+func NewISTFT(n, hop, channels int, window WindowingFunction, mode OverlapAdd, opts Options) (out *ISTFT, err error) {
+	if hop <= 0 || hop > n {
+		err = fmt.Errorf("hop %d must be in [1, %d]", hop, n)
+		return
+	}
+
+	if _, ripple := COLA(window, n, hop, mode == WeightedOverlapAdd); ripple > COLATolerance {
+		err = &COLAError{N: n, Hop: hop, Weighted: mode == WeightedOverlapAdd, Ripple: ripple}
+		return
+	}
+
+	out = &ISTFT{
+		n:        n,
+		hop:      hop,
+		channels: channels,
+		mode:     mode,
+		window:   make([]float64, n),
+		acc:      make([][]float64, channels),
+		norm:     make([]float64, n),
+	}
+
+	for i := range out.window {
+		out.window[i] = window(float64(i), float64(n))
+	}
+
+	for ch := range out.acc {
+		out.acc[ch] = make([]float64, n)
+	}
+
+	// each channel's spectrum & samples are contiguous
+	out.plan = opts.Cache.Acquire(opts.backend(), PlanKey{
+		N:        n,
+		HowMany:  channels,
+		IStride:  1,
+		IDist:    (n / 2) + 1,
+		OStride:  1,
+		ODist:    n,
+		Inverse:  true,
+		Planning: opts.Planning,
+	})
+
+	return
+}
+
+// adds one frame, spectrum is indexed [bin][channel] with (n / 2) + 1 bins. The hop samples which no later frame will
+// change are written to out (indexed [frame][channel]), and the number written is returned
+func (s *ISTFT) Push(spectrum [][]complex128, out [][]float64) int {
+	bins := (s.n / 2) + 1
+	for i, bin := range spectrum[:bins] {
+		for ch, v := range bin {
+			s.plan.Complexes[ch*bins+i] = v
+		}
+	}
+
+	s.plan.Execute()
+
+	// the inverse is not normalized
+	scale := 1 / float64(s.n)
+	for ch, acc := range s.acc {
+		samples := s.plan.Reals[ch*s.n : (ch+1)*s.n]
+		for i, v := range samples {
+			v *= scale
+			if s.mode == WeightedOverlapAdd {
+				v *= s.window[i]
+			}
+
+			acc[i] += v
+		}
+	}
+
+	for i, w := range s.window {
+		if s.mode == WeightedOverlapAdd {
+			w *= w
+		}
+
+		s.norm[i] += w
+	}
+
+	s.emit(out, s.hop)
+
+	// shift out the finished samples
+	for _, acc := range s.acc {
+		copy(acc, acc[s.hop:])
+		for i := s.n - s.hop; i < s.n; i++ {
+			acc[i] = 0
+		}
+	}
+
+	copy(s.norm, s.norm[s.hop:])
+	for i := s.n - s.hop; i < s.n; i++ {
+		s.norm[i] = 0
+	}
+
+	return s.hop
+}
+
+// writes the tail of the last frame (n - hop samples) to out and returns the number written, after this the ISTFT starts
+// over
+func (s *ISTFT) Flush(out [][]float64) (n int) {
+	n = s.n - s.hop
+	s.emit(out, n)
+
+	for _, acc := range s.acc {
+		for i := range acc {
+			acc[i] = 0
+		}
+	}
+
+	for i := range s.norm {
+		s.norm[i] = 0
+	}
+
+	return
+}
+
+// resynthesizes a whole signal, frames indexed [frame][bin][channel]. The result has (frames - 1) * hop + n samples
+func (s *ISTFT) ComputeAll(frames [][][]complex128) (out [][]float64) {
+	if len(frames) == 0 {
+		return
+	}
+
+	out = make([][]float64, (len(frames)-1)*s.hop+s.n)
+	outN := make([]float64, len(out)*s.channels)
+	for i := range out {
+		out[i], outN = outN[:s.channels], outN[s.channels:]
+	}
+
+	at := 0
+	for _, frame := range frames {
+		at += s.Push(frame, out[at:])
+	}
+
+	s.Flush(out[at:])
+	return
+}
+
+// samples in each frame
+func (s *ISTFT) FrameSize() int {
+	return s.n
+}
+
+// samples output per frame
+func (s *ISTFT) Hop() int {
+	return s.hop
+}
+
+func (s *ISTFT) Close() {
+	s.plan.Release()
+}
+
+func (s *ISTFT) emit(out [][]float64, count int) {
+	// below this the windows barely covered the sample, and dividing would only amplify noise
+	const minNorm = 1e-10
+	for i := 0; i < count; i++ {
+		norm := s.norm[i]
+		for ch, acc := range s.acc {
+			if norm < minNorm {
+				out[i][ch] = 0
+			} else {
+				out[i][ch] = acc[i] / norm
+			}
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package fft_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/fft"
+)
+
+func TestInverseRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(4))
+	backends := []fft.Backend{fft.GoBackend{}, fft.DefaultBackend}
+	for _, backend := range backends {
+		for _, n := range []int{1, 2, 3, 5, 8, 15, 16, 100, 131, 256, 1000, 4099} {
+			const howmany = 2
+			bins := n/2 + 1
+			reals := backend.AllocReals(n * howmany)
+			complexes := backend.AllocComplexes(bins * howmany)
+			back := backend.AllocReals(n * howmany)
+
+			forward := backend.PlanR2C(n, howmany, reals, 1, n, complexes, 1, bins, fft.Planning{})
+			inverse := backend.PlanC2R(n, howmany, complexes, 1, bins, back, 1, n, fft.Planning{})
+
+			original := make([]float64, len(reals))
+			for i := range original {
+				original[i] = rnd.Float64()*2 - 1
+			}
+
+			copy(reals, original)
+			forward.Execute()
+			inverse.Execute()
+
+			for i, v := range back {
+				if math.Abs(v/float64(n)-original[i]) > 1e-9 {
+					t.Fatalf("%s n=%d: sample %d = %v, expected %v", backend.Name(), n, i, v/float64(n), original[i])
+				}
+			}
+
+			forward.Close()
+			inverse.Close()
+			backend.Free(reals)
+			backend.Free(complexes)
+			backend.Free(back)
+		}
+	}
+}
+
+func TestInverseRoundTrip32(t *testing.T) {
+	rnd := rand.New(rand.NewSource(5))
+	for _, backend := range []fft.Backend{fft.GoBackend{}, fft.DefaultBackend} {
+		for _, n := range []int{7, 64, 210} {
+			bins := n/2 + 1
+			reals := backend.AllocFloats(n)
+			complexes := backend.AllocFloatComplexes(bins)
+			back := backend.AllocFloats(n)
+
+			forward := backend.PlanR2C32(n, 1, reals, 1, n, complexes, 1, bins, fft.Planning{})
+			inverse := backend.PlanC2R32(n, 1, complexes, 1, bins, back, 1, n, fft.Planning{})
+
+			original := make([]float32, n)
+			for i := range original {
+				original[i] = float32(rnd.Float64()*2 - 1)
+			}
+
+			copy(reals, original)
+			forward.Execute()
+			inverse.Execute()
+
+			for i, v := range back {
+				if math.Abs(float64(v/float32(n)-original[i])) > 1e-4 {
+					t.Fatalf("%s n=%d: sample %d = %v, expected %v", backend.Name(), n, i, v/float32(n), original[i])
+				}
+			}
+
+			forward.Close()
+			inverse.Close()
+			backend.Free(reals)
+			backend.Free(complexes)
+			backend.Free(back)
+		}
+	}
+}
+
+func TestCOLA(t *testing.T) {
+	if gain, ripple := fft.COLA(fft.NoWindow, 64, 16, false); gain != 4 || ripple != 0 {
+		t.Fatalf("rectangular window at a quarter hop: gain %v ripple %v", gain, ripple)
+	}
+
+	// the rectangular window does not overlap evenly unless the hop divides the size
+	if _, ripple := fft.COLA(fft.NoWindow, 64, 48, false); ripple == 0 {
+		t.Fatal("expected ripple for a hop which does not divide the window")
+	}
+
+	if _, err := fft.NewISTFT(1024, 512, 1, fft.BlackmanNuttallWindow, fft.PlainOverlapAdd, fft.Options{}); err == nil {
+		t.Fatal("expected a COLA error for Blackman-Nuttall at half overlap")
+	} else if _, ok := err.(*fft.COLAError); !ok {
+		t.Fatalf("expected *COLAError, got %T", err)
+	}
+}
+
+func TestISTFTReconstructs(t *testing.T) {
+	const sampleRate = 1000
+	samples := stereoTestSignal(2048)
+	for _, mode := range []fft.OverlapAdd{fft.PlainOverlapAdd, fft.WeightedOverlapAdd} {
+		analysis := fft.NewFFTByFrame(newMemoryInput(sampleRate, samples), time.Millisecond*256, time.Millisecond*32, fft.BlackmanNuttallWindow, false)
+
+		var frames [][][]complex128
+		for analysis.HasNext() {
+			frame := make([][]complex128, analysis.NumberOutputFrequencies())
+			for i := range frame {
+				frame[i] = make([]complex128, 2)
+			}
+
+			if err := analysis.ComputeComplex(frame); err != nil {
+				t.Fatal(err)
+			}
+
+			frames = append(frames, frame)
+		}
+
+		istft, err := fft.NewISTFT(analysis.FrameSize(), analysis.Hop(), 2, fft.BlackmanNuttallWindow, mode, fft.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out := istft.ComputeAll(frames)
+		istft.Close()
+		analysis.Close()
+
+		if expected := (len(frames)-1)*32 + 256; len(out) != expected {
+			t.Fatalf("got %d samples, expected %d", len(out), expected)
+		}
+
+		for i, frame := range out {
+			for ch, v := range frame {
+				if math.Abs(v-samples[i][ch]) > 1e-6 {
+					t.Fatalf("mode %d: sample %d channel %d = %v, expected %v", mode, i, ch, v, samples[i][ch])
+				}
+			}
+		}
+	}
+}
+
+// streaming in pieces gives the same result as ComputeAll
+func TestISTFTPushFlush(t *testing.T) {
+	const n, hop = 32, 8
+	rnd := rand.New(rand.NewSource(6))
+	frames := make([][][]complex128, 5)
+	for f := range frames {
+		frames[f] = make([][]complex128, n/2+1)
+		for i := range frames[f] {
+			frames[f][i] = []complex128{complex(rnd.Float64(), rnd.Float64())}
+		}
+	}
+
+	istft, err := fft.NewISTFT(n, hop, 1, fft.NoWindow, fft.WeightedOverlapAdd, fft.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer istft.Close()
+
+	all := istft.ComputeAll(frames)
+	streamed := make([][]float64, 0, len(all))
+	buf := make([][]float64, n)
+	for i := range buf {
+		buf[i] = make([]float64, 1)
+	}
+
+	appendOut := func(count int) {
+		for _, v := range buf[:count] {
+			streamed = append(streamed, []float64{v[0]})
+		}
+	}
+
+	for _, frame := range frames {
+		appendOut(istft.Push(frame, buf))
+	}
+
+	appendOut(istft.Flush(buf))
+
+	if len(streamed) != len(all) {
+		t.Fatalf("streamed %d samples, expected %d", len(streamed), len(all))
+	}
+
+	for i := range all {
+		if streamed[i][0] != all[i][0] {
+			t.Fatalf("sample %d: %v != %v", i, streamed[i][0], all[i][0])
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package fft_test
+
+import (
+	"math/cmplx"
+	"testing"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/fft"
+)
+
+// zero padding samples the same spectrum more finely, so every factor'th padded bin is an unpadded bin
+func TestZeroPaddingInterpolates(t *testing.T) {
+	const sampleRate, factor = 1000, 4
+	samples := stereoTestSignal(1000)
+	window, hop := time.Millisecond*100, time.Millisecond*50
+	opts := fft.Options{RemoveDC: true}
+	padded := opts
+	padded.FFTSize = 100 * factor
+
+	check := func(name string, plain, interpolated [][][]complex128) {
+		if len(plain) == 0 || len(plain) != len(interpolated) {
+			t.Fatalf("%s: %d padded frames, expected %d", name, len(interpolated), len(plain))
+		}
+
+		for fI := range plain {
+			if len(interpolated[fI]) != (len(plain[fI])-1)*factor+1 {
+				t.Fatalf("%s: %d padded bins for %d bins", name, len(interpolated[fI]), len(plain[fI]))
+			}
+
+			for i := range plain[fI] {
+				for ch := range plain[fI][i] {
+					if d := cmplx.Abs(plain[fI][i][ch] - interpolated[fI][i*factor][ch]); d > 1e-9 {
+						t.Fatalf("%s: frame %d bin %d channel %d: %v != %v", name, fI, i, ch, interpolated[fI][i*factor][ch], plain[fI][i][ch])
+					}
+				}
+			}
+		}
+	}
+
+	byFrame := func(opts fft.Options) (all [][][]complex128) {
+		f := fft.NewFFTByFrameOptions(newMemoryInput(sampleRate, samples), window, hop, fft.HannWindow, opts)
+		defer f.Close()
+
+		size := 100
+		if opts.FFTSize > size {
+			size = opts.FFTSize
+		}
+
+		if f.FrameSize() != 100 || f.FFTSize() != size {
+			t.Fatalf("frame size %d, fft size %d", f.FrameSize(), f.FFTSize())
+		}
+
+		for f.HasNext() {
+			frame := make([][]complex128, f.NumberOutputFrequencies())
+			for i := range frame {
+				frame[i] = make([]complex128, 2)
+			}
+
+			if err := f.ComputeComplex(frame); err != nil {
+				t.Fatal(err)
+			}
+
+			all = append(all, frame)
+		}
+
+		return
+	}
+
+	computeAll := func(opts fft.Options) [][][]complex128 {
+		all, err := fft.NewFFTComputeAllOptions(newMemoryInput(sampleRate, samples), window, hop, fft.HannWindow, opts).ComputeAllComplex()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return all
+	}
+
+	check("FFTByFrame", byFrame(opts), byFrame(padded))
+	check("FFTComputeAll", computeAll(opts), computeAll(padded))
+}
+
+// a cached plan's buffers hold the previous analyzer's samples, the padding must not pick them up
+func TestZeroPaddingWithCache(t *testing.T) {
+	samples := stereoTestSignal(600)
+	opts := fft.Options{FFTSize: 256, Cache: fft.NewPlanCache(1)}
+	first := fft.NewFFTByFrameOptions(newMemoryInput(1000, samples), time.Millisecond*256, time.Millisecond*128, fft.NoWindow, fft.Options{Cache: opts.Cache})
+	for first.HasNext() {
+		if err := first.ComputeComplex(make2DComplex(first.NumberOutputFrequencies(), 2)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first.Close()
+
+	fresh := fft.NewFFTByFrameOptions(newMemoryInput(1000, samples), time.Millisecond*100, time.Millisecond*50, fft.NoWindow, fft.Options{FFTSize: 256})
+	defer fresh.Close()
+	reused := fft.NewFFTByFrameOptions(newMemoryInput(1000, samples), time.Millisecond*100, time.Millisecond*50, fft.NoWindow, opts)
+	defer reused.Close()
+
+	if opts.Cache.Idle() != 0 {
+		t.Fatal("plan was not reused")
+	}
+
+	a, b := make2DComplex(129, 2), make2DComplex(129, 2)
+	for fresh.HasNext() {
+		if err := fresh.ComputeComplex(a); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := reused.ComputeComplex(b); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := range a {
+			for ch := range a[i] {
+				if cmplx.Abs(a[i][ch]-b[i][ch]) > 1e-9 {
+					t.Fatalf("bin %d channel %d: %v != %v", i, ch, b[i][ch], a[i][ch])
+				}
+			}
+		}
+	}
+}
+
+func make2DComplex(x, y int) (out [][]complex128) {
+	out = make([][]complex128, x)
+	for i := range out {
+		out[i] = make([]complex128, y)
+	}
+
+	return
+}
@@ -0,0 +1,28 @@
+package fft
+
+import "math"
+
+// maps a phase difference (in radians) into (-π, π]
+func WrapPhase(p float64) float64 {
+	p -= 2 * math.Pi * math.Round(p/(2*math.Pi))
+	if p <= -math.Pi {
+		p += 2 * math.Pi
+	}
+
+	return p
+}
+
+// removes the jumps of 2π between consecutive phases (in radians), in place
+func UnwrapPhase(phase []float64) {
+	if len(phase) == 0 {
+		return
+	}
+
+	last, unwrapped := phase[0], phase[0]
+	for i := 1; i < len(phase); i++ {
+		p := phase[i]
+		unwrapped += WrapPhase(p - last)
+		last = p
+		phase[i] = unwrapped
+	}
+}
@@ -0,0 +1,71 @@
+package fft
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// how hard FFTW should look for a fast plan, these map to FFTW_ESTIMATE, FFTW_MEASURE, FFTW_PATIENT and
+// FFTW_EXHAUSTIVE
+type Rigor int
+
+const (
+	Estimate Rigor = iota
+	Measure
+	Patient
+	Exhaustive
+)
+
+var rigorNames = []string{"estimate", "measure", "patient", "exhaustive"}
+
+func (r Rigor) String() string {
+	if r < 0 || int(r) >= len(rigorNames) {
+		return fmt.Sprintf("Rigor(%d)", int(r))
+	}
+
+	return rigorNames[r]
+}
+
+// parses the names returned by Rigor.String, case insensitive
+func ParseRigor(s string) (r Rigor, err error) {
+	for i, name := range rigorNames {
+		if strings.EqualFold(s, name) {
+			r = Rigor(i)
+			return
+		}
+	}
+
+	err = fmt.Errorf("unknown planning rigor '%s'", s)
+	return
+}
+
+const defaultPlanTimeLimit = time.Second * 2
+
+// planning settings passed to the backend, GoBackend has nothing to plan and ignores them
+//
+// with a Rigor above Estimate, FFTW first looks for the problem in the loaded wisdom (see ImportWisdom). If it is not
+// there the transform starts out with an FFTW_ESTIMATE plan (so the first frame is not delayed) while the real plan is
+// measured in the background, and the transform switches over to it once it is ready. Exporting the wisdom on exit
+// means the next run finds the plan straight away
+type Planning struct {
+	Rigor Rigor
+
+	// 0 means the default of 2 seconds, negative means no limit
+	TimeLimit time.Duration
+
+	// plan at Rigor before returning, instead of starting with an estimated plan
+	Wait bool
+}
+
+func (p Planning) timeLimit() time.Duration {
+	if p.TimeLimit == 0 {
+		return defaultPlanTimeLimit
+	}
+
+	if p.TimeLimit < 0 {
+		return 0
+	}
+
+	return p.TimeLimit
+}
@@ -0,0 +1,67 @@
+package fft_test
+
+import (
+	"math/cmplx"
+	"math/rand"
+	"testing"
+
+	"github.com/Twister915/vis.go/pkg/fft"
+)
+
+func TestParseRigor(t *testing.T) {
+	for _, r := range []fft.Rigor{fft.Estimate, fft.Measure, fft.Patient, fft.Exhaustive} {
+		parsed, err := fft.ParseRigor(r.String())
+		if err != nil || parsed != r {
+			t.Errorf("ParseRigor(%q) = %v, %v", r.String(), parsed, err)
+		}
+	}
+
+	if r, err := fft.ParseRigor("MEASURE"); err != nil || r != fft.Measure {
+		t.Errorf("ParseRigor is case sensitive: %v, %v", r, err)
+	}
+
+	if _, err := fft.ParseRigor("fast"); err == nil {
+		t.Error("expected error for unknown rigor")
+	}
+}
+
+// whichever plan ends up being used, the output must not change
+func TestPlanningRigorSameOutput(t *testing.T) {
+	const n = 512
+	rnd := rand.New(rand.NewSource(3))
+	samples := make([]float64, n)
+	for i := range samples {
+		samples[i] = rnd.Float64()*2 - 1
+	}
+
+	backend := fft.DefaultBackend
+	transform := func(p fft.Planning) []complex128 {
+		in := backend.AllocReals(n)
+		defer backend.Free(in)
+		out := backend.AllocComplexes(n/2 + 1)
+		defer backend.Free(out)
+
+		plan := backend.PlanR2C(n, 1, in, 1, n, out, 1, n/2+1, p)
+		defer plan.Close()
+
+		// run a few times so a background plan has the chance to be swapped in
+		result := make([]complex128, len(out))
+		for i := 0; i < 3; i++ {
+			copy(in, samples)
+			plan.Execute()
+		}
+
+		copy(result, out)
+		return result
+	}
+
+	expected := transform(fft.Planning{})
+	for _, p := range []fft.Planning{{Rigor: fft.Measure}, {Rigor: fft.Measure, Wait: true}} {
+		got := transform(p)
+		for k := range expected {
+			if cmplx.Abs(got[k]-expected[k]) > 1e-9 {
+				t.Fatalf("rigor %s (wait %v): bin %d = %v, expected %v", p.Rigor, p.Wait, k, got[k], expected[k])
+			}
+		}
+	}
+}
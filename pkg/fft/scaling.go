@@ -0,0 +1,129 @@
+package fft
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// what the magnitude style outputs (FFTByFrame.Compute, FFTComputeAll.ComputeAll and their single precision versions)
+// contain for each bin
+type Scaling int
+
+const (
+	// |X[k]|
+	Magnitude Scaling = iota
+
+	// |X[k]|²
+	Power
+
+	// one sided power spectral density in units² / Hz, |X[k]|² / (sampleRate * Σw²) doubled for every bin but DC and
+	// Nyquist. Summing it times the bin width gives the mean square of the signal
+	PSD
+
+	// amplitude in dB relative to full scale, corrected for the window's coherent gain so a full scale sine centered on
+	// a bin reads 0 dBFS whatever the window. Silent bins are clamped to MinDBFS
+	DBFS
+)
+
+// the floor for DBFS output
+const MinDBFS = -240.0
+
+var scalingNames = []string{"magnitude", "power", "psd", "dbfs"}
+
+func (s Scaling) String() string {
+	if s < 0 || int(s) >= len(scalingNames) {
+		return fmt.Sprintf("Scaling(%d)", int(s))
+	}
+
+	return scalingNames[s]
+}
+
+// parses the names returned by Scaling.String, case insensitive
+func ParseScaling(s string) (out Scaling, err error) {
+	for i, name := range scalingNames {
+		if strings.EqualFold(s, name) {
+			out = Scaling(i)
+			return
+		}
+	}
+
+	err = fmt.Errorf("unknown spectrum scaling '%s'", s)
+	return
+}
+
+// converts complex bins to the selected scaling, built once per analyzer from the window it applies
+type scaler struct {
+	scaling Scaling
+	// transform size, longer than the window when frames are zero padded
+	n int
+
+	// 1 / (sampleRate * Σw²)
+	psd float64
+	// 1 / Σw, the inverse of the coherent gain times n
+	amplitude float64
+}
+
+func newScaler(scaling Scaling, window []float64, n, sampleRate int) (s scaler) {
+	s.scaling = scaling
+	s.n = n
+
+	var sum, sumSquares float64
+	for _, w := range window {
+		sum += w
+		sumSquares += w * w
+	}
+
+	s.psd = 1 / (float64(sampleRate) * sumSquares)
+	s.amplitude = 1 / sum
+	return
+}
+
+// every bin except DC and (for even n) Nyquist stands for both the positive and negative frequency
+func (s scaler) oneSided(k int) float64 {
+	if k == 0 || (s.n%2 == 0 && k == s.n/2) {
+		return 1
+	}
+
+	return 2
+}
+
+func (s scaler) scale(k int, v complex128) float64 {
+	r, im := real(v), imag(v)
+	switch s.scaling {
+	case Power:
+		return r*r + im*im
+	case PSD:
+		return (r*r + im*im) * s.psd * s.oneSided(k)
+	case DBFS:
+		a := math.Hypot(r, im) * s.amplitude * s.oneSided(k)
+		return math.Max(20*math.Log10(a), MinDBFS)
+	default:
+		return math.Hypot(r, im)
+	}
+}
+
+// subtracts the mean of a frame, which zeroes its DC bin
+func removeMean(frame []float64) {
+	sum := 0.0
+	for _, v := range frame {
+		sum += v
+	}
+
+	mean := sum / float64(len(frame))
+	for i := range frame {
+		frame[i] -= mean
+	}
+}
+
+func removeMean32(frame []float32) {
+	var sum float32
+	for _, v := range frame {
+		sum += v
+	}
+
+	mean := sum / float32(len(frame))
+	for i := range frame {
+		frame[i] -= mean
+	}
+}
@@ -0,0 +1,184 @@
+package fft_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/fft"
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+func allScalings() []fft.Scaling {
+	return []fft.Scaling{fft.Magnitude, fft.Power, fft.PSD, fft.DBFS}
+}
+
+func closeTo(a, b, relative float64) bool {
+	if math.IsInf(a, 0) || math.IsInf(b, 0) {
+		return a == b
+	}
+
+	return math.Abs(a-b) <= relative*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+// the streaming and whole-file analyzers output the same values for every option combination
+func TestScalingMatchesAcrossImplementations(t *testing.T) {
+	const sampleRate = 1000
+	samples := stereoTestSignal(1024)
+	windowSize, windowMove := time.Millisecond*128, time.Millisecond*48
+
+	for _, scaling := range allScalings() {
+		for _, removeDC := range []bool{false, true} {
+			opts := fft.Options{Scaling: scaling, RemoveDC: removeDC}
+			byFrame := fft.NewFFTByFrameOptions(newMemoryInput(sampleRate, samples), windowSize, windowMove, fft.BlackmanNuttallWindow, opts)
+			all, err := fft.NewFFTComputeAllOptions(newMemoryInput(sampleRate, samples), windowSize, windowMove, fft.BlackmanNuttallWindow, opts).ComputeAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(all) == 0 {
+				t.Fatal("no frames computed")
+			}
+
+			frame := util.Create2DFloats(byFrame.NumberOutputFrequencies(), 2)
+			for fI, expected := range all {
+				if err = byFrame.Compute(frame); err != nil {
+					t.Fatal(err)
+				}
+
+				for i := range expected {
+					for ch := range expected[i] {
+						if !closeTo(frame[i][ch], expected[i][ch], 1e-9) {
+							t.Fatalf("%s removeDC=%v frame %d bin %d channel %d: FFTByFrame %v, FFTComputeAll %v", scaling, removeDC, fI, i, ch, frame[i][ch], expected[i][ch])
+						}
+					}
+				}
+			}
+
+			byFrame.Close()
+		}
+	}
+}
+
+func TestScalingMatchesSinglePrecision(t *testing.T) {
+	const sampleRate = 1000
+	samples := stereoTestSignal(512)
+	windowSize, windowMove := time.Millisecond*128, time.Millisecond*64
+
+	for _, scaling := range allScalings() {
+		opts := fft.Options{Scaling: scaling, RemoveDC: true}
+		f64 := fft.NewFFTByFrameOptions(newMemoryInput(sampleRate, samples), windowSize, windowMove, fft.BlackmanNuttallWindow, opts)
+		f32 := fft.NewFFTByFrame32Options(newMemoryInput(sampleRate, samples), windowSize, windowMove, fft.BlackmanNuttallWindow, opts)
+		all32, err := fft.NewFFTComputeAll32Options(newMemoryInput(sampleRate, samples), windowSize, windowMove, fft.BlackmanNuttallWindow, opts).ComputeAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := util.Create2DFloats(f64.NumberOutputFrequencies(), 2)
+		got := util.CreateNDTs(float32(0), f32.NumberOutputFrequencies(), 2).([][]float32)
+		if err = f64.Compute(expected); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = f32.Compute(got); err != nil {
+			t.Fatal(err)
+		}
+
+		// float32 is only good to a few decimal places, and the DC bin is just rounding noise once the mean is removed
+		for i := 1; i < len(all32[0]); i++ {
+			for ch := range expected[i] {
+				if !closeTo(float64(got[i][ch]), expected[i][ch], 1e-3) || !closeTo(float64(all32[0][i][ch]), expected[i][ch], 1e-3) {
+					t.Fatalf("%s bin %d channel %d: float64 %v, FFTByFrame32 %v, FFTComputeAll32 %v", scaling, i, ch, expected[i][ch], got[i][ch], all32[0][i][ch])
+				}
+			}
+		}
+
+		f64.Close()
+		f32.Close()
+	}
+}
+
+// NewFFTComputeAll keeps its old output, power of the mean-removed frame
+func TestComputeAllLegacyScaling(t *testing.T) {
+	samples := stereoTestSignal(512)
+	legacy, err := fft.NewFFTComputeAll(newMemoryInput(1000, samples), time.Millisecond*128, time.Millisecond*64, fft.BlackmanNuttallWindow, false).ComputeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := fft.Options{Scaling: fft.Power, RemoveDC: true}
+	explicit, err := fft.NewFFTComputeAllOptions(newMemoryInput(1000, samples), time.Millisecond*128, time.Millisecond*64, fft.BlackmanNuttallWindow, opts).ComputeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for fI := range legacy {
+		for i := range legacy[fI] {
+			for ch := range legacy[fI][i] {
+				if legacy[fI][i][ch] != explicit[fI][i][ch] {
+					t.Fatalf("frame %d bin %d channel %d: %v != %v", fI, i, ch, legacy[fI][i][ch], explicit[fI][i][ch])
+				}
+			}
+		}
+
+		if legacy[fI][0][0] > 1e-20 {
+			t.Fatalf("frame %d: DC bin %v, expected the mean to be removed", fI, legacy[fI][0][0])
+		}
+	}
+}
+
+// a full scale sine centered on a bin reads 0 dBFS under any window, and the PSD integrates to its mean square
+func TestScalingReferenceValues(t *testing.T) {
+	const sampleRate, n, bin = 1024, 256, 32
+	frequency := float64(bin) * sampleRate / n
+	samples := util.Create2DFloats(n, 1)
+	for i := range samples {
+		samples[i][0] = math.Sin(2 * math.Pi * frequency * float64(i) / sampleRate)
+	}
+
+	windowSize := time.Duration(n) * time.Second / sampleRate
+	periodicHann := func(i, N float64) float64 {
+		return 0.5 - 0.5*math.Cos(2*math.Pi*i/N)
+	}
+
+	for _, window := range []fft.WindowingFunction{fft.NoWindow, periodicHann} {
+		f := fft.NewFFTByFrameOptions(newMemoryInput(sampleRate, samples), windowSize, windowSize, window, fft.Options{Scaling: fft.DBFS})
+		out := util.Create2DFloats(f.NumberOutputFrequencies(), 1)
+		if err := f.Compute(out); err != nil {
+			t.Fatal(err)
+		}
+
+		f.Close()
+		if math.Abs(out[bin][0]) > 1e-6 {
+			t.Fatalf("full scale sine read %v dBFS", out[bin][0])
+		}
+	}
+
+	f := fft.NewFFTByFrameOptions(newMemoryInput(sampleRate, samples), windowSize, windowSize, fft.NoWindow, fft.Options{Scaling: fft.PSD})
+	defer f.Close()
+	out := util.Create2DFloats(f.NumberOutputFrequencies(), 1)
+	if err := f.Compute(out); err != nil {
+		t.Fatal(err)
+	}
+
+	total := 0.0
+	for _, v := range out {
+		total += v[0] * sampleRate / n
+	}
+
+	if math.Abs(total-0.5) > 1e-9 {
+		t.Fatalf("PSD integrates to %v, expected 0.5", total)
+	}
+}
+
+func TestParseScaling(t *testing.T) {
+	for _, s := range allScalings() {
+		if parsed, err := fft.ParseScaling(s.String()); err != nil || parsed != s {
+			t.Errorf("ParseScaling(%q) = %v, %v", s.String(), parsed, err)
+		}
+	}
+
+	if _, err := fft.ParseScaling("loud"); err == nil {
+		t.Error("expected error for unknown scaling")
+	}
+}
@@ -0,0 +1,111 @@
+package fft
+
+import (
+	"fmt"
+	"strings"
+)
+
+// how the window length in samples is picked from the requested duration. Transforms are fastest when the size only has
+// small prime factors, FFTW and GoBackend both have dedicated code for 2, 3, 5 and 7
+type Sizing int
+
+const (
+	// the requested duration, to the sample
+	ExactSize Sizing = iota
+
+	// rounded up to the next power of two, what Options.PowTwo has always done. This can almost double the window
+	NextPow2Size
+
+	// rounded to the nearest size with no prime factor above 7, which is never more than a few percent away
+	NearestFastSize
+)
+
+var sizingNames = []string{"exact", "next-pow2", "nearest-fast"}
+
+func (s Sizing) String() string {
+	if s < 0 || int(s) >= len(sizingNames) {
+		return fmt.Sprintf("Sizing(%d)", int(s))
+	}
+
+	return sizingNames[s]
+}
+
+// parses the names returned by Sizing.String, case insensitive
+func ParseSizing(s string) (out Sizing, err error) {
+	for i, name := range sizingNames {
+		if strings.EqualFold(s, name) {
+			out = Sizing(i)
+			return
+		}
+	}
+
+	err = fmt.Errorf("unknown window sizing '%s'", s)
+	return
+}
+
+// the window length for a request of samples samples
+func (s Sizing) Size(samples int) int {
+	if samples <= 1 {
+		return samples
+	}
+
+	switch s {
+	case NextPow2Size:
+		return NextPower2(samples)
+	case NearestFastSize:
+		return ClosestFastSize(samples)
+	default:
+		return samples
+	}
+}
+
+// true when n has no prime factor above 7
+func IsFastSize(n int) bool {
+	if n <= 0 {
+		return false
+	}
+
+	for _, p := range []int{2, 3, 5, 7} {
+		for n%p == 0 {
+			n /= p
+		}
+	}
+
+	return n == 1
+}
+
+// the smallest fast size (see IsFastSize) at or above n
+func NextFastSize(n int) int {
+	if n < 1 {
+		return 1
+	}
+
+	for !IsFastSize(n) {
+		n++
+	}
+
+	return n
+}
+
+// the largest fast size (see IsFastSize) at or below n
+func PrevFastSize(n int) int {
+	if n < 1 {
+		return 1
+	}
+
+	for !IsFastSize(n) {
+		n--
+	}
+
+	return n
+}
+
+// the fast size (see IsFastSize) closest to n, ties go to the larger one
+func ClosestFastSize(n int) int {
+	above, below := NextFastSize(n), PrevFastSize(n)
+	if above-n <= n-below {
+		return above
+	}
+
+	return below
+}
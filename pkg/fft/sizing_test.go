@@ -0,0 +1,83 @@
+package fft_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/fft"
+)
+
+func TestSizing(t *testing.T) {
+	cases := []struct {
+		sizing        fft.Sizing
+		samples, size int
+	}{
+		{fft.ExactSize, 7276, 7276},
+		{fft.NextPow2Size, 7276, 8192},
+		{fft.NextPow2Size, 4096, 4096},
+		// the fast sizes either side of 7276 (2² 17 107) are 7203 (3 7⁴) and 7290 (2 3⁶ 5)
+		{fft.NearestFastSize, 7276, 7290},
+		{fft.NearestFastSize, 7210, 7203},
+		{fft.NearestFastSize, 7056, 7056},
+		{fft.NearestFastSize, 1, 1},
+	}
+
+	for _, c := range cases {
+		if size := c.sizing.Size(c.samples); size != c.size {
+			t.Errorf("%v of %d is %d, expected %d", c.sizing, c.samples, size, c.size)
+		}
+	}
+
+	for n := 1; n < 5000; n++ {
+		size := fft.ClosestFastSize(n)
+		if !fft.IsFastSize(size) {
+			t.Fatalf("%d is not fast", size)
+		}
+
+		for d := 1; d < abs(size-n); d++ {
+			if fft.IsFastSize(n+d) || fft.IsFastSize(n-d) {
+				t.Fatalf("%d is closer to %d than %d", n+d, n, size)
+			}
+		}
+
+		if fft.PrevFastSize(n) > n || fft.NextFastSize(n) < n {
+			t.Fatalf("%d is outside [%d, %d]", n, fft.PrevFastSize(n), fft.NextFastSize(n))
+		}
+	}
+
+	for _, s := range []fft.Sizing{fft.ExactSize, fft.NextPow2Size, fft.NearestFastSize} {
+		if parsed, err := fft.ParseSizing(s.String()); err != nil || parsed != s {
+			t.Errorf("%v parsed as %v (%v)", s, parsed, err)
+		}
+	}
+}
+
+// the analyzers agree on the window length for every policy, and PowTwo still means NextPow2Size
+func TestSizingAnalyzers(t *testing.T) {
+	samples := stereoTestSignal(2000)
+	for _, opts := range []fft.Options{{}, {PowTwo: true}, {Sizing: fft.NextPow2Size}, {Sizing: fft.NearestFastSize}} {
+		expected := 101
+		switch {
+		case opts.PowTwo || opts.Sizing == fft.NextPow2Size:
+			expected = 128
+		case opts.Sizing == fft.NearestFastSize:
+			expected = 100
+		}
+
+		byFrame := fft.NewFFTByFrameOptions(newMemoryInput(1000, samples), time.Millisecond*101, time.Millisecond*50, fft.HannWindow, opts)
+		all := fft.NewFFTComputeAllOptions(newMemoryInput(1000, samples), time.Millisecond*101, time.Millisecond*50, fft.HannWindow, opts)
+		if byFrame.FrameSize() != expected || all.FrameSize() != expected {
+			t.Errorf("%+v: FFTByFrame uses %d samples, FFTComputeAll %d, expected %d", opts, byFrame.FrameSize(), all.FrameSize(), expected)
+		}
+
+		byFrame.Close()
+	}
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+
+	return i
+}
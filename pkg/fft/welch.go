@@ -0,0 +1,238 @@
+package fft
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/audio"
+)
+
+// how Welch combines the periodograms of the segments
+type Averaging int
+
+const (
+	// the average, which is the usual Welch estimate
+	MeanAveraging Averaging = iota
+
+	// the median corrected for its bias (like scipy's average='median'), which ignores short loud events such as clicks
+	MedianAveraging
+
+	// the largest value each bin reached, for finding peaks that stick out anywhere in the track
+	MaxHold
+)
+
+var averagingNames = []string{"mean", "median", "max-hold"}
+
+func (a Averaging) String() string {
+	if a < 0 || int(a) >= len(averagingNames) {
+		return fmt.Sprintf("Averaging(%d)", int(a))
+	}
+
+	return averagingNames[a]
+}
+
+// parses the names returned by Averaging.String, case insensitive
+func ParseAveraging(s string) (out Averaging, err error) {
+	for i, name := range averagingNames {
+		if strings.EqualFold(s, name) {
+			out = Averaging(i)
+			return
+		}
+	}
+
+	err = fmt.Errorf("unknown averaging '%s'", s)
+	return
+}
+
+type WelchConfig struct {
+	// samples in each segment, 0 means 4096
+	Segment int
+
+	// the fraction of each segment shared with the next, from 0 up to (not including) 1
+	Overlap float64
+
+	// nil means HannWindow
+	Window WindowingFunction
+
+	Averaging Averaging
+}
+
+const (
+	defaultWelchSegment = 4096
+
+	// segments transformed per batch
+	welchChunkFrames = 64
+)
+
+// scipy.signal.welch's defaults, half overlapping Hann windowed segments
+func DefaultWelchConfig() WelchConfig {
+	return WelchConfig{Segment: defaultWelchSegment, Overlap: 0.5, Window: HannWindow}
+}
+
+// a whole track's power spectral density
+type Spectrum struct {
+	// the frequency of each bin in Hz
+	Frequencies []float64
+
+	// indexed [bin][channel], one sided in units² / Hz (see PSD)
+	Values [][]float64
+
+	// how many segments were averaged
+	Segments int
+}
+
+// estimates the power spectral density of a whole input by Welch's method, the average of the periodograms of
+// overlapping segments. The segments are transformed in batches with FFTComputeAll, so like its output the Nyquist bin is
+// left out. opts picks the backend, planning, cache and zero padding, and RemoveDC works like scipy's detrend='constant'.
+// Its Scaling and Sizing are ignored. MedianAveraging keeps every segment (as float32) until the end
+func Welch(input audio.Input, cfg WelchConfig, opts Options) (out Spectrum, err error) {
+	segment := cfg.Segment
+	if segment <= 0 {
+		segment = defaultWelchSegment
+	}
+
+	window := cfg.Window
+	if window == nil {
+		window = HannWindow
+	}
+
+	hop := int(math.Round(float64(segment) * (1 - cfg.Overlap)))
+	if hop < 1 || hop > segment {
+		err = fmt.Errorf("overlap %v must be in [0, 1)", cfg.Overlap)
+		return
+	}
+
+	opts.Scaling = PSD
+	opts.PowTwo = false
+	opts.Sizing = ExactSize
+	analyzer := NewFFTComputeAllOptions(input, time.Duration(segment)*input.Timebase(), time.Duration(hop)*input.Timebase(), window, opts)
+
+	bins, channels := analyzer.FFTSize()/2, input.Channels()
+	out.Frequencies = make([]float64, bins)
+	for k := range out.Frequencies {
+		out.Frequencies[k] = float64(k) * float64(input.SampleRate()) / float64(analyzer.FFTSize())
+	}
+
+	out.Values = make([][]float64, bins)
+	for k := range out.Values {
+		out.Values[k] = make([]float64, channels)
+	}
+
+	// every segment's value of each bin, indexed [bin][channel][segment]
+	var all [][][]float32
+	if cfg.Averaging == MedianAveraging {
+		all = make([][][]float32, bins)
+		for k := range all {
+			all[k] = make([][]float32, channels)
+		}
+	}
+
+	err = analyzer.ComputeChunked(welchChunkFrames, func(c Chunk) error {
+		for _, frame := range c.Data {
+			for k, bin := range frame {
+				for ch, v := range bin {
+					switch cfg.Averaging {
+					case MedianAveraging:
+						all[k][ch] = append(all[k][ch], float32(v))
+					case MaxHold:
+						out.Values[k][ch] = math.Max(out.Values[k][ch], v)
+					default:
+						out.Values[k][ch] += v
+					}
+				}
+			}
+		}
+
+		out.Segments += len(c.Data)
+		return nil
+	})
+
+	if err != nil || out.Segments == 0 {
+		return
+	}
+
+	switch cfg.Averaging {
+	case MedianAveraging:
+		bias := medianBias(out.Segments)
+		for k, bin := range all {
+			for ch, values := range bin {
+				out.Values[k][ch] = median(values) / bias
+			}
+		}
+	case MaxHold:
+		// already the largest
+	default:
+		for _, bin := range out.Values {
+			for ch := range bin {
+				bin[ch] /= float64(out.Segments)
+			}
+		}
+	}
+
+	return
+}
+
+func median(values []float32) float64 {
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	mid := len(values) / 2
+	if len(values)%2 == 1 {
+		return float64(values[mid])
+	}
+
+	return (float64(values[mid-1]) + float64(values[mid])) / 2
+}
+
+// the median of n periodogram values (χ² with 2 degrees of freedom) over their mean, which tends to ln 2. The same as
+// scipy's _median_bias
+func medianBias(n int) float64 {
+	bias := 1.0
+	for i := 1; i <= (n-1)/2; i++ {
+		bias += 1/float64(2*i+1) - 1/float64(2*i)
+	}
+
+	return bias
+}
+
+// the values in dB, 10 log10(v), with zeros clamped to MinDBFS
+func (s Spectrum) Decibels() (out [][]float64) {
+	out = make([][]float64, len(s.Values))
+	for k, bin := range s.Values {
+		out[k] = make([]float64, len(bin))
+		for ch, v := range bin {
+			out[k][ch] = math.Max(10*math.Log10(v), MinDBFS)
+		}
+	}
+
+	return
+}
+
+// writes a header ("frequency,channel0,channel1...") then one line per bin
+func (s Spectrum) WriteCSV(w io.Writer) (err error) {
+	header := "frequency"
+	if len(s.Values) > 0 {
+		for ch := range s.Values[0] {
+			header += fmt.Sprintf(",channel%d", ch)
+		}
+	}
+
+	if _, err = fmt.Fprintln(w, header); err != nil {
+		return
+	}
+
+	for k, f := range s.Frequencies {
+		line := fmt.Sprintf("%g", f)
+		for _, v := range s.Values[k] {
+			line += fmt.Sprintf(",%g", v)
+		}
+
+		if _, err = fmt.Fprintln(w, line); err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,117 @@
+package fft_test
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/Twister915/vis.go/pkg/fft"
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+const welchTestRate = 8000
+
+func welchTestSignal(seconds int, noise, sineAmplitude float64) [][]float64 {
+	random := rand.New(rand.NewSource(11))
+	samples := util.Create2DFloats(seconds*welchTestRate, 2)
+	for i := range samples {
+		samples[i][0] = noise * random.NormFloat64()
+		samples[i][1] = sineAmplitude * math.Sin(2*math.Pi*1000*float64(i)/welchTestRate)
+	}
+
+	return samples
+}
+
+// white noise of variance σ² has a flat one sided density of 2σ² / fs, and the mean, median and max-hold estimates
+// all land close to it (max-hold above it)
+func TestWelchWhiteNoise(t *testing.T) {
+	const sigma = 0.1
+	expected := 2 * sigma * sigma / welchTestRate
+	samples := welchTestSignal(30, sigma, 0)
+	for _, averaging := range []fft.Averaging{fft.MeanAveraging, fft.MedianAveraging, fft.MaxHold} {
+		cfg := fft.DefaultWelchConfig()
+		cfg.Segment = 512
+		cfg.Averaging = averaging
+		spectrum, err := fft.Welch(newMemoryInput(welchTestRate, samples), cfg, fft.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(spectrum.Frequencies) != 256 || spectrum.Frequencies[1] != welchTestRate/512.0 || spectrum.Segments < 900 {
+			t.Fatalf("%d bins, %v Hz apart, %d segments", len(spectrum.Frequencies), spectrum.Frequencies[1], spectrum.Segments)
+		}
+
+		var mean float64
+		for _, bin := range spectrum.Values[1:] {
+			mean += bin[0]
+		}
+
+		mean /= float64(len(spectrum.Values) - 1)
+		switch averaging {
+		case fft.MaxHold:
+			if mean < 3*expected {
+				t.Fatalf("max-hold %v, the mean is %v", mean, expected)
+			}
+		default:
+			if !closeTo(mean, expected, 0.03) {
+				t.Fatalf("%s: %v, expected %v", averaging, mean, expected)
+			}
+		}
+	}
+}
+
+// the density of a sine summed over its peak times the bin width is its mean square, A² / 2
+func TestWelchSinePower(t *testing.T) {
+	const amplitude = 0.5
+	spectrum, err := fft.Welch(newMemoryInput(welchTestRate, welchTestSignal(5, 0, amplitude)), fft.DefaultWelchConfig(), fft.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	binWidth := spectrum.Frequencies[1]
+	var power float64
+	peak := 0
+	for k, bin := range spectrum.Values {
+		power += bin[1] * binWidth
+		if bin[1] > spectrum.Values[peak][1] {
+			peak = k
+		}
+	}
+
+	if !closeTo(power, amplitude*amplitude/2, 0.01) || spectrum.Frequencies[peak] != 1000 {
+		t.Fatalf("power %v at %v Hz", power, spectrum.Frequencies[peak])
+	}
+
+	if db := spectrum.Decibels(); !closeTo(db[peak][1], 10*math.Log10(spectrum.Values[peak][1]), 1e-12) || db[peak][0] != fft.MinDBFS {
+		t.Fatalf("%v dB and %v dB", db[peak][1], db[peak][0])
+	}
+}
+
+func TestWelchCSV(t *testing.T) {
+	spectrum := fft.Spectrum{Frequencies: []float64{0, 10}, Values: [][]float64{{1, 2}, {0.5, 0.25}}}
+	var out bytes.Buffer
+	if err := spectrum.WriteCSV(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "frequency,channel0,channel1\n0,1,2\n10,0.5,0.25\n"
+	if out.String() != expected {
+		t.Fatalf("%q", out.String())
+	}
+}
+
+func TestWelchConfigErrors(t *testing.T) {
+	cfg := fft.DefaultWelchConfig()
+	cfg.Overlap = 1
+	if _, err := fft.Welch(newMemoryInput(welchTestRate, welchTestSignal(1, 0.1, 0)), cfg, fft.Options{}); err == nil || !strings.Contains(err.Error(), "overlap") {
+		t.Fatalf("expected an overlap error, got %v", err)
+	}
+
+	for _, averaging := range []fft.Averaging{fft.MeanAveraging, fft.MedianAveraging, fft.MaxHold} {
+		if parsed, err := fft.ParseAveraging(averaging.String()); err != nil || parsed != averaging {
+			t.Fatalf("%s parsed as %v, %v", averaging, parsed, err)
+		}
+	}
+}
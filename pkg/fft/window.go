@@ -12,10 +12,16 @@ func HammingWindow(n, N float64) float64 {
 		TWOPI = math.Pi * 2
 	)
 
-	bt := beta * math.Cos((TWOPI*float64(n))/N-1)
+	bt := beta * math.Cos((TWOPI*float64(n))/(N-1))
 	return alpha - bt
 }
 
+func HannWindow(n, N float64) float64 {
+	const TWOPI = float64(math.Pi * 2)
+
+	return 0.5 - 0.5*math.Cos((TWOPI*float64(n))/(N-1))
+}
+
 func BlackmanWindow(n, N float64) float64 {
 	const (
 		a0 = 0.42659
@@ -51,6 +57,103 @@ func BlackmanNuttallWindow(n, N float64) float64 {
 	return a0 - a1t + a2t - a3t
 }
 
+// the 4 term, -92 dB Blackman-Harris window
+func BlackmanHarrisWindow(n, N float64) float64 {
+	const (
+		a0 = 0.35875
+		a1 = 0.48829
+		a2 = 0.14128
+		a3 = 0.01168
+
+		TWOPI  = float64(math.Pi * 2)
+		FOURPI = float64(math.Pi * 4)
+		SIXPI  = float64(math.Pi * 6)
+	)
+
+	nMinusOne := N - 1
+	a1t := a1 * math.Cos((TWOPI*float64(n))/nMinusOne)
+	a2t := a2 * math.Cos((FOURPI*float64(n))/nMinusOne)
+	a3t := a3 * math.Cos((SIXPI*float64(n))/nMinusOne)
+	return a0 - a1t + a2t - a3t
+}
+
+// a flat-top window (the same coefficients as MATLAB's flattopwin), its scalloping loss is close to 0 so the magnitude of
+// a sinusoid is read correctly wherever it falls between bins, at the cost of a very wide main lobe
+func FlatTopWindow(n, N float64) float64 {
+	const (
+		a0 = 0.21557895
+		a1 = 0.41663158
+		a2 = 0.277263158
+		a3 = 0.083578947
+		a4 = 0.006947368
+
+		TWOPI   = float64(math.Pi * 2)
+		FOURPI  = float64(math.Pi * 4)
+		SIXPI   = float64(math.Pi * 6)
+		EIGHTPI = float64(math.Pi * 8)
+	)
+
+	nMinusOne := N - 1
+	a1t := a1 * math.Cos((TWOPI*float64(n))/nMinusOne)
+	a2t := a2 * math.Cos((FOURPI*float64(n))/nMinusOne)
+	a3t := a3 * math.Cos((SIXPI*float64(n))/nMinusOne)
+	a4t := a4 * math.Cos((EIGHTPI*float64(n))/nMinusOne)
+	return a0 - a1t + a2t - a3t + a4t
+}
+
+// the Kaiser window, beta trades main lobe width for side lobe level (0 is rectangular, around 8.6 is similar to
+// Blackman)
+func KaiserWindow(beta float64) WindowingFunction {
+	denominator := besselI0(beta)
+	return func(n, N float64) float64 {
+		x := (2*n)/(N-1) - 1
+		return besselI0(beta*math.Sqrt(1-x*x)) / denominator
+	}
+}
+
+// a Gaussian window, sigma is the standard deviation relative to half the window (so 0.4 puts the edges 2.5 standard
+// deviations from the center)
+func GaussianWindow(sigma float64) WindowingFunction {
+	return func(n, N float64) float64 {
+		half := (N - 1) / 2
+		x := (n - half) / (sigma * half)
+		return math.Exp(-0.5 * x * x)
+	}
+}
+
+// the Tukey (tapered cosine) window, alpha is the fraction of the window inside the cosine tapers. 0 is rectangular,
+// and 1 is Hann
+func TukeyWindow(alpha float64) WindowingFunction {
+	return func(n, N float64) float64 {
+		if alpha <= 0 {
+			return 1
+		}
+
+		x := n / (N - 1)
+		switch {
+		case x < alpha/2:
+			return 0.5 * (1 + math.Cos(math.Pi*(2*x/alpha-1)))
+		case x > 1-alpha/2:
+			return 0.5 * (1 + math.Cos(math.Pi*(2*x/alpha-2/alpha+1)))
+		default:
+			return 1
+		}
+	}
+}
+
 func NoWindow(n, N float64) float64 {
 	return 1
 }
+
+// modified Bessel function of the first kind, order 0
+func besselI0(x float64) (sum float64) {
+	term := 1.0
+	sum = 1
+	for k := 1; term > sum*1e-17; k++ {
+		half := x / (2 * float64(k))
+		term *= half * half
+		sum += term
+	}
+
+	return
+}
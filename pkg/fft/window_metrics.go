@@ -0,0 +1,45 @@
+package fft
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// properties of a window at a size of n samples, for reading levels off a windowed spectrum
+
+// the mean of the window, a sinusoid exactly on a bin comes out this much smaller than without a window
+func CoherentGain(window WindowingFunction, n int) float64 {
+	var sum float64
+	for i := 0; i < n; i++ {
+		sum += window(float64(i), float64(n))
+	}
+
+	return sum / float64(n)
+}
+
+// equivalent noise bandwidth in bins, the width of the rectangular filter which would let through as much white noise as
+// one bin does under this window. The rectangular window's is 1
+func ENBW(window WindowingFunction, n int) float64 {
+	var sum, squares float64
+	for i := 0; i < n; i++ {
+		w := window(float64(i), float64(n))
+		sum += w
+		squares += w * w
+	}
+
+	return float64(n) * squares / (sum * sum)
+}
+
+// how many dB lower a sinusoid halfway between two bins reads than one exactly on a bin, the worst case for a peak's
+// magnitude
+func ScallopingLoss(window WindowingFunction, n int) float64 {
+	var sum float64
+	var half complex128
+	for i := 0; i < n; i++ {
+		w := window(float64(i), float64(n))
+		sum += w
+		half += complex(w, 0) * cmplx.Rect(1, -math.Pi*float64(i)/float64(n))
+	}
+
+	return 20 * math.Log10(sum/cmplx.Abs(half))
+}
@@ -0,0 +1,251 @@
+package fft
+
+import (
+	"math"
+	"math/cmplx"
+	"sync"
+	"sync/atomic"
+)
+
+// windows with no closed form, which are solved for a whole length at once. Each length is computed the first time it
+// is used, and the last few are kept
+type tabulatedWindow struct {
+	compute func(n int) []float64
+
+	// the table used last, *windowTable. Filling a window asks for every sample of one length in turn, which this
+	// answers without locking
+	latest atomic.Value
+
+	mutex sync.Mutex
+	// the oldest first
+	tables []*windowTable
+}
+
+type windowTable struct {
+	size   int
+	values []float64
+}
+
+// lengths kept by each tabulated window
+const maxWindowTables = 4
+
+func newTabulatedWindow(compute func(n int) []float64) WindowingFunction {
+	t := &tabulatedWindow{compute: compute}
+	return t.at
+}
+
+func (t *tabulatedWindow) at(n, N float64) float64 {
+	size := int(N)
+	if latest, _ := t.latest.Load().(*windowTable); latest != nil && latest.size == size {
+		return latest.values[int(n)]
+	}
+
+	return t.table(size).values[int(n)]
+}
+
+// finds or computes the table for size, and makes it the latest
+func (t *tabulatedWindow) table(size int) (out *windowTable) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for _, table := range t.tables {
+		if table.size == size {
+			out = table
+			break
+		}
+	}
+
+	if out == nil {
+		out = &windowTable{size: size, values: t.compute(size)}
+		t.tables = append(t.tables, out)
+		if over := len(t.tables) - maxWindowTables; over > 0 {
+			t.tables = append(t.tables[:0], t.tables[over:]...)
+		}
+	}
+
+	t.latest.Store(out)
+	return
+}
+
+// the Dolph-Chebyshev window, every side lobe is attenuation dB below the main lobe, which is as narrow as that allows
+func DolphChebyshevWindow(attenuation float64) WindowingFunction {
+	return newTabulatedWindow(func(n int) []float64 {
+		return dolphChebyshev(n, math.Abs(attenuation))
+	})
+}
+
+// the window is the inverse DFT of the Chebyshev polynomial of order n - 1, sampled so its equiripple region covers
+// everything outside the main lobe
+func dolphChebyshev(n int, attenuation float64) (out []float64) {
+	out = make([]float64, n)
+	if n == 1 {
+		out[0] = 1
+		return
+	}
+
+	order := float64(n - 1)
+	beta := math.Cosh(math.Acosh(math.Pow(10, attenuation/20)) / order)
+
+	p := make([]complex128, n)
+	for k := range p {
+		x := beta * math.Cos(math.Pi*float64(k)/float64(n))
+		var v float64
+		switch {
+		case x > 1:
+			v = math.Cosh(order * math.Acosh(x))
+		case x < -1:
+			// T(-x) = (-1)^order T(x)
+			v = math.Cosh(order * math.Acosh(-x))
+			if n%2 == 0 {
+				v = -v
+			}
+		default:
+			v = math.Cos(order * math.Acos(x))
+		}
+
+		p[k] = complex(v, 0)
+		if n%2 == 0 {
+			// half a sample delay, so the even length window is centered
+			p[k] *= cmplx.Rect(1, math.Pi*float64(k)/float64(n))
+		}
+	}
+
+	w := make([]complex128, n)
+	transform := newComplexFFT(n)
+	transform.transform(p, w, transform.newScratch())
+
+	// w holds the right half of the window, starting from the center
+	if n%2 == 1 {
+		half := (n + 1) / 2
+		for i := 0; i < half; i++ {
+			out[half-1+i] = real(w[i])
+			out[half-1-i] = real(w[i])
+		}
+	} else {
+		half := n / 2
+		for i := 1; i <= half; i++ {
+			out[half-1+i] = real(w[i])
+			out[half-i] = real(w[i])
+		}
+	}
+
+	normalizeCenter(out)
+	return
+}
+
+// the first discrete prolate spheroidal (Slepian) sequence, the window with the most energy within nw bins of its
+// center. It is very close to a Kaiser window with a beta of π nw
+func DPSSWindow(nw float64) WindowingFunction {
+	return newTabulatedWindow(func(n int) []float64 {
+		return dpss(n, nw)
+	})
+}
+
+// the sequence is the eigenvector of the largest eigenvalue of a tridiagonal matrix which commutes with the
+// concentration problem's. The eigenvalue is found by bisection, then the vector by inverse iteration
+func dpss(n int, nw float64) (out []float64) {
+	out = make([]float64, n)
+	if n == 1 {
+		out[0] = 1
+		return
+	}
+
+	w := nw / float64(n)
+	diagonal := make([]float64, n)
+	off := make([]float64, n) // off[i] joins i - 1 and i, off[0] is unused
+	for i := range diagonal {
+		c := (float64(n-1) - 2*float64(i)) / 2
+		diagonal[i] = c * c * math.Cos(2*math.Pi*w)
+		if i > 0 {
+			off[i] = float64(i*(n-i)) / 2
+		}
+	}
+
+	// Gershgorin bounds every eigenvalue
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i, d := range diagonal {
+		radius := off[i]
+		if i+1 < n {
+			radius += off[i+1]
+		}
+
+		lo = math.Min(lo, d-radius)
+		hi = math.Max(hi, d+radius)
+	}
+
+	// the number of eigenvalues below x, from the signs of the pivots of T - xI
+	below := func(x float64) (count int) {
+		q := 1.0
+		for i, d := range diagonal {
+			if i == 0 {
+				q = d - x
+			} else {
+				q = d - x - off[i]*off[i]/q
+			}
+
+			if q == 0 {
+				q = 1e-300
+			}
+
+			if q < 0 {
+				count++
+			}
+		}
+
+		return
+	}
+
+	for iteration := 0; iteration < 200 && hi-lo > 1e-15*math.Max(math.Abs(lo), math.Abs(hi)); iteration++ {
+		mid := (lo + hi) / 2
+		if below(mid) == n {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+
+	// solve (T - λI) x = b repeatedly, λ is nudged up so the system is not exactly singular
+	lambda := hi + 1e-10*math.Max(1, math.Abs(hi))
+	for i := range out {
+		out[i] = 1
+	}
+
+	pivots := make([]float64, n)
+	for iteration := 0; iteration < 3; iteration++ {
+		// forward elimination (Thomas algorithm), the matrix is symmetric so the upper diagonal is off too
+		for i := range out {
+			pivot := diagonal[i] - lambda
+			if i > 0 {
+				factor := off[i] / pivots[i-1]
+				pivot -= factor * off[i]
+				out[i] -= factor * out[i-1]
+			}
+
+			if pivot == 0 {
+				pivot = 1e-300
+			}
+
+			pivots[i] = pivot
+		}
+
+		for i := n - 1; i >= 0; i-- {
+			if i+1 < n {
+				out[i] -= off[i+1] * out[i+1]
+			}
+
+			out[i] /= pivots[i]
+		}
+
+		normalizeCenter(out)
+	}
+
+	return
+}
+
+// scales values so the center is 1. Not the largest value, long Dolph-Chebyshev windows have spikes at their ends
+func normalizeCenter(values []float64) {
+	center := values[len(values)/2]
+	for i := range values {
+		values[i] /= center
+	}
+}
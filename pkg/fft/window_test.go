@@ -0,0 +1,217 @@
+package fft_test
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"sync"
+	"testing"
+
+	"github.com/Twister915/vis.go/pkg/fft"
+)
+
+func TestHammingWindow(t *testing.T) {
+	const n = 65
+	for i := 0; i < n; i++ {
+		if a, b := fft.HammingWindow(float64(i), n), fft.HammingWindow(float64(n-1-i), n); math.Abs(a-b) > 1e-12 {
+			t.Fatalf("not symmetric at %d: %v != %v", i, a, b)
+		}
+	}
+
+	if edge := fft.HammingWindow(0, n); math.Abs(edge-(0.53836-0.46164)) > 1e-12 {
+		t.Fatalf("edge is %v", edge)
+	}
+
+	if center := fft.HammingWindow((n-1)/2, n); math.Abs(center-1) > 1e-12 {
+		t.Fatalf("center is %v", center)
+	}
+}
+
+// reference values from Harris, "On the Use of Windows for Harmonic Analysis with the Discrete Fourier Transform"
+// (1978), table 1, given to 2 decimals. The rest are derived from the window's definition, and NaN has no reference
+var windowReferences = []struct {
+	name                        string
+	window                      fft.WindowingFunction
+	enbw, scallop, coherentGain float64
+}{
+	{"rectangular", fft.NoWindow, 1.00, 3.92, 1},
+	{"hann", fft.HannWindow, 1.50, 1.42, 0.50},
+	// cosine sums have a gain of a0, and an ENBW of 1 + Σ ak² / 2 a0²
+	{"hamming", fft.HammingWindow, 1 + 0.46164*0.46164/(2*0.53836*0.53836), math.NaN(), 0.53836},
+	{"exact blackman", fft.BlackmanWindow, 1.69, 1.15, 0.43},
+	{"blackman-harris", fft.BlackmanHarrisWindow, 2.00, 0.83, 0.36},
+	{"flat-top", fft.FlatTopWindow, 1 + (0.41663158*0.41663158+0.277263158*0.277263158+0.083578947*0.083578947+0.006947368*0.006947368)/(2*0.21557895*0.21557895), math.NaN(), 0.21557895},
+	{"kaiser 2", fft.KaiserWindow(2 * math.Pi), 1.50, 1.46, 0.49},
+	{"kaiser 2.5", fft.KaiserWindow(2.5 * math.Pi), 1.65, 1.20, 0.44},
+	{"kaiser 3", fft.KaiserWindow(3 * math.Pi), 1.80, 1.02, 0.40},
+	{"kaiser 3.5", fft.KaiserWindow(3.5 * math.Pi), 1.93, 0.89, 0.37},
+	{"gaussian 2.5", fft.GaussianWindow(1 / 2.5), gaussianENBW(2.5), math.NaN(), gaussianGain(2.5)},
+	{"gaussian 3.5", fft.GaussianWindow(1 / 3.5), gaussianENBW(3.5), math.NaN(), gaussianGain(3.5)},
+	{"tukey 0.25", fft.TukeyWindow(0.25), 1.10, 2.96, 0.88},
+	{"tukey 0.5", fft.TukeyWindow(0.5), 1.22, 2.24, 0.75},
+	{"tukey 0.75", fft.TukeyWindow(0.75), 1.36, 1.73, 0.63},
+	// Harris's ENBW & scalloping loss for these depend on the length, see TestDolphChebyshevSidelobes
+	{"dolph-chebyshev 60", fft.DolphChebyshevWindow(60), math.NaN(), math.NaN(), 0.48},
+	{"dolph-chebyshev 80", fft.DolphChebyshevWindow(80), math.NaN(), math.NaN(), 0.42},
+}
+
+// a Gaussian truncated at alpha standard deviations, as a continuous window over [-1, 1]
+func gaussianGain(alpha float64) float64 {
+	return math.Sqrt(2*math.Pi) / alpha * math.Erf(alpha/math.Sqrt2) / 2
+}
+
+func gaussianENBW(alpha float64) float64 {
+	squares := math.Sqrt(math.Pi) / alpha * math.Erf(alpha)
+	sum := math.Sqrt(2*math.Pi) / alpha * math.Erf(alpha/math.Sqrt2)
+	return 2 * squares / (sum * sum)
+}
+
+func TestWindowMetrics(t *testing.T) {
+	const n, tolerance = 4096, 0.01
+	for _, ref := range windowReferences {
+		check := func(metric string, got, want float64) {
+			if !math.IsNaN(want) && math.Abs(got-want) > tolerance {
+				t.Errorf("%s %s is %.4f, expected %.4f", ref.name, metric, got, want)
+			}
+		}
+
+		check("ENBW", fft.ENBW(ref.window, n), ref.enbw)
+		check("scalloping loss", fft.ScallopingLoss(ref.window, n), ref.scallop)
+		check("coherent gain", fft.CoherentGain(ref.window, n), ref.coherentGain)
+	}
+
+	if loss := fft.ScallopingLoss(fft.FlatTopWindow, n); math.Abs(loss) > 0.01 {
+		t.Errorf("flat-top scalloping loss is %.4f dB", loss)
+	}
+}
+
+// the highest side lobe of a Dolph-Chebyshev window is at the requested attenuation
+func TestDolphChebyshevSidelobes(t *testing.T) {
+	for _, n := range []int{31, 64} {
+		for _, attenuation := range []float64{40, 60, 100} {
+			window := fft.DolphChebyshevWindow(attenuation)
+			samples := make([]float64, n)
+			for i := range samples {
+				samples[i] = window(float64(i), float64(n))
+				if mirror := window(float64(n-1-i), float64(n)); math.Abs(samples[i]-mirror) > 1e-9 {
+					t.Fatalf("n %d: not symmetric at %d", n, i)
+				}
+			}
+
+			// the response sampled finely, the main lobe ends at the first minimum
+			const points = 4096
+			response := make([]float64, points)
+			for k := range response {
+				var sum complex128
+				for i, w := range samples {
+					sum += complex(w, 0) * cmplx.Rect(1, -math.Pi*float64(k*i)/points)
+				}
+
+				response[k] = cmplx.Abs(sum)
+			}
+
+			k := 1
+			for k < points && response[k] < response[k-1] {
+				k++
+			}
+
+			var sidelobe float64
+			for _, v := range response[k:] {
+				sidelobe = math.Max(sidelobe, v)
+			}
+
+			if level := 20 * math.Log10(response[0]/sidelobe); math.Abs(level-attenuation) > 0.1 {
+				t.Errorf("n %d, %v dB: side lobes are %.3f dB down", n, attenuation, level)
+			}
+		}
+	}
+}
+
+// tabulated windows give the same values however many lengths are used, in whatever order and from several goroutines
+func TestTabulatedWindowLengths(t *testing.T) {
+	shared := fft.DolphChebyshevWindow(60)
+	lengths := []int{16, 17, 31, 64, 100, 128, 255}
+
+	var wg sync.WaitGroup
+	errs := make(chan string, len(lengths))
+	for _, n := range lengths {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+
+			fresh := fft.DolphChebyshevWindow(60)
+			for pass := 0; pass < 3; pass++ {
+				for _, other := range lengths {
+					// interleaved with the other lengths, and with the other goroutines
+					shared(0, float64(other))
+					for i := 0; i < n; i++ {
+						if a, b := shared(float64(i), float64(n)), fresh(float64(i), float64(n)); a != b {
+							errs <- fmt.Sprintf("n %d: sample %d is %v, expected %v", n, i, a, b)
+							return
+						}
+					}
+				}
+			}
+		}(n)
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+// fraction of the window's energy within nw bins of 0, the quantity the DPSS window maximizes
+func concentration(window fft.WindowingFunction, n int, nw float64) float64 {
+	w := nw / float64(n)
+	samples := make([]float64, n)
+	for i := range samples {
+		samples[i] = window(float64(i), float64(n))
+	}
+
+	var inBand, total float64
+	for i, a := range samples {
+		total += a * a
+		for j, b := range samples {
+			if i == j {
+				inBand += a * b * 2 * w
+			} else {
+				d := float64(i - j)
+				inBand += a * b * math.Sin(2*math.Pi*w*d) / (math.Pi * d)
+			}
+		}
+	}
+
+	return inBand / total
+}
+
+func TestDPSSWindow(t *testing.T) {
+	for _, n := range []int{63, 128} {
+		for _, nw := range []float64{2, 4} {
+			dpss := concentration(fft.DPSSWindow(nw), n, nw)
+			kaiser := concentration(fft.KaiserWindow(math.Pi*nw), n, nw)
+			if dpss < kaiser || dpss < 0.999 {
+				t.Errorf("n %d, nw %v: concentration %v, kaiser's is %v", n, nw, dpss, kaiser)
+			}
+
+			window := fft.DPSSWindow(nw)
+			if center := window(float64(n/2), float64(n)); math.Abs(center-1) > 1e-9 {
+				t.Errorf("n %d, nw %v: center is %v", n, nw, center)
+			}
+		}
+	}
+}
+
+func TestTukeyWindowLimits(t *testing.T) {
+	const n = 101
+	for i := 0; i < n; i++ {
+		if w := fft.TukeyWindow(0)(float64(i), n); w != 1 {
+			t.Fatalf("tukey 0 at %d is %v", i, w)
+		}
+
+		if a, b := fft.TukeyWindow(1)(float64(i), n), fft.HannWindow(float64(i), n); math.Abs(a-b) > 1e-12 {
+			t.Fatalf("tukey 1 at %d is %v, hann is %v", i, a, b)
+		}
+	}
+}
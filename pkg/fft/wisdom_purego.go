@@ -0,0 +1,16 @@
+//go:build !cgo || purego
+// +build !cgo purego
+
+package fft
+
+// GoBackend has no plans to remember, so wisdom is a no-op without FFTW
+
+func ImportWisdom(file string) error {
+	return nil
+}
+
+func ExportWisdom(file string) error {
+	return nil
+}
+
+func ForgetWisdom() {}
@@ -0,0 +1,221 @@
+package hpss
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/audio"
+	"github.com/Twister915/vis.go/pkg/fft"
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+// harmonic/percussive source separation by median filtering (Fitzgerald, 2010). Harmonic sounds are steady in time, so
+// they are horizontal lines in a spectrogram, and percussive ones are broadband, so they are vertical lines. A median
+// across time keeps the first and a median across frequency the second, and comparing the two decides how much of each
+// bin belongs to which. The results match librosa's decompose.hpss
+
+type Config struct {
+	// frames in the median across time which finds the harmonic part, 0 means 31. Even sizes are rounded up to odd
+	HarmonicKernel int
+
+	// bins in the median across frequency which finds the percussive part, 0 means 31. Even sizes are rounded up to odd
+	PercussiveKernel int
+
+	// the exponent of the soft masks, 0 means 2 (Wiener filtering). math.Inf(1) makes hard masks of 0 or 1
+	Power float64
+
+	// above 1 a bin only goes to one part when it is this many times larger in that part than the other, and what goes to
+	// neither is the residual. 0 means 1, where the masks add up to 1
+	Margin float64
+}
+
+const (
+	defaultKernel = 31
+	defaultPower  = 2.0
+)
+
+func (c Config) kernels() (harmonic, percussive int) {
+	harmonic, percussive = c.HarmonicKernel, c.PercussiveKernel
+	if harmonic <= 0 {
+		harmonic = defaultKernel
+	}
+
+	if percussive <= 0 {
+		percussive = defaultKernel
+	}
+
+	harmonic |= 1
+	percussive |= 1
+	return
+}
+
+func (c Config) power() float64 {
+	if c.Power <= 0 {
+		return defaultPower
+	}
+
+	return c.Power
+}
+
+func (c Config) margin() float64 {
+	if c.Margin <= 0 {
+		return 1
+	}
+
+	return c.Margin
+}
+
+// the share of each bin belonging to each part, from 0 to 1 and indexed [frame][bin][channel]
+type Masks struct {
+	Harmonic, Percussive [][][]float64
+}
+
+// the masks of a magnitude (or power) spectrogram indexed [frame][bin][channel], like FFTComputeAll.ComputeAll's with
+// Scaling: fft.Magnitude or Magnitudes of a complex one. The medians reflect at the edges of the spectrogram
+func ComputeMasks(spectrogram [][][]float64, cfg Config) (out Masks) {
+	if len(spectrogram) == 0 || len(spectrogram[0]) == 0 {
+		return
+	}
+
+	frames, bins, channels := len(spectrogram), len(spectrogram[0]), len(spectrogram[0][0])
+	harmonicKernel, percussiveKernel := cfg.kernels()
+	power, margin := cfg.power(), cfg.margin()
+
+	out.Harmonic = util.CreateNDFloat64(frames, bins, channels).([][][]float64)
+	out.Percussive = util.CreateNDFloat64(frames, bins, channels).([][][]float64)
+
+	scratch := make([]float64, int(math.Max(float64(harmonicKernel), float64(percussiveKernel))))
+	for f := range spectrogram {
+		for k := range spectrogram[f] {
+			for ch := range spectrogram[f][k] {
+				window := scratch[:harmonicKernel]
+				for i := range window {
+					window[i] = spectrogram[reflect(f+i-harmonicKernel/2, frames)][k][ch]
+				}
+
+				harmonic := median(window)
+
+				window = scratch[:percussiveKernel]
+				for i := range window {
+					window[i] = spectrogram[f][reflect(k+i-percussiveKernel/2, bins)][ch]
+				}
+
+				percussive := median(window)
+
+				out.Harmonic[f][k][ch] = softMask(harmonic, percussive*margin, power)
+				out.Percussive[f][k][ch] = softMask(percussive, harmonic*margin, power)
+			}
+		}
+	}
+
+	return
+}
+
+// the magnitude of every value of a complex spectrogram, like FFTComputeAll.ComputeAllComplex's
+func Magnitudes(spectrogram [][][]complex128) (out [][][]float64) {
+	out = make([][][]float64, len(spectrogram))
+	for f, frame := range spectrogram {
+		channels := 0
+		if len(frame) > 0 {
+			channels = len(frame[0])
+		}
+
+		out[f] = util.Create2DFloats(len(frame), channels)
+		for k, bin := range frame {
+			for ch, v := range bin {
+				out[f][k][ch] = math.Hypot(real(v), imag(v))
+			}
+		}
+	}
+
+	return
+}
+
+// multiplies a complex spectrogram by a mask, both indexed [frame][bin][channel]. Bins past the end of the mask (the
+// Nyquist bin, for masks of ComputeAll's spectrograms) use its last bin
+func ApplyMask(spectrogram [][][]complex128, mask [][][]float64) (out [][][]complex128) {
+	out = make([][][]complex128, len(spectrogram))
+	for f, frame := range spectrogram {
+		out[f] = make([][]complex128, len(frame))
+		for k, bin := range frame {
+			m := mask[f][len(mask[f])-1]
+			if k < len(mask[f]) {
+				m = mask[f][k]
+			}
+
+			out[f][k] = make([]complex128, len(bin))
+			for ch, v := range bin {
+				out[f][k][ch] = v * complex(m[ch], 0)
+			}
+		}
+	}
+
+	return
+}
+
+// separates a whole input into harmonic and percussive audio, indexed [sample][channel] like the input. Frames are Hann
+// windowed and must overlap by at least half (the window must be COLA at the hop, see fft.NewISTFT). Each output starts
+// at the input's first sample, and fades in and out over the first and last frames
+func SeparateAudio(input audio.Input, windowSize, windowMove time.Duration, cfg Config, opts fft.Options) (harmonic, percussive [][]float64, err error) {
+	// resynthesis needs every bin as it is, without padding
+	opts.RemoveDC = false
+	opts.FFTSize = 0
+
+	analysis := fft.NewFFTComputeAllOptions(input, windowSize, windowMove, fft.HannWindow, opts)
+	var synthesis *fft.ISTFT
+	if synthesis, err = fft.NewISTFT(analysis.FFTSize(), analysis.Hop(), input.Channels(), fft.HannWindow, fft.PlainOverlapAdd, opts); err != nil {
+		return
+	}
+
+	defer synthesis.Close()
+
+	var spectrogram [][][]complex128
+	if spectrogram, err = analysis.ComputeAllComplex(); err != nil {
+		return
+	}
+
+	masks := ComputeMasks(Magnitudes(spectrogram), cfg)
+	harmonic = synthesis.ComputeAll(ApplyMask(spectrogram, masks.Harmonic))
+	percussive = synthesis.ComputeAll(ApplyMask(spectrogram, masks.Percussive))
+	return
+}
+
+// the index i reflected into [0, n) about the edges, which are repeated (scipy's mode='reflect')
+func reflect(i, n int) int {
+	for i < 0 || i >= n {
+		if i < 0 {
+			i = -i - 1
+		} else {
+			i = 2*n - i - 1
+		}
+	}
+
+	return i
+}
+
+// the median of values, which are reordered
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	return values[len(values)/2]
+}
+
+// the share of x in x + reference, after raising both to power. Infinite powers give 1 when x is larger and 0 otherwise,
+// and when both are 0 the share is a half
+func softMask(x, reference, power float64) float64 {
+	if math.IsInf(power, 1) {
+		if x > reference {
+			return 1
+		}
+
+		return 0
+	}
+
+	z := math.Max(x, reference)
+	if z == 0 {
+		return 0.5
+	}
+
+	x, reference = math.Pow(x/z, power), math.Pow(reference/z, power)
+	return x / (x + reference)
+}
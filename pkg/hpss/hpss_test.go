@@ -0,0 +1,221 @@
+package hpss_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/audio"
+	"github.com/Twister915/vis.go/pkg/fft"
+	"github.com/Twister915/vis.go/pkg/hpss"
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+const (
+	testSampleRate = 16000
+	frameSamples   = 1024
+	hopSamples     = 256
+)
+
+// a steady 440 Hz tone and, separately, clicks every quarter of a second, each one channel and seconds long
+func sources(seconds float64) (tone, clicks [][]float64) {
+	n := int(seconds * testSampleRate)
+	tone, clicks = util.Create2DFloats(n, 1), util.Create2DFloats(n, 1)
+	for i := range tone {
+		tone[i][0] = 0.3 * math.Sin(2*math.Pi*440*float64(i)/testSampleRate)
+	}
+
+	for i := testSampleRate / 8; i < n; i += testSampleRate / 4 {
+		clicks[i][0] = 1
+	}
+
+	return
+}
+
+func mix(a, b [][]float64) (out [][]float64) {
+	out = util.Create2DFloats(len(a), len(a[0]))
+	for i := range out {
+		for ch := range out[i] {
+			out[i][ch] = a[i][ch] + b[i][ch]
+		}
+	}
+
+	return
+}
+
+func newInput(samples [][]float64) audio.Input {
+	return audio.ToInput(audio.NewMemoryReader(audio.Format{SampleRate: testSampleRate, Channels: len(samples[0]), BitDepth: 16}, samples))
+}
+
+func duration(samples int) time.Duration {
+	return time.Duration(samples) * time.Second / testSampleRate
+}
+
+// the energy of the difference between two signals relative to the energy of expected, in dB, over [from, to)
+func errorDB(actual, expected [][]float64, from, to int) float64 {
+	var diff, energy float64
+	for i := from; i < to; i++ {
+		for ch := range expected[i] {
+			d := actual[i][ch] - expected[i][ch]
+			diff += d * d
+			energy += expected[i][ch] * expected[i][ch]
+		}
+	}
+
+	return 10 * math.Log10(diff/energy)
+}
+
+func TestSeparateAudio(t *testing.T) {
+	tone, clicks := sources(3)
+	harmonic, percussive, err := hpss.SeparateAudio(newInput(mix(tone, clicks)), duration(frameSamples), duration(hopSamples), hpss.Config{}, fft.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// away from the fades at either end
+	from, to := frameSamples, len(harmonic)-frameSamples
+
+	h, p := errorDB(harmonic, tone, from, to), errorDB(percussive, clicks, from, to)
+	t.Logf("harmonic error %.1f dB, percussive error %.1f dB", h, p)
+	if h > -30 {
+		t.Fatalf("harmonic error %v dB", h)
+	}
+
+	if p > -12 {
+		t.Fatalf("percussive error %v dB", p)
+	}
+
+	// the masks add up to 1, so the parts add up to the input
+	for i := from; i < to; i++ {
+		if sum, expected := harmonic[i][0]+percussive[i][0], tone[i][0]+clicks[i][0]; math.Abs(sum-expected) > 1e-9 {
+			t.Fatalf("sample %d: %v, expected %v", i, sum, expected)
+		}
+	}
+}
+
+func TestMasks(t *testing.T) {
+	tone, clicks := sources(1)
+	analysis := fft.NewFFTComputeAllOptions(newInput(mix(tone, clicks)), duration(frameSamples), duration(hopSamples), fft.HannWindow, fft.Options{Scaling: fft.Magnitude})
+	spectrogram, err := analysis.ComputeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	toneBin := int(math.Round(440.0 * frameSamples / testSampleRate))
+	check := func(name string, cfg hpss.Config, fn func(h, p float64)) {
+		masks := hpss.ComputeMasks(spectrogram, cfg)
+		for f := range masks.Harmonic {
+			for k := range masks.Harmonic[f] {
+				fn(masks.Harmonic[f][k][0], masks.Percussive[f][k][0])
+			}
+		}
+
+		if h := masks.Harmonic[len(spectrogram)/2][toneBin][0]; h < 0.99 {
+			t.Fatalf("%s: the tone is %v harmonic", name, h)
+		}
+	}
+
+	check("soft", hpss.Config{}, func(h, p float64) {
+		if h < 0 || p < 0 || math.Abs(h+p-1) > 1e-9 {
+			t.Fatalf("soft masks %v + %v", h, p)
+		}
+	})
+
+	check("hard", hpss.Config{Power: math.Inf(1)}, func(h, p float64) {
+		if (h != 0 && h != 1) || (p != 0 && p != 1) || h+p > 1 {
+			t.Fatalf("hard masks %v, %v", h, p)
+		}
+	})
+
+	check("margin", hpss.Config{Margin: 2}, func(h, p float64) {
+		if h < 0 || p < 0 || h+p > 1+1e-9 {
+			t.Fatalf("masks with margin %v + %v", h, p)
+		}
+	})
+}
+
+func TestStreaming(t *testing.T) {
+	tone, clicks := sources(2)
+	input := newInput(mix(tone, clicks))
+	frames := fft.NewFFTByFrameOptions(input, duration(frameSamples), duration(hopSamples), fft.HannWindow, fft.Options{Scaling: fft.Magnitude})
+	a := hpss.NewAnalyzer(frames, hpss.Config{})
+	defer a.Close()
+
+	bins := frames.NumberOutputFrequencies()
+	harmonic, percussive := util.Create2DFloats(bins, 1), util.Create2DFloats(bins, 1)
+	toneBin := int(math.Round(440.0 * frameSamples / testSampleRate))
+	// bins well above the tone, where only the clicks are
+	highBins := bins / 4
+
+	for frame := 0; a.HasNext(); frame++ {
+		if err := a.Compute(harmonic, percussive); err != nil {
+			t.Fatal(err)
+		}
+
+		// wait for the history to fill
+		if frame < 31 {
+			continue
+		}
+
+		if share := harmonic[toneBin][0] / (harmonic[toneBin][0] + percussive[toneBin][0]); share < 0.95 {
+			t.Fatalf("frame %d: the tone is %v harmonic", frame, share)
+		}
+
+		// frames whose middle is on a click
+		center := frame*hopSamples + frameSamples/2
+		if offset := center % (testSampleRate / 4); math.Abs(float64(offset-testSampleRate/8)) < hopSamples/2 {
+			var h, p float64
+			for k := highBins; k < bins; k++ {
+				h += harmonic[k][0]
+				p += percussive[k][0]
+			}
+
+			if p/(h+p) < 0.9 {
+				t.Fatalf("frame %d: the click is %v percussive", frame, p/(h+p))
+			}
+		}
+	}
+}
+
+func TestStreamingResynthesis(t *testing.T) {
+	tone, clicks := sources(2)
+	samples := mix(tone, clicks)
+	frames := fft.NewFFTByFrameOptions(newInput(samples), duration(frameSamples), duration(hopSamples), fft.HannWindow, fft.Options{})
+	a := hpss.NewAnalyzer(frames, hpss.Config{})
+	defer a.Close()
+
+	synthesis, err := fft.NewISTFT(frameSamples, hopSamples, 1, fft.HannWindow, fft.PlainOverlapAdd, fft.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer synthesis.Close()
+
+	bins := frames.NumberOutputFrequencies()
+	harmonic, percussive := make([][]complex128, bins), make([][]complex128, bins)
+	sum := make([][]complex128, bins)
+	for k := range harmonic {
+		harmonic[k], percussive[k], sum[k] = make([]complex128, 1), make([]complex128, 1), make([]complex128, 1)
+	}
+
+	out := util.Create2DFloats(len(samples), 1)
+	at := 0
+	for a.HasNext() {
+		if err = a.ComputeComplex(harmonic, percussive); err != nil {
+			t.Fatal(err)
+		}
+
+		for k := range sum {
+			sum[k][0] = harmonic[k][0] + percussive[k][0]
+		}
+
+		at += synthesis.Push(sum, out[at:])
+	}
+
+	// the parts add back up to the input
+	for i := frameSamples; i < at; i++ {
+		if math.Abs(out[i][0]-samples[i][0]) > 1e-9 {
+			t.Fatalf("sample %d: %v, expected %v", i, out[i][0], samples[i][0])
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package hpss
+
+import (
+	"math"
+
+	"github.com/Twister915/vis.go/pkg/fft"
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+// masks frame by frame as the audio plays. The median across time can only look back, so it covers the latest
+// HarmonicKernel frames, and a note takes about half of them to be counted as harmonic (until then it leans
+// percussive). The median across frequency is the same as the offline one
+type Streaming struct {
+	harmonicKernel, percussiveKernel int
+	power, margin                    float64
+
+	// the latest frames, indexed [frame][bin][channel] as a ring starting at next
+	history [][][]float64
+	next    int
+	filled  int
+
+	scratch []float64
+}
+
+// for spectra of bins bins and channels channels
+func NewStreaming(bins, channels int, cfg Config) *Streaming {
+	out := &Streaming{power: cfg.power(), margin: cfg.margin()}
+	out.harmonicKernel, out.percussiveKernel = cfg.kernels()
+	out.history = util.CreateNDFloat64(out.harmonicKernel, bins, channels).([][][]float64)
+	out.scratch = make([]float64, int(math.Max(float64(out.harmonicKernel), float64(out.percussiveKernel))))
+	return out
+}
+
+// adds the next magnitude (or power) spectrum and writes its masks, all indexed [bin][channel]
+func (s *Streaming) Masks(spectrum [][]float64, harmonic, percussive [][]float64) {
+	latest := s.history[s.next]
+	for k, bin := range spectrum {
+		copy(latest[k], bin)
+	}
+
+	s.next = (s.next + 1) % len(s.history)
+	if s.filled < len(s.history) {
+		s.filled++
+	}
+
+	bins := len(spectrum)
+	for k := range spectrum {
+		for ch := range spectrum[k] {
+			window := s.scratch[:s.filled]
+			for i := range window {
+				window[i] = s.history[i][k][ch]
+			}
+
+			h := median(window)
+
+			window = s.scratch[:s.percussiveKernel]
+			for i := range window {
+				window[i] = spectrum[reflect(k+i-s.percussiveKernel/2, bins)][ch]
+			}
+
+			p := median(window)
+
+			harmonic[k][ch] = softMask(h, p*s.margin, s.power)
+			percussive[k][ch] = softMask(p, h*s.margin, s.power)
+		}
+	}
+}
+
+// forgets the history, for starting over on other audio
+func (s *Streaming) Reset() {
+	s.next, s.filled = 0, 0
+}
+
+// separates an FFTByFrame's frames as they are computed
+type Analyzer struct {
+	frames    *fft.FFTByFrame
+	streaming *Streaming
+
+	spectrum  [][]float64
+	complexes [][]complex128
+
+	harmonic, percussive [][]float64
+}
+
+func NewAnalyzer(frames *fft.FFTByFrame, cfg Config) *Analyzer {
+	bins, channels := frames.NumberOutputFrequencies(), frames.Channels()
+	out := &Analyzer{
+		frames:     frames,
+		streaming:  NewStreaming(bins, channels, cfg),
+		spectrum:   util.Create2DFloats(bins, channels),
+		complexes:  make([][]complex128, bins),
+		harmonic:   util.Create2DFloats(bins, channels),
+		percussive: util.Create2DFloats(bins, channels),
+	}
+
+	for k := range out.complexes {
+		out.complexes[k] = make([]complex128, channels)
+	}
+
+	return out
+}
+
+func (a *Analyzer) HasNext() bool {
+	return a.frames.HasNext()
+}
+
+// splits the next frame into its harmonic and percussive parts, indexed [bin][channel] and adding up to the frame
+// (with a Margin of 1). The FFTByFrame should use Scaling: fft.Magnitude or fft.Power, the masks are computed from the
+// scaled values
+func (a *Analyzer) Compute(harmonic, percussive [][]float64) (err error) {
+	if err = a.frames.Compute(a.spectrum); err != nil {
+		return
+	}
+
+	a.streaming.Masks(a.spectrum, a.harmonic, a.percussive)
+	for k, bin := range a.spectrum {
+		for ch, v := range bin {
+			harmonic[k][ch] = v * a.harmonic[k][ch]
+			percussive[k][ch] = v * a.percussive[k][ch]
+		}
+	}
+
+	return
+}
+
+// like Compute, but splits the complex spectrum (masked by magnitude), which an fft.ISTFT can turn back into audio
+func (a *Analyzer) ComputeComplex(harmonic, percussive [][]complex128) (err error) {
+	if err = a.frames.ComputeComplex(a.complexes); err != nil {
+		return
+	}
+
+	for k, bin := range a.complexes {
+		for ch, v := range bin {
+			a.spectrum[k][ch] = math.Hypot(real(v), imag(v))
+		}
+	}
+
+	a.streaming.Masks(a.spectrum, a.harmonic, a.percussive)
+	for k, bin := range a.complexes {
+		for ch, v := range bin {
+			harmonic[k][ch] = v * complex(a.harmonic[k][ch], 0)
+			percussive[k][ch] = v * complex(a.percussive[k][ch], 0)
+		}
+	}
+
+	return
+}
+
+// closes the FFTByFrame
+func (a *Analyzer) Close() error {
+	return a.frames.Close()
+}
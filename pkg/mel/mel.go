@@ -0,0 +1,220 @@
+package mel
+
+import (
+	"math"
+
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+// triangular filterbanks on the Mel scale, applied to power spectra from pkg/fft (FFTByFrame or FFTComputeAll with
+// Scaling: fft.Power). Results match librosa's mel filters, melspectrogram and mfcc
+
+// which formula maps Hz to Mels
+type Scale int
+
+const (
+	// linear below 1 kHz and logarithmic above (Slaney's Auditory Toolbox), what librosa uses by default
+	Slaney Scale = iota
+
+	// 2595 log10(1 + f / 700), as in HTK
+	HTK
+)
+
+const (
+	slaneyLinearStep = 200.0 / 3
+	slaneyLogStart   = 1000.0
+	// Mels at slaneyLogStart
+	slaneyLogStartMel = slaneyLogStart / slaneyLinearStep
+)
+
+// the Slaney scale grows by a factor of 6.4 every 27 Mels above 1 kHz
+var slaneyLogStep = math.Log(6.4) / 27
+
+func HzToMel(hz float64, scale Scale) float64 {
+	if scale == HTK {
+		return 2595 * math.Log10(1+hz/700)
+	}
+
+	if hz < slaneyLogStart {
+		return hz / slaneyLinearStep
+	}
+
+	return slaneyLogStartMel + math.Log(hz/slaneyLogStart)/slaneyLogStep
+}
+
+func MelToHz(mel float64, scale Scale) float64 {
+	if scale == HTK {
+		return 700 * (math.Pow(10, mel/2595) - 1)
+	}
+
+	if mel < slaneyLogStartMel {
+		return mel * slaneyLinearStep
+	}
+
+	return slaneyLogStart * math.Exp(slaneyLogStep*(mel-slaneyLogStartMel))
+}
+
+// n frequencies evenly spaced in Mels from fmin to fmax (both in Hz, and both included)
+func Frequencies(n int, fmin, fmax float64, scale Scale) (out []float64) {
+	out = make([]float64, n)
+	low, high := HzToMel(fmin, scale), HzToMel(fmax, scale)
+	for i := range out {
+		mel := low
+		if n > 1 {
+			mel += (high - low) * float64(i) / float64(n-1)
+		}
+
+		out[i] = MelToHz(mel, scale)
+	}
+
+	return
+}
+
+type Config struct {
+	// number of filters, 0 means 128
+	Bands int
+
+	// the edges of the lowest and highest filters in Hz, a MaxFreq of 0 means the Nyquist frequency
+	MinFreq, MaxFreq float64
+
+	Scale Scale
+
+	// scale each filter by 2 / its width in Hz, so they all have the same area and a band's energy does not grow with
+	// its width. librosa's norm='slaney'. Without it every filter peaks at 1
+	AreaNormalize bool
+}
+
+// Slaney's filterbank, librosa's default
+func SlaneyConfig(bands int) Config {
+	return Config{Bands: bands, Scale: Slaney, AreaNormalize: true}
+}
+
+// HTK's filterbank, with unnormalized triangles
+func HTKConfig(bands int) Config {
+	return Config{Bands: bands, Scale: HTK}
+}
+
+const defaultBands = 128
+
+// a bank of overlapping triangular filters, each rising from the center of the one below it to its own center and
+// falling to the center of the one above
+type Filterbank struct {
+	centers []float64
+	filters []filter
+}
+
+// the non-zero weights of one filter, starting at FFT bin first
+type filter struct {
+	first   int
+	weights []float64
+}
+
+// a filterbank for spectra of fftSize point transforms at sampleRate, which have (fftSize / 2) + 1 bins
+func NewFilterbank(sampleRate, fftSize int, cfg Config) *Filterbank {
+	bands := cfg.Bands
+	if bands <= 0 {
+		bands = defaultBands
+	}
+
+	maxFreq := cfg.MaxFreq
+	if maxFreq <= 0 {
+		maxFreq = float64(sampleRate) / 2
+	}
+
+	// the edges, the filter i spans edges[i] to edges[i + 2] and peaks at edges[i + 1]
+	edges := Frequencies(bands+2, cfg.MinFreq, maxFreq, cfg.Scale)
+	binHz := float64(sampleRate) / float64(fftSize)
+
+	out := &Filterbank{
+		centers: edges[1 : bands+1],
+		filters: make([]filter, bands),
+	}
+
+	for i := range out.filters {
+		lower, center, upper := edges[i], edges[i+1], edges[i+2]
+		scale := 1.0
+		if cfg.AreaNormalize {
+			scale = 2 / (upper - lower)
+		}
+
+		f := filter{first: -1}
+		for k := 0; k <= fftSize/2; k++ {
+			hz := float64(k) * binHz
+			w := math.Min((hz-lower)/(center-lower), (upper-hz)/(upper-center))
+			if w <= 0 {
+				if f.first >= 0 {
+					break
+				}
+
+				continue
+			}
+
+			if f.first < 0 {
+				f.first = k
+			}
+
+			f.weights = append(f.weights, w*scale)
+		}
+
+		// narrower than a bin, the filter falls between two and sees nothing (librosa warns about these)
+		if f.first < 0 {
+			f.first = 0
+		}
+
+		out.filters[i] = f
+	}
+
+	return out
+}
+
+func (f *Filterbank) Bands() int {
+	return len(f.filters)
+}
+
+// the center frequency of each filter in Hz
+func (f *Filterbank) Centers() []float64 {
+	return f.centers
+}
+
+// the weight of FFT bin k in filter band
+func (f *Filterbank) Weight(band, k int) float64 {
+	filter := f.filters[band]
+	if k < filter.first || k >= filter.first+len(filter.weights) {
+		return 0
+	}
+
+	return filter.weights[k-filter.first]
+}
+
+// filters one power spectrum, indexed [bin][channel] (like FFTByFrame.Compute), into dst indexed [band][channel].
+// Spectra without the Nyquist bin (like FFTComputeAll's) are fine, missing bins count as 0
+func (f *Filterbank) Apply(spectrum [][]float64, dst [][]float64) {
+	for band, filter := range f.filters {
+		for ch := range dst[band] {
+			var sum float64
+			for i, w := range filter.weights {
+				if k := filter.first + i; k < len(spectrum) {
+					sum += spectrum[k][ch] * w
+				}
+			}
+
+			dst[band][ch] = sum
+		}
+	}
+}
+
+// Apply for every frame of a spectrogram indexed [frame][bin][channel], the result is indexed [frame][band][channel]
+func (f *Filterbank) ApplyAll(spectrogram [][][]float64) (out [][][]float64) {
+	out = make([][][]float64, len(spectrogram))
+	for i, frame := range spectrogram {
+		channels := 0
+		if len(frame) > 0 {
+			channels = len(frame[0])
+		}
+
+		out[i] = util.Create2DFloats(f.Bands(), channels)
+		f.Apply(frame, out[i])
+	}
+
+	return
+}
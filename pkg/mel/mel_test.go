@@ -0,0 +1,211 @@
+package mel_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/audio"
+	"github.com/Twister915/vis.go/pkg/fft"
+	"github.com/Twister915/vis.go/pkg/mel"
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+// librosa's hz_to_mel docstring, and HTK's 1000 Hz ≈ 1000 Mel
+func TestScales(t *testing.T) {
+	cases := []struct {
+		hz, mel float64
+		scale   mel.Scale
+	}{
+		{60, 0.9, mel.Slaney},
+		{110, 1.65, mel.Slaney},
+		{440, 6.6, mel.Slaney},
+		{1000, 15, mel.Slaney},
+		{6400, 42, mel.Slaney},
+		{1000, 999.99, mel.HTK},
+		{440, 549.64, mel.HTK},
+	}
+
+	for _, c := range cases {
+		if m := mel.HzToMel(c.hz, c.scale); math.Abs(m-c.mel) > 0.01 {
+			t.Errorf("%v Hz is %v Mel, expected %v", c.hz, m, c.mel)
+		}
+
+		if hz := mel.MelToHz(mel.HzToMel(c.hz, c.scale), c.scale); math.Abs(hz-c.hz) > 1e-9 {
+			t.Errorf("%v Hz came back as %v", c.hz, hz)
+		}
+	}
+}
+
+// librosa.filters.mel(sr=22050, n_fft=2048)[0, 1] is 0.016 in its docstring
+func TestSlaneyFilterbank(t *testing.T) {
+	const sampleRate, fftSize = 22050, 2048
+	fb := mel.NewFilterbank(sampleRate, fftSize, mel.SlaneyConfig(128))
+	if w := fb.Weight(0, 1); math.Abs(w-0.016) > 0.0005 {
+		t.Fatalf("first filter's weight on bin 1 is %v", w)
+	}
+
+	if fb.Weight(0, 0) != 0 || fb.Weight(1, 1) != 0 {
+		t.Fatalf("filters reach too far: %v, %v", fb.Weight(0, 0), fb.Weight(1, 1))
+	}
+
+	// each triangle has an area of 1 (in Hz), the wide ones are sampled well enough to show it
+	binHz := float64(sampleRate) / fftSize
+	for band := 64; band < fb.Bands(); band++ {
+		var area float64
+		for k := 0; k <= fftSize/2; k++ {
+			area += fb.Weight(band, k) * binHz
+		}
+
+		if math.Abs(area-1) > 0.02 {
+			t.Errorf("band %d has an area of %v", band, area)
+		}
+	}
+}
+
+// unnormalized triangles overlap so every bin between the first and last centers has a total weight of 1
+func TestHTKFilterbank(t *testing.T) {
+	const sampleRate, fftSize = 16000, 512
+	fb := mel.NewFilterbank(sampleRate, fftSize, mel.HTKConfig(40))
+	centers := fb.Centers()
+	binHz := float64(sampleRate) / fftSize
+	for k := 0; k <= fftSize/2; k++ {
+		hz := float64(k) * binHz
+		if hz < centers[0] || hz > centers[len(centers)-1] {
+			continue
+		}
+
+		var sum float64
+		for band := 0; band < fb.Bands(); band++ {
+			w := fb.Weight(band, k)
+			if w > 1 {
+				t.Fatalf("band %d bin %d has weight %v", band, k, w)
+			}
+
+			sum += w
+		}
+
+		if math.Abs(sum-1) > 1e-9 {
+			t.Fatalf("bin %d (%v Hz) sums to %v", k, hz, sum)
+		}
+	}
+}
+
+// orthonormal DCT-II: a flat log-Mel spectrum only has c0, and a full set of coefficients keeps the energy
+func TestMFCC(t *testing.T) {
+	const bands = 26
+	m := mel.NewMFCC(bands, bands, 0)
+	flat := util.Create2DFloats(bands, 1)
+	for i := range flat {
+		flat[i][0] = -20
+	}
+
+	out := util.Create2DFloats(bands, 1)
+	m.Compute(flat, out)
+	if math.Abs(out[0][0]-(-20*math.Sqrt(bands))) > 1e-9 {
+		t.Fatalf("c0 is %v", out[0][0])
+	}
+
+	for k := 1; k < bands; k++ {
+		if math.Abs(out[k][0]) > 1e-9 {
+			t.Fatalf("c%d is %v", k, out[k][0])
+		}
+	}
+
+	var in, coefficients float64
+	for i := range flat {
+		flat[i][0] = math.Sin(float64(i)) * float64(i)
+		in += flat[i][0] * flat[i][0]
+	}
+
+	m.Compute(flat, out)
+	for k := range out {
+		coefficients += out[k][0] * out[k][0]
+	}
+
+	if math.Abs(in-coefficients) > 1e-9*in {
+		t.Fatalf("energy %v became %v", in, coefficients)
+	}
+
+	// liftering only scales each coefficient
+	liftered := util.Create2DFloats(13, 1)
+	mel.NewMFCC(bands, 13, 22).Compute(flat, liftered)
+	for k := range liftered {
+		expected := out[k][0] * (1 + 11*math.Sin(math.Pi*float64(k+1)/22))
+		if math.Abs(liftered[k][0]-expected) > 1e-9 {
+			t.Fatalf("liftered c%d is %v, expected %v", k, liftered[k][0], expected)
+		}
+	}
+}
+
+// the deltas of a ramp are its slope, apart from near the edges where the repeated frames flatten it
+func TestDeltas(t *testing.T) {
+	features := make([][][]float64, 20)
+	for i := range features {
+		features[i] = [][]float64{{2 * float64(i)}}
+	}
+
+	deltas := mel.Deltas(features, 4)
+	for i, d := range deltas {
+		if i >= 4 && i < 16 && math.Abs(d[0][0]-2) > 1e-12 {
+			t.Fatalf("delta %d is %v", i, d[0][0])
+		}
+
+		if (i < 4 || i >= 16) && (d[0][0] >= 2 || d[0][0] <= 0) {
+			t.Fatalf("edge delta %d is %v", i, d[0][0])
+		}
+	}
+
+	for i, d := range mel.Deltas(deltas, 4)[8:12] {
+		if math.Abs(d[0][0]) > 1e-12 {
+			t.Fatalf("acceleration %d is %v", i+8, d[0][0])
+		}
+	}
+}
+
+func TestDeltasWidth(t *testing.T) {
+	features := [][][]float64{{{0}}, {{2}}, {{4}}}
+	for _, width := range []int{0, -1} {
+		for i, d := range mel.Deltas(features, width) {
+			if expected := mel.Deltas(features, 1)[i][0][0]; d[0][0] != expected {
+				t.Fatalf("width %d delta %d is %v, expected %v", width, i, d[0][0], expected)
+			}
+		}
+	}
+}
+
+// a 1 kHz tone through FFTByFrame, a filterbank and the log is loudest in the band around 1 kHz
+func TestLogMelFromFFTByFrame(t *testing.T) {
+	const sampleRate = 16000
+	samples := util.Create2DFloats(sampleRate/2, 1)
+	for i := range samples {
+		samples[i][0] = math.Sin(2 * math.Pi * 1000 * float64(i) / sampleRate)
+	}
+
+	input := audio.ToInput(audio.NewMemoryReader(audio.Format{SampleRate: sampleRate, Channels: 1, BitDepth: 16}, samples))
+	frames := fft.NewFFTByFrameOptions(input, time.Millisecond*32, time.Millisecond*16, fft.HannWindow, fft.Options{Scaling: fft.Power})
+	defer frames.Close()
+
+	fb := mel.NewFilterbank(sampleRate, frames.FFTSize(), mel.HTKConfig(40))
+	spectrum := util.Create2DFloats(frames.NumberOutputFrequencies(), 1)
+	bands := util.Create2DFloats(fb.Bands(), 1)
+	for frames.HasNext() {
+		if err := frames.Compute(spectrum); err != nil {
+			t.Fatal(err)
+		}
+
+		fb.Apply(spectrum, bands)
+		mel.PowerToDB(bands, mel.DefaultMinPower)
+
+		loudest := 0
+		for i := range bands {
+			if bands[i][0] > bands[loudest][0] {
+				loudest = i
+			}
+		}
+
+		if center := fb.Centers()[loudest]; math.Abs(center-1000) > 100 {
+			t.Fatalf("loudest band is centered on %v Hz", center)
+		}
+	}
+}
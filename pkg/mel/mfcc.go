@@ -0,0 +1,166 @@
+package mel
+
+import (
+	"math"
+
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+// the smallest power PowerToDB takes the log of, librosa's amin
+const DefaultMinPower = 1e-10
+
+// converts Mel band powers (indexed [band][channel]) to dB in place, 10 log10(max(v, minPower)). This is the log-Mel
+// spectrum MFCCs are taken from
+func PowerToDB(bands [][]float64, minPower float64) {
+	for _, band := range bands {
+		for ch, v := range band {
+			band[ch] = 10 * math.Log10(math.Max(v, minPower))
+		}
+	}
+}
+
+// limits a whole log-Mel spectrogram (indexed [frame][band][channel]) to topDB below its loudest value, like librosa's
+// power_to_db with top_db. Quiet passages otherwise reach down to the log of minPower
+func ClipDB(spectrogram [][][]float64, topDB float64) {
+	loudest := math.Inf(-1)
+	for _, frame := range spectrogram {
+		for _, band := range frame {
+			for _, v := range band {
+				loudest = math.Max(loudest, v)
+			}
+		}
+	}
+
+	for _, frame := range spectrogram {
+		for _, band := range frame {
+			for ch, v := range band {
+				band[ch] = math.Max(v, loudest-topDB)
+			}
+		}
+	}
+}
+
+// Mel frequency cepstral coefficients, the orthonormal DCT-II of the log-Mel spectrum (librosa's mfcc with dct_type 2 and
+// norm 'ortho'), optionally liftered
+type MFCC struct {
+	// dct[k][band]
+	dct [][]float64
+}
+
+// coefficients of bands Mel bands. A lifter above 0 scales coefficient k by 1 + (lifter / 2) sin(π (k + 1) / lifter),
+// which evens out the magnitudes of the higher coefficients (HTK's CEPLIFTER, librosa's lifter)
+func NewMFCC(bands, coefficients, lifter int) *MFCC {
+	out := &MFCC{dct: util.Create2DFloats(coefficients, bands)}
+	for k, row := range out.dct {
+		scale := math.Sqrt(2 / float64(bands))
+		if k == 0 {
+			scale = math.Sqrt(1 / float64(bands))
+		}
+
+		if lifter > 0 {
+			scale *= 1 + float64(lifter)/2*math.Sin(math.Pi*float64(k+1)/float64(lifter))
+		}
+
+		for n := range row {
+			row[n] = scale * math.Cos(math.Pi*float64(k)*(2*float64(n)+1)/(2*float64(bands)))
+		}
+	}
+
+	return out
+}
+
+func (m *MFCC) Coefficients() int {
+	return len(m.dct)
+}
+
+// logMel is indexed [band][channel] (see PowerToDB), and dst [coefficient][channel]
+func (m *MFCC) Compute(logMel [][]float64, dst [][]float64) {
+	for k, row := range m.dct {
+		for ch := range dst[k] {
+			var sum float64
+			for n, c := range row {
+				sum += c * logMel[n][ch]
+			}
+
+			dst[k][ch] = sum
+		}
+	}
+}
+
+// Compute for every frame of a log-Mel spectrogram indexed [frame][band][channel], the result is indexed
+// [frame][coefficient][channel]
+func (m *MFCC) ComputeAll(logMel [][][]float64) (out [][][]float64) {
+	out = make([][][]float64, len(logMel))
+	for i, frame := range logMel {
+		channels := 0
+		if len(frame) > 0 {
+			channels = len(frame[0])
+		}
+
+		out[i] = util.Create2DFloats(m.Coefficients(), channels)
+		m.Compute(frame, out[i])
+	}
+
+	return
+}
+
+// the regression deltas of features indexed [frame][feature][channel], HTK's formula
+//
+//	d[t] = Σ n (c[t + n] - c[t - n]) / 2 Σ n², for n in [1, width]
+//
+// with the first and last frames repeated past the edges. A width of 4 matches librosa's delta (width 9) away from the
+// edges, and widths below 1 are taken as 1. Deltas of the deltas are the acceleration coefficients
+func Deltas(features [][][]float64, width int) (out [][][]float64) {
+	out = make([][][]float64, len(features))
+	if len(features) == 0 {
+		return
+	}
+
+	if width < 1 {
+		width = 1
+	}
+
+	var denominator float64
+	for n := 1; n <= width; n++ {
+		denominator += float64(n * n)
+	}
+
+	denominator *= 2
+
+	clamp := func(t int) int {
+		if t < 0 {
+			return 0
+		}
+
+		if t >= len(features) {
+			return len(features) - 1
+		}
+
+		return t
+	}
+
+	for t, frame := range features {
+		channels := 0
+		if len(frame) > 0 {
+			channels = len(frame[0])
+		}
+
+		out[t] = util.Create2DFloats(len(frame), channels)
+		for n := 1; n <= width; n++ {
+			ahead, behind := features[clamp(t+n)], features[clamp(t-n)]
+			for i := range frame {
+				for ch := range frame[i] {
+					out[t][i][ch] += float64(n) * (ahead[i][ch] - behind[i][ch])
+				}
+			}
+		}
+
+		for _, feature := range out[t] {
+			for ch := range feature {
+				feature[ch] /= denominator
+			}
+		}
+	}
+
+	return
+}
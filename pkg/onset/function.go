@@ -0,0 +1,157 @@
+package onset
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"strings"
+)
+
+// onset detection functions, each turns a frame's spectrum into one value which jumps when a note or drum starts
+
+type Function int
+
+const (
+	// the sum of every bin's increase in magnitude since the last frame (Masri, Duxbury), good all round
+	SpectralFlux Function = iota
+
+	// the sum of every bin's power weighted by its bin number (Masri & Bateman, 1996), good for drums and other
+	// percussive onsets which are mostly high frequency noise
+	HighFrequencyContent
+
+	// the sum of every bin's distance from where it would be if the last two frames' magnitude and phase continued
+	// (Bello et al., 2004), which also finds soft onsets where only the pitch changes
+	ComplexDomain
+)
+
+var functionNames = []string{"spectral-flux", "hfc", "complex-domain"}
+
+func (f Function) String() string {
+	if f < 0 || int(f) >= len(functionNames) {
+		return fmt.Sprintf("Function(%d)", int(f))
+	}
+
+	return functionNames[f]
+}
+
+// parses the names returned by Function.String, case insensitive
+func ParseFunction(s string) (out Function, err error) {
+	for i, name := range functionNames {
+		if strings.EqualFold(s, name) {
+			out = Function(i)
+			return
+		}
+	}
+
+	err = fmt.Errorf("unknown onset detection function '%s'", s)
+	return
+}
+
+// computes a detection function frame by frame, keeping the previous frames it needs
+type envelope struct {
+	function    Function
+	compression float64
+	// magnitudes are multiplied by this, so a full scale sinusoid is about 1 whatever the frame size
+	scale float64
+
+	// [bin][channel] of the previous two frames, zero before the first
+	magnitudes, lastMagnitudes [][]float64
+	phases, lastPhases         [][]float64
+	// frames seen so far, up to the 2 the phase prediction needs
+	history int
+}
+
+func newEnvelope(function Function, compression float64, frameSize int) *envelope {
+	return &envelope{function: function, compression: compression, scale: 2 / float64(frameSize)}
+}
+
+func (e *envelope) next(spectrum [][]complex128) (value float64) {
+	if e.magnitudes == nil {
+		e.magnitudes, e.lastMagnitudes = create2D(spectrum), create2D(spectrum)
+		e.phases, e.lastPhases = create2D(spectrum), create2D(spectrum)
+	}
+
+	// the current frame goes where the oldest one was, which is still needed for the phase prediction
+	e.magnitudes, e.lastMagnitudes = e.lastMagnitudes, e.magnitudes
+	older := e.lastPhases
+	e.phases, e.lastPhases = e.lastPhases, e.phases
+
+	channels := 0
+	bins := float64(len(spectrum))
+	for k, bin := range spectrum {
+		channels = len(bin)
+		for ch, v := range bin {
+			v *= complex(e.scale, 0)
+			magnitude := cmplx.Abs(v)
+			if e.compression > 0 {
+				magnitude = math.Log1p(e.compression * magnitude)
+			}
+
+			previous := e.lastMagnitudes[k][ch]
+			switch e.function {
+			case HighFrequencyContent:
+				value += float64(k) / bins * magnitude * magnitude
+			case ComplexDomain:
+				predicted := cmplx.Rect(previous, 2*e.lastPhases[k][ch]-older[k][ch])
+				value += cmplx.Abs(cmplx.Rect(magnitude, cmplx.Phase(v)) - predicted)
+			default:
+				if magnitude > previous {
+					value += magnitude - previous
+				}
+			}
+
+			e.magnitudes[k][ch] = magnitude
+			e.phases[k][ch] = cmplx.Phase(v)
+		}
+	}
+
+	// the average of the channels
+	if channels > 0 {
+		value /= float64(channels)
+	}
+
+	// without the frames before, the first frames would compare against silence
+	if e.history < e.function.history() {
+		value = 0
+	}
+
+	if e.history < 2 {
+		e.history++
+	}
+
+	return
+}
+
+// the default Config.Threshold, about a tenth of what a full scale hit gives (HFC is a power, so a hundredth). Noise
+// adds up over every bin, -60 dB of it is already a few percent of this
+func (f Function) threshold() float64 {
+	switch f {
+	case HighFrequencyContent:
+		return 1e-4
+	case ComplexDomain:
+		return 0.2
+	default:
+		return 0.1
+	}
+}
+
+// the frames before the current one a function compares against
+func (f Function) history() int {
+	switch f {
+	case HighFrequencyContent:
+		return 0
+	case ComplexDomain:
+		return 2
+	default:
+		return 1
+	}
+}
+
+func create2D(like [][]complex128) (out [][]float64) {
+	out = make([][]float64, len(like))
+	for i := range out {
+		out[i] = make([]float64, len(like[i]))
+	}
+
+	return
+}
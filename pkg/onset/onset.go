@@ -0,0 +1,221 @@
+package onset
+
+import (
+	"math"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/fft"
+)
+
+// onset detection, finding where notes and drum hits start. A detection function is computed from each frame's
+// spectrum, and peaks of it which stand out from the frames around them are onsets. Works frame by frame, each onset is
+// reported a few frames after it happened (see Config.PostMax and Config.PostAvg)
+
+type Config struct {
+	Function Function
+
+	// magnitudes are compressed to log(1 + Compression * magnitude) first, which evens out loud and quiet onsets
+	// (Böck & Widmer use 1 to 100). 0 leaves them alone
+	Compression float64
+
+	// frame n is an onset when it has the largest value from n - PreMax to n + PostMax, and is at least Delta above the
+	// mean from n - PreAvg to n + PostAvg. Values are divided by the largest recent one, so Delta is a fraction of that.
+	// Every duration is rounded up to whole frames
+	PreMax, PostMax time.Duration
+	PreAvg, PostAvg time.Duration
+	Delta           float64
+
+	// the shortest time between onsets
+	Wait time.Duration
+
+	// the smallest value the detection function is divided by, so noise before the first onset does not count as
+	// onsets. Magnitudes are scaled so a full scale sinusoid is about 1. 0 means the function's default, and below 0
+	// means none
+	Threshold float64
+}
+
+// librosa's onset_detect settings. Onsets are reported PostAvg (100ms) after they happen, lower it for less latency
+func DefaultConfig() Config {
+	return Config{
+		Function: SpectralFlux,
+		PreMax:   30 * time.Millisecond,
+		PreAvg:   100 * time.Millisecond,
+		PostAvg:  100 * time.Millisecond,
+		Delta:    0.07,
+		Wait:     30 * time.Millisecond,
+	}
+}
+
+// values are divided by the largest recent one, which halves every this long unless a larger one comes along
+const peakHalfLife = 10 * time.Second
+
+type Onset struct {
+	// the frame it was found in, counting from 0
+	Frame int
+
+	// the center of that frame
+	Time time.Duration
+
+	// the detection function's value, relative to the largest recent one
+	Strength float64
+}
+
+// finds onsets in frames of frameSize samples starting every hop samples
+type Detector struct {
+	sampleRate, frameSize, hop int
+
+	envelope *envelope
+	picker   *peakPicker
+
+	// the largest recent value (never below threshold), and how much it decays each frame
+	peak, decay float64
+	threshold   float64
+	// the last frame's value, before dividing by peak
+	last float64
+	// normalized values of the frames the picker has not decided yet, from frame picker.next on
+	strengths []float64
+}
+
+func NewDetector(sampleRate, frameSize, hop int, cfg Config) *Detector {
+	frames := func(d time.Duration) int {
+		return int(math.Ceil(d.Seconds() * float64(sampleRate) / float64(hop)))
+	}
+
+	threshold := cfg.Threshold
+	if threshold == 0 {
+		threshold = cfg.Function.threshold()
+	}
+
+	return &Detector{
+		sampleRate: sampleRate,
+		frameSize:  frameSize,
+		hop:        hop,
+		envelope:   newEnvelope(cfg.Function, cfg.Compression, frameSize),
+		picker: &peakPicker{
+			preMax:    frames(cfg.PreMax),
+			postMax:   frames(cfg.PostMax),
+			preAvg:    frames(cfg.PreAvg),
+			postAvg:   frames(cfg.PostAvg),
+			wait:      frames(cfg.Wait),
+			delta:     cfg.Delta,
+			lastOnset: -1,
+		},
+		threshold: threshold,
+		decay:     math.Pow(0.5, float64(hop)/float64(sampleRate)/peakHalfLife.Seconds()),
+	}
+}
+
+// adds the next frame, a spectrum indexed [bin][channel] (like FFTByFrame.ComputeComplex). found is set when the frame
+// decided by it is an onset, which is Frame, not this one
+func (d *Detector) Process(spectrum [][]complex128) (onset Onset, found bool) {
+	d.last = d.envelope.next(spectrum)
+	d.peak = math.Max(math.Max(d.last, d.peak*d.decay), d.threshold)
+
+	normalized := 0.0
+	if d.peak > 0 {
+		normalized = d.last / d.peak
+	}
+
+	d.strengths = append(d.strengths, normalized)
+	frame, decided, ok := d.picker.add(normalized)
+	if !decided {
+		return
+	}
+
+	strength := d.strengths[0]
+	d.strengths = d.strengths[1:]
+	if ok {
+		onset, found = d.onset(frame, strength), true
+	}
+
+	return
+}
+
+// decides the frames still waiting for the ones after them, at the end of the input
+func (d *Detector) Flush() (onsets []Onset) {
+	first := d.picker.next
+	strengths := d.strengths
+	d.strengths = nil
+	for _, frame := range d.picker.flush() {
+		onsets = append(onsets, d.onset(frame, strengths[frame-first]))
+	}
+
+	return
+}
+
+func (d *Detector) onset(frame int, strength float64) Onset {
+	center := float64(frame*d.hop+d.frameSize/2) / float64(d.sampleRate)
+	return Onset{Frame: frame, Time: time.Duration(center * float64(time.Second)), Strength: strength}
+}
+
+// the detection function's value for the last frame Processed, for drawing it
+func (d *Detector) Envelope() float64 {
+	return d.last
+}
+
+// onsets from the frames of an FFTByFrame
+type Analyzer struct {
+	frames   *fft.FFTByFrame
+	detector *Detector
+	spectrum [][]complex128
+}
+
+func NewAnalyzer(frames *fft.FFTByFrame, cfg Config) *Analyzer {
+	out := &Analyzer{
+		frames:   frames,
+		detector: NewDetector(frames.SampleRate(), frames.FrameSize(), frames.Hop(), cfg),
+		spectrum: make([][]complex128, frames.NumberOutputFrequencies()),
+	}
+
+	for i := range out.spectrum {
+		out.spectrum[i] = make([]complex128, frames.Channels())
+	}
+
+	return out
+}
+
+func (a *Analyzer) HasNext() bool {
+	return a.frames.HasNext()
+}
+
+// transforms the next frame, see Detector.Process
+func (a *Analyzer) Compute() (onset Onset, found bool, err error) {
+	if err = a.frames.ComputeComplex(a.spectrum); err != nil {
+		return
+	}
+
+	onset, found = a.detector.Process(a.spectrum)
+	return
+}
+
+// see Detector.Flush, once HasNext is false
+func (a *Analyzer) Flush() []Onset {
+	return a.detector.Flush()
+}
+
+// every onset in the remaining frames
+func (a *Analyzer) ComputeAll() (onsets []Onset, err error) {
+	for a.HasNext() {
+		var onset Onset
+		var found bool
+		if onset, found, err = a.Compute(); err != nil {
+			return
+		}
+
+		if found {
+			onsets = append(onsets, onset)
+		}
+	}
+
+	onsets = append(onsets, a.Flush()...)
+	return
+}
+
+func (a *Analyzer) Detector() *Detector {
+	return a.detector
+}
+
+// closes the FFTByFrame
+func (a *Analyzer) Close() error {
+	return a.frames.Close()
+}
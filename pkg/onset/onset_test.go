@@ -0,0 +1,130 @@
+package onset_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/audio"
+	"github.com/Twister915/vis.go/pkg/fft"
+	"github.com/Twister915/vis.go/pkg/onset"
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+const testSampleRate = 22050
+
+// decaying notes and noise bursts starting at each of starts, over quiet noise
+func hits(seconds float64, starts []time.Duration) audio.Input {
+	random := rand.New(rand.NewSource(1))
+	samples := util.Create2DFloats(int(seconds*testSampleRate), 1)
+	for i := range samples {
+		samples[i][0] = 0.001 * random.NormFloat64()
+	}
+
+	for n, start := range starts {
+		first := int(start.Seconds() * testSampleRate)
+		hz := 220 * math.Pow(2, float64(n%5)/5)
+		for i := first; i < len(samples); i++ {
+			t := float64(i-first) / testSampleRate
+			envelope := math.Exp(-t * 8)
+			samples[i][0] += 0.4 * envelope * (math.Sin(2*math.Pi*hz*t) + 0.3*math.Exp(-t*40)*random.NormFloat64())
+		}
+	}
+
+	return audio.ToInput(audio.NewMemoryReader(audio.Format{SampleRate: testSampleRate, Channels: 1, BitDepth: 16}, samples))
+}
+
+func analyzer(input audio.Input, cfg onset.Config) *onset.Analyzer {
+	hop := 256 * input.Timebase()
+	return onset.NewAnalyzer(fft.NewFFTByFrameOptions(input, 1024*input.Timebase(), hop, fft.HannWindow, fft.Options{}), cfg)
+}
+
+func TestDetectsEveryHit(t *testing.T) {
+	starts := []time.Duration{300 * time.Millisecond, 700 * time.Millisecond, 1000 * time.Millisecond, 1600 * time.Millisecond, 1850 * time.Millisecond}
+	for _, function := range []onset.Function{onset.SpectralFlux, onset.HighFrequencyContent, onset.ComplexDomain} {
+		cfg := onset.DefaultConfig()
+		cfg.Function = function
+		a := analyzer(hits(2.5, starts), cfg)
+		onsets, err := a.ComputeAll()
+		a.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(onsets) != len(starts) {
+			t.Fatalf("%s found %d onsets, expected %d: %v", function, len(onsets), len(starts), onsets)
+		}
+
+		// within a frame (46ms) of the start, the frame's center can be half of one before it
+		for i, o := range onsets {
+			if d := o.Time - starts[i]; d < -25*time.Millisecond || d > 50*time.Millisecond {
+				t.Fatalf("%s put onset %d at %v, expected %v", function, i, o.Time, starts[i])
+			}
+
+			if o.Strength <= 0 || o.Strength > 1 {
+				t.Fatalf("%s onset %d has strength %v", function, i, o.Strength)
+			}
+		}
+	}
+}
+
+// onsets come out PostAvg after the frame they are in, and the last ones at the end
+func TestStreamingLatency(t *testing.T) {
+	starts := []time.Duration{200 * time.Millisecond, 900 * time.Millisecond}
+	cfg := onset.DefaultConfig()
+	a := analyzer(hits(1, starts), cfg)
+	defer a.Close()
+
+	frame := 0
+	var found []onset.Onset
+	for a.HasNext() {
+		o, ok, err := a.Compute()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok {
+			if lag := frame - o.Frame; lag != 9 {
+				t.Fatalf("onset in frame %d reported with frame %d", o.Frame, frame)
+			}
+
+			found = append(found, o)
+		}
+
+		frame++
+	}
+
+	found = append(found, a.Flush()...)
+	if len(found) != len(starts) {
+		t.Fatalf("found %v", found)
+	}
+}
+
+func TestWait(t *testing.T) {
+	starts := []time.Duration{300 * time.Millisecond, 330 * time.Millisecond}
+	cfg := onset.DefaultConfig()
+	cfg.Wait = 100 * time.Millisecond
+	a := analyzer(hits(1, starts), cfg)
+	onsets, err := a.ComputeAll()
+	a.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(onsets) != 1 {
+		t.Fatalf("found %v", onsets)
+	}
+}
+
+func TestParseFunction(t *testing.T) {
+	for _, f := range []onset.Function{onset.SpectralFlux, onset.HighFrequencyContent, onset.ComplexDomain} {
+		if parsed, err := onset.ParseFunction(f.String()); err != nil || parsed != f {
+			t.Fatalf("%s parsed as %v, %v", f, parsed, err)
+		}
+	}
+
+	if _, err := onset.ParseFunction("energy"); err == nil {
+		t.Fatal("parsed an unknown function")
+	}
+}
@@ -0,0 +1,106 @@
+package onset
+
+// adaptive peak picking (as in librosa.util.peak_pick), frame n is an onset when it is the largest value from n - preMax
+// to n + postMax, at least delta above the mean from n - preAvg to n + postAvg, and more than wait frames after the last
+// onset. Looking ahead means frame n is only decided once frame n + max(postMax, postAvg) has been added
+type peakPicker struct {
+	preMax, postMax int
+	preAvg, postAvg int
+	wait            int
+	delta           float64
+
+	// values from frame first on
+	values []float64
+	first  int
+
+	// the next frame to decide
+	next      int
+	lastOnset int
+}
+
+func (p *peakPicker) lookahead() int {
+	if p.postMax > p.postAvg {
+		return p.postMax
+	}
+
+	return p.postAvg
+}
+
+func (p *peakPicker) lookbehind() int {
+	if p.preMax > p.preAvg {
+		return p.preMax
+	}
+
+	return p.preAvg
+}
+
+// adds the next frame's value, and decides the frame lookahead frames before it (when there is one). ok is set when it
+// is an onset
+func (p *peakPicker) add(v float64) (frame int, decided, ok bool) {
+	p.values = append(p.values, v)
+	if p.first+len(p.values)-1-p.next < p.lookahead() {
+		return
+	}
+
+	decided = true
+	frame, ok = p.decide()
+	return
+}
+
+// decides every frame still waiting for the frames after it, as if the values ended where they do
+func (p *peakPicker) flush() (frames []int) {
+	for p.next < p.first+len(p.values) {
+		if frame, ok := p.decide(); ok {
+			frames = append(frames, frame)
+		}
+	}
+
+	return
+}
+
+func (p *peakPicker) decide() (frame int, ok bool) {
+	frame = p.next
+	p.next++
+	defer p.trim()
+
+	v := p.at(frame)
+	last := p.first + len(p.values) - 1
+	for i := frame - p.preMax; i <= frame+p.postMax; i++ {
+		if i >= p.first && i <= last && p.at(i) > v {
+			return
+		}
+	}
+
+	var sum float64
+	var n int
+	for i := frame - p.preAvg; i <= frame+p.postAvg; i++ {
+		if i >= p.first && i <= last {
+			sum += p.at(i)
+			n++
+		}
+	}
+
+	if v < sum/float64(n)+p.delta {
+		return
+	}
+
+	if p.lastOnset >= 0 && frame-p.lastOnset <= p.wait {
+		return
+	}
+
+	p.lastOnset = frame
+	ok = true
+	return
+}
+
+func (p *peakPicker) at(frame int) float64 {
+	return p.values[frame-p.first]
+}
+
+// drops the values no frame left to decide looks back to
+func (p *peakPicker) trim() {
+	if drop := p.next - p.lookbehind() - p.first; drop > len(p.values)/2 && drop > 0 {
+		p.values = append(p.values[:0], p.values[drop:]...)
+		p.first += drop
+	}
+}
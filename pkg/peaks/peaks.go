@@ -0,0 +1,242 @@
+package peaks
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/Twister915/vis.go/pkg/fft"
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+// spectral peak picking, finding the sinusoids (partials) in a frame and estimating their frequency and amplitude more
+// finely than the bins. Spectra are indexed [bin][channel] like FFTByFrame.Compute, and the channels are averaged
+
+// how a peak's frequency and magnitude are estimated from its bin and the bins either side
+type Interpolation int
+
+const (
+	// the vertex of the parabola through the log magnitudes, which is exact for a Gaussian window and within a few
+	// hundredths of a bin for the usual ones (Hann, Blackman...). With Config.Decibels the values are already logs, and
+	// this is the same as Parabolic
+	Gaussian Interpolation = iota
+
+	// the vertex of the parabola through the values as they are
+	Parabolic
+
+	// the bin itself
+	NoInterpolation
+)
+
+var interpolationNames = []string{"gaussian", "parabolic", "none"}
+
+func (i Interpolation) String() string {
+	if i < 0 || int(i) >= len(interpolationNames) {
+		return fmt.Sprintf("Interpolation(%d)", int(i))
+	}
+
+	return interpolationNames[i]
+}
+
+// parses the names returned by Interpolation.String, case insensitive
+func ParseInterpolation(s string) (out Interpolation, err error) {
+	for i, name := range interpolationNames {
+		if strings.EqualFold(s, name) {
+			out = Interpolation(i)
+			return
+		}
+	}
+
+	err = fmt.Errorf("unknown interpolation '%s'", s)
+	return
+}
+
+type Config struct {
+	Interpolation Interpolation
+
+	// keep only this many peaks, the largest. 0 keeps all of them
+	MaxPeaks int
+
+	// peaks more than this many dB below the frame's largest are dropped. 0 keeps all of them
+	RelativeThreshold float64
+
+	// peaks smaller than this (in the spectrum's units, so dB with Decibels) are dropped. 0 keeps all of them
+	MinMagnitude float64
+
+	// the spectrum is in dB (Scaling: fft.DBFS), so RelativeThreshold is subtracted from the largest peak rather than
+	// scaling it, and Gaussian interpolation fits the values as they are
+	Decibels bool
+
+	// only peaks between these frequencies are kept, a MaxFreq of 0 means the Nyquist frequency
+	MinFreq, MaxFreq float64
+}
+
+type Peak struct {
+	// the interpolated bin, fractional
+	Bin float64
+
+	// in Hz
+	Frequency float64
+
+	// the interpolated height of the peak, in the spectrum's units. With Scaling: fft.Magnitude a sinusoid of amplitude A
+	// is A times half the window's sum
+	Magnitude float64
+}
+
+// finds the peaks of spectra from fftSize point transforms at sampleRate
+type Picker struct {
+	cfg   Config
+	binHz float64
+
+	mixed []float64
+}
+
+func NewPicker(sampleRate, fftSize int, cfg Config) *Picker {
+	return &Picker{cfg: cfg, binHz: float64(sampleRate) / float64(fftSize)}
+}
+
+// the peaks of one spectrum in order of frequency, appended to dst[:0]
+func (p *Picker) Find(spectrum [][]float64, dst []Peak) []Peak {
+	dst = dst[:0]
+	if cap(p.mixed) < len(spectrum) {
+		p.mixed = make([]float64, len(spectrum))
+	}
+
+	mixed := p.mixed[:len(spectrum)]
+	for k, bin := range spectrum {
+		var sum float64
+		for _, v := range bin {
+			sum += v
+		}
+
+		mixed[k] = sum / float64(len(bin))
+	}
+
+	for k := 1; k < len(mixed)-1; k++ {
+		a, b, c := mixed[k-1], mixed[k], mixed[k+1]
+		if b <= a || b < c || (p.cfg.MinMagnitude != 0 && b < p.cfg.MinMagnitude) {
+			continue
+		}
+
+		peak := p.interpolate(k, a, b, c)
+		if peak.Frequency < p.cfg.MinFreq || (p.cfg.MaxFreq > 0 && peak.Frequency > p.cfg.MaxFreq) {
+			continue
+		}
+
+		dst = append(dst, peak)
+	}
+
+	if p.cfg.RelativeThreshold > 0 && len(dst) > 0 {
+		largest := dst[0].Magnitude
+		for _, peak := range dst[1:] {
+			largest = math.Max(largest, peak.Magnitude)
+		}
+
+		threshold := largest * math.Pow(10, -p.cfg.RelativeThreshold/20)
+		if p.cfg.Decibels {
+			threshold = largest - p.cfg.RelativeThreshold
+		}
+
+		kept := dst[:0]
+		for _, peak := range dst {
+			if peak.Magnitude >= threshold {
+				kept = append(kept, peak)
+			}
+		}
+
+		dst = kept
+	}
+
+	if p.cfg.MaxPeaks > 0 && len(dst) > p.cfg.MaxPeaks {
+		sort.Slice(dst, func(i, j int) bool { return dst[i].Magnitude > dst[j].Magnitude })
+		dst = dst[:p.cfg.MaxPeaks]
+		sort.Slice(dst, func(i, j int) bool { return dst[i].Bin < dst[j].Bin })
+	}
+
+	return dst
+}
+
+// the peak at bin k, with the values a and c either side of it
+func (p *Picker) interpolate(k int, a, b, c float64) (out Peak) {
+	out.Bin, out.Magnitude = float64(k), b
+	switch {
+	case p.cfg.Interpolation == Gaussian && !p.cfg.Decibels:
+		if a > 0 && c > 0 {
+			offset, height := vertex(math.Log(a), math.Log(b), math.Log(c))
+			out.Bin += offset
+			out.Magnitude = math.Exp(height)
+		}
+	case p.cfg.Interpolation == Gaussian, p.cfg.Interpolation == Parabolic:
+		offset, height := vertex(a, b, c)
+		out.Bin += offset
+		out.Magnitude = height
+	}
+
+	out.Frequency = out.Bin * p.binHz
+	return
+}
+
+// the offset (from -0.5 to 0.5) and height of the vertex of the parabola through (-1, a), (0, b) and (1, c), where b is
+// at least as large as the others
+func vertex(a, b, c float64) (offset, height float64) {
+	curvature := a - 2*b + c
+	if curvature >= 0 {
+		return 0, b
+	}
+
+	offset = 0.5 * (a - c) / curvature
+	height = b - 0.25*(a-c)*offset
+	return
+}
+
+// peaks frame by frame from an FFTByFrame, which should use Scaling: fft.Magnitude (or Power) for Gaussian interpolation
+type Analyzer struct {
+	frames   *fft.FFTByFrame
+	picker   *Picker
+	spectrum [][]float64
+}
+
+func NewAnalyzer(frames *fft.FFTByFrame, cfg Config) *Analyzer {
+	return &Analyzer{
+		frames:   frames,
+		picker:   NewPicker(frames.SampleRate(), frames.FFTSize(), cfg),
+		spectrum: util.Create2DFloats(frames.NumberOutputFrequencies(), frames.Channels()),
+	}
+}
+
+func (a *Analyzer) HasNext() bool {
+	return a.frames.HasNext()
+}
+
+// the peaks of the next frame
+func (a *Analyzer) Compute() (out []Peak, err error) {
+	if err = a.frames.Compute(a.spectrum); err != nil {
+		return
+	}
+
+	out = a.picker.Find(a.spectrum, nil)
+	return
+}
+
+// the peaks of every remaining frame, linked into tracks. The tracks are in the order they ended
+func (a *Analyzer) ComputeTracks(cfg TrackerConfig) (tracks []Track, err error) {
+	tracker := NewTracker(cfg)
+	var peaks []Peak
+	for a.HasNext() {
+		if err = a.frames.Compute(a.spectrum); err != nil {
+			return
+		}
+
+		peaks = a.picker.Find(a.spectrum, peaks)
+		tracks = append(tracks, tracker.Add(peaks)...)
+	}
+
+	tracks = append(tracks, tracker.Flush()...)
+	return
+}
+
+// closes the FFTByFrame
+func (a *Analyzer) Close() error {
+	return a.frames.Close()
+}
@@ -0,0 +1,341 @@
+package peaks_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/audio"
+	"github.com/Twister915/vis.go/pkg/fft"
+	"github.com/Twister915/vis.go/pkg/peaks"
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+const (
+	testSampleRate = 8000
+	testFFTSize    = 1024
+)
+
+type partial struct {
+	// the frequency at the start and end, gliding linearly between them
+	from, to  float64
+	amplitude float64
+}
+
+// one channel, seconds long, with the phase of each partial integrated so glides are smooth
+func synthesize(seconds float64, partials ...partial) [][]float64 {
+	n := int(seconds * testSampleRate)
+	samples := util.Create2DFloats(n, 1)
+	for _, p := range partials {
+		var phase float64
+		for i := range samples {
+			hz := p.from + (p.to-p.from)*float64(i)/float64(n)
+			samples[i][0] += p.amplitude * math.Sin(phase)
+			phase += 2 * math.Pi * hz / testSampleRate
+		}
+	}
+
+	return samples
+}
+
+func newAnalyzer(samples [][]float64, cfg peaks.Config) *peaks.Analyzer {
+	input := audio.ToInput(audio.NewMemoryReader(audio.Format{SampleRate: testSampleRate, Channels: 1, BitDepth: 16}, samples))
+	frameSize := time.Duration(testFFTSize) * input.Timebase()
+	frames := fft.NewFFTByFrameOptions(input, frameSize, frameSize/4, fft.HannWindow, fft.Options{Scaling: fft.Magnitude})
+	return peaks.NewAnalyzer(frames, cfg)
+}
+
+// the first frame's peaks
+func find(t *testing.T, samples [][]float64, cfg peaks.Config) []peaks.Peak {
+	a := newAnalyzer(samples, cfg)
+	defer a.Close()
+
+	found, err := a.Compute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return found
+}
+
+func TestInterpolation(t *testing.T) {
+	binHz := float64(testSampleRate) / testFFTSize
+	// between bins, but not halfway where symmetry makes every method's frequency exact
+	for _, hz := range []float64{1000 + binHz/4, 1000 + binHz*0.35} {
+		samples := synthesize(0.2, partial{from: hz, to: hz, amplitude: 0.5})
+		// a sinusoid's magnitude with a Hann window and Magnitude scaling is its amplitude times half the window's sum
+		expected := 0.5 * testFFTSize / 4
+
+		errors := make(map[peaks.Interpolation]float64)
+		for _, interpolation := range []peaks.Interpolation{peaks.Gaussian, peaks.Parabolic, peaks.NoInterpolation} {
+			found := find(t, samples, peaks.Config{Interpolation: interpolation, RelativeThreshold: 60})
+			if len(found) != 1 {
+				t.Fatalf("%v: %v peaks", interpolation, found)
+			}
+
+			errors[interpolation] = math.Abs(found[0].Frequency-hz) / binHz
+			t.Logf("%v at %.2f Hz: %.2f Hz (%.4f bins off), magnitude %.2f (expected %.2f)", interpolation, hz, found[0].Frequency, errors[interpolation], found[0].Magnitude, expected)
+
+			switch interpolation {
+			case peaks.Gaussian:
+				if errors[interpolation] > 0.02 {
+					t.Fatalf("gaussian frequency %v bins off", errors[interpolation])
+				}
+
+				// the Hann window isn't quite Gaussian, the height comes out a little over
+				if !closeTo(found[0].Magnitude, expected, 0.03) {
+					t.Fatalf("gaussian magnitude %v, expected %v", found[0].Magnitude, expected)
+				}
+			case peaks.Parabolic:
+				if errors[interpolation] > 0.1 {
+					t.Fatalf("parabolic frequency %v bins off", errors[interpolation])
+				}
+
+				if !closeTo(found[0].Magnitude, expected, 0.06) {
+					t.Fatalf("parabolic magnitude %v, expected %v", found[0].Magnitude, expected)
+				}
+			}
+		}
+
+		if errors[peaks.Gaussian] >= errors[peaks.Parabolic] || errors[peaks.Parabolic] >= errors[peaks.NoInterpolation] {
+			t.Fatalf("errors %v", errors)
+		}
+	}
+}
+
+func TestThresholds(t *testing.T) {
+	samples := synthesize(0.2,
+		partial{from: 500, to: 500, amplitude: 0.5},
+		// 20 dB down
+		partial{from: 1200, to: 1200, amplitude: 0.05},
+		// 40 dB down
+		partial{from: 2500, to: 2500, amplitude: 0.005},
+	)
+
+	frequencies := func(found []peaks.Peak) (out []float64) {
+		for _, p := range found {
+			out = append(out, math.Round(p.Frequency))
+		}
+
+		return
+	}
+
+	check := func(name string, cfg peaks.Config, expected ...float64) {
+		found := frequencies(find(t, samples, cfg))
+		if len(found) != len(expected) {
+			t.Fatalf("%s: found %v, expected %v", name, found, expected)
+		}
+
+		for i := range found {
+			if math.Abs(found[i]-expected[i]) > 2 {
+				t.Fatalf("%s: found %v, expected %v", name, found, expected)
+			}
+		}
+	}
+
+	// the Hann window's sidelobes are over 60 dB down
+	check("relative 60", peaks.Config{RelativeThreshold: 60}, 500, 1200, 2500)
+	check("relative 30", peaks.Config{RelativeThreshold: 30}, 500, 1200)
+	check("max peaks", peaks.Config{RelativeThreshold: 60, MaxPeaks: 2}, 500, 1200)
+	check("max peaks 1", peaks.Config{RelativeThreshold: 60, MaxPeaks: 1}, 500)
+	check("range", peaks.Config{RelativeThreshold: 60, MinFreq: 1000, MaxFreq: 2000}, 1200)
+
+	// the 1200 Hz peak is 0.05 * 1024 / 4 = 12.8
+	check("absolute", peaks.Config{MinMagnitude: 10, MaxPeaks: 10}, 500, 1200)
+}
+
+func TestDecibels(t *testing.T) {
+	samples := synthesize(0.2,
+		// -6 dBFS
+		partial{from: 500, to: 500, amplitude: 0.5},
+		// -26 dBFS
+		partial{from: 1200, to: 1200, amplitude: 0.05},
+		// -46 dBFS
+		partial{from: 2500, to: 2500, amplitude: 0.005},
+	)
+
+	input := audio.ToInput(audio.NewMemoryReader(audio.Format{SampleRate: testSampleRate, Channels: 1, BitDepth: 16}, samples))
+	frameSize := time.Duration(testFFTSize) * input.Timebase()
+	frames := fft.NewFFTByFrameOptions(input, frameSize, frameSize, fft.HannWindow, fft.Options{Scaling: fft.DBFS})
+	defer frames.Close()
+
+	spectrum := util.Create2DFloats(frames.NumberOutputFrequencies(), 1)
+	if err := frames.Compute(spectrum); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(name string, cfg peaks.Config, expected ...float64) {
+		cfg.Decibels = true
+		found := peaks.NewPicker(testSampleRate, testFFTSize, cfg).Find(spectrum, nil)
+		if len(found) != len(expected) {
+			t.Fatalf("%s: found %v, expected %v", name, found, expected)
+		}
+
+		for i, peak := range found {
+			if math.Abs(peak.Frequency-expected[i]) > 2 {
+				t.Fatalf("%s: found %v, expected %v", name, found, expected)
+			}
+		}
+	}
+
+	check("relative 30", peaks.Config{RelativeThreshold: 30}, 500, 1200)
+	check("relative 50", peaks.Config{RelativeThreshold: 50}, 500, 1200, 2500)
+	check("absolute", peaks.Config{MinMagnitude: -35, MaxPeaks: 10}, 500, 1200)
+	check("max peaks", peaks.Config{MaxPeaks: 1}, 500)
+
+	// the sidelobes and noise floor have peaks of their own, but the partials are among them
+	all := peaks.NewPicker(testSampleRate, testFFTSize, peaks.Config{Decibels: true}).Find(spectrum, nil)
+	if len(all) < 3 {
+		t.Fatalf("found %v", all)
+	}
+
+	// fitting the dB values is Gaussian interpolation of the magnitudes
+	for _, interpolation := range []peaks.Interpolation{peaks.Gaussian, peaks.Parabolic} {
+		found := peaks.NewPicker(testSampleRate, testFFTSize, peaks.Config{Interpolation: interpolation, Decibels: true, MaxPeaks: 1}).Find(spectrum, nil)
+		if expected := 20 * math.Log10(0.5); math.Abs(found[0].Magnitude-expected) > 0.1 {
+			t.Fatalf("%v: %v dB, expected %v", interpolation, found[0].Magnitude, expected)
+		}
+	}
+}
+
+func TestDecibelSpectrum(t *testing.T) {
+	spectrum := util.Create2DFloats(64, 1)
+	for k := range spectrum {
+		spectrum[k][0] = -100
+	}
+
+	spectrum[10][0], spectrum[9][0], spectrum[11][0] = -10, -16, -16
+	spectrum[40][0], spectrum[39][0], spectrum[41][0] = -30, -36, -36
+
+	for _, cfg := range []peaks.Config{
+		{Decibels: true},
+		{Decibels: true, MinMagnitude: -1000, RelativeThreshold: 30},
+	} {
+		found := peaks.NewPicker(testSampleRate, testFFTSize, cfg).Find(spectrum, nil)
+		if len(found) != 2 || found[0].Bin != 10 || found[1].Bin != 40 || found[0].Magnitude != -10 {
+			t.Fatalf("%+v: found %v", cfg, found)
+		}
+	}
+
+	found := peaks.NewPicker(testSampleRate, testFFTSize, peaks.Config{Decibels: true, RelativeThreshold: 10}).Find(spectrum, nil)
+	if len(found) != 1 || found[0].Bin != 10 {
+		t.Fatalf("found %v", found)
+	}
+}
+
+func TestTracks(t *testing.T) {
+	samples := synthesize(2,
+		partial{from: 400, to: 600, amplitude: 0.3},
+		partial{from: 1500, to: 1500, amplitude: 0.3},
+		partial{from: 3000, to: 2500, amplitude: 0.1},
+	)
+
+	a := newAnalyzer(samples, peaks.Config{RelativeThreshold: 40})
+	defer a.Close()
+
+	tracks, err := a.ComputeTracks(peaks.TrackerConfig{MaxGap: 2, MinLength: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tracks) != 3 {
+		for _, track := range tracks {
+			t.Logf("track %d: %d peaks from frame %d, %v to %v Hz", track.ID, len(track.Peaks), track.Start(), track.Peaks[0].Frequency, track.Last().Frequency)
+		}
+
+		t.Fatalf("%d tracks", len(tracks))
+	}
+
+	frames := (len(samples)-testFFTSize)/(testFFTSize/4) + 1
+	for _, track := range tracks {
+		if track.Start() != 0 || len(track.Peaks) != frames {
+			t.Fatalf("track %d: %d peaks from frame %d, expected %d from 0", track.ID, len(track.Peaks), track.Start(), frames)
+		}
+	}
+
+	starts := []float64{400, 1500, 3000}
+	ends := []float64{600, 1500, 2500}
+	for _, track := range tracks {
+		first, last := track.Peaks[0].Frequency, track.Last().Frequency
+		var matched bool
+		for i := range starts {
+			// the first and last frames are centered half a frame (64 ms) inside the glides, 16 Hz into the steepest
+			if math.Abs(first-starts[i]) < 25 && math.Abs(last-ends[i]) < 25 {
+				matched = true
+			}
+		}
+
+		if !matched {
+			t.Fatalf("track %d from %v to %v Hz", track.ID, first, last)
+		}
+
+		for i := 1; i < len(track.Frames); i++ {
+			if track.Frames[i] != track.Frames[i-1]+1 {
+				t.Fatalf("track %d has a gap at %d", track.ID, track.Frames[i])
+			}
+		}
+	}
+}
+
+func TestTrackerGaps(t *testing.T) {
+	tracker := peaks.NewTracker(peaks.TrackerConfig{MaxGap: 1, MinLength: 2})
+	peak := func(hz float64) peaks.Peak { return peaks.Peak{Frequency: hz, Magnitude: 1} }
+
+	var ended []peaks.Track
+	ended = append(ended, tracker.Add([]peaks.Peak{peak(440), peak(880)})...)
+	// 880 dips out for a frame, 440 glides by 30 cents
+	ended = append(ended, tracker.Add([]peaks.Peak{peak(447.7)})...)
+	ended = append(ended, tracker.Add([]peaks.Peak{peak(447.7), peak(881)})...)
+	// 447.7 disappears for good, and a blip too short to keep comes and goes
+	ended = append(ended, tracker.Add([]peaks.Peak{peak(882), peak(2000)})...)
+	ended = append(ended, tracker.Add([]peaks.Peak{peak(882)})...)
+	ended = append(ended, tracker.Add([]peaks.Peak{peak(882)})...)
+	if len(ended) != 1 || ended[0].ID != 0 || len(ended[0].Peaks) != 3 {
+		t.Fatalf("ended %+v", ended)
+	}
+
+	if active := tracker.Active(); len(active) != 1 || active[0].ID != 1 {
+		t.Fatalf("active %+v", active)
+	}
+
+	ended = tracker.Flush()
+	if len(ended) != 1 || ended[0].ID != 1 {
+		t.Fatalf("flushed %+v", ended)
+	}
+
+	expected := []int{0, 2, 3, 4, 5}
+	for i, frame := range ended[0].Frames {
+		if frame != expected[i] {
+			t.Fatalf("frames %v", ended[0].Frames)
+		}
+	}
+
+	// a jump of more than 50 cents starts a new track
+	tracker = peaks.NewTracker(peaks.TrackerConfig{})
+	tracker.Add([]peaks.Peak{peak(440)})
+	tracker.Add([]peaks.Peak{peak(466)})
+	if active := tracker.Active(); len(active) != 1 || active[0].ID != 1 {
+		t.Fatalf("active %+v", active)
+	}
+
+	if ended := tracker.Flush(); len(ended) != 1 {
+		t.Fatalf("flushed %+v", ended)
+	}
+}
+
+func TestParseInterpolation(t *testing.T) {
+	for _, i := range []peaks.Interpolation{peaks.Gaussian, peaks.Parabolic, peaks.NoInterpolation} {
+		parsed, err := peaks.ParseInterpolation(i.String())
+		if err != nil || parsed != i {
+			t.Fatalf("%v: %v, %v", i, parsed, err)
+		}
+	}
+
+	if _, err := peaks.ParseInterpolation("cubic"); err == nil {
+		t.Fatal("parsed cubic")
+	}
+}
+
+func closeTo(a, b, rel float64) bool {
+	return math.Abs(a-b) <= rel*math.Abs(b)
+}
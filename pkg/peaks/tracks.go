@@ -0,0 +1,132 @@
+package peaks
+
+import (
+	"math"
+	"sort"
+)
+
+// partial tracking (McAulay & Quatieri, 1986), linking the peaks of successive frames into tracks which follow one
+// sinusoid as it glides, starts and stops
+
+type TrackerConfig struct {
+	// a peak continues a track when it is within this many cents of the track's last frequency, 0 means 50 (a quarter
+	// tone)
+	MaxJump float64
+
+	// frames a track may go without a peak before it ends, for partials which briefly dip below the picker's thresholds
+	MaxGap int
+
+	// shorter tracks are dropped when they end, 0 keeps all of them
+	MinLength int
+}
+
+const defaultMaxJump = 50.0
+
+type Track struct {
+	// unique within a Tracker, in the order the tracks started
+	ID int
+
+	// the frame (counting from 0) of each peak, increasing but with gaps of up to MaxGap
+	Frames []int
+	Peaks  []Peak
+}
+
+// the frame the track started in
+func (t *Track) Start() int {
+	return t.Frames[0]
+}
+
+// the last peak
+func (t *Track) Last() Peak {
+	return t.Peaks[len(t.Peaks)-1]
+}
+
+type Tracker struct {
+	cfg TrackerConfig
+
+	active []*Track
+	frame  int
+	nextID int
+}
+
+func NewTracker(cfg TrackerConfig) *Tracker {
+	if cfg.MaxJump <= 0 {
+		cfg.MaxJump = defaultMaxJump
+	}
+
+	return &Tracker{cfg: cfg}
+}
+
+// adds the peaks of the next frame. The largest peaks are linked first, each to the closest active track not yet
+// continued in this frame, and peaks left over start new tracks. Tracks which have gone more than MaxGap frames without a
+// peak end, and those at least MinLength long are returned
+func (t *Tracker) Add(peaks []Peak) (ended []Track) {
+	order := make([]int, len(peaks))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.Slice(order, func(i, j int) bool { return peaks[order[i]].Magnitude > peaks[order[j]].Magnitude })
+
+	continued := make(map[*Track]bool, len(t.active))
+	for _, i := range order {
+		peak := peaks[i]
+		var closest *Track
+		best := t.cfg.MaxJump
+		for _, track := range t.active {
+			if continued[track] {
+				continue
+			}
+
+			if cents := math.Abs(1200 * math.Log2(peak.Frequency/track.Last().Frequency)); cents <= best {
+				closest, best = track, cents
+			}
+		}
+
+		if closest == nil {
+			closest = &Track{ID: t.nextID}
+			t.nextID++
+			t.active = append(t.active, closest)
+		}
+
+		closest.Frames = append(closest.Frames, t.frame)
+		closest.Peaks = append(closest.Peaks, peak)
+		continued[closest] = true
+	}
+
+	still := t.active[:0]
+	for _, track := range t.active {
+		if t.frame-track.Frames[len(track.Frames)-1] > t.cfg.MaxGap {
+			ended = t.end(ended, track)
+		} else {
+			still = append(still, track)
+		}
+	}
+
+	t.active = still
+	t.frame++
+	return
+}
+
+// ends every active track, at the end of the input
+func (t *Tracker) Flush() (ended []Track) {
+	for _, track := range t.active {
+		ended = t.end(ended, track)
+	}
+
+	t.active = nil
+	return
+}
+
+func (t *Tracker) end(ended []Track, track *Track) []Track {
+	if len(track.Peaks) < t.cfg.MinLength {
+		return ended
+	}
+
+	return append(ended, *track)
+}
+
+// the tracks still going, which the caller must not change
+func (t *Tracker) Active() []*Track {
+	return t.active
+}
@@ -0,0 +1,283 @@
+package pitch
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/audio"
+	"github.com/Twister915/vis.go/pkg/chroma"
+	"github.com/Twister915/vis.go/pkg/fft"
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+// fundamental frequency tracking for monophonic sources (one voice or instrument) with YIN (de Cheveigné & Kawahara,
+// 2002). The magnitude spectrum shows the harmonics but not which of them is the fundamental, YIN instead finds the
+// shortest lag at which the signal repeats itself
+
+type Config struct {
+	// the range of fundamentals searched, 0 means C2 (65 Hz) and C7 (2093 Hz) like librosa's pyin
+	MinFreq, MaxFreq float64
+
+	// the first dip of the normalized difference function below this is taken as the period, lower is stricter. 0 means
+	// 0.1, librosa's default
+	Threshold float64
+
+	// frames with less RMS than this are silent, and unvoiced. 0 means 0.001 (-60 dBFS)
+	MinRMS float64
+
+	// the tuning of A4 for note names, 0 means 440 Hz
+	A4 float64
+}
+
+const (
+	defaultMinFreq   = 65.40639
+	defaultMaxFreq   = 2093.005
+	defaultThreshold = 0.1
+	defaultMinRMS    = 0.001
+)
+
+func (c Config) withDefaults() Config {
+	if c.MinFreq <= 0 {
+		c.MinFreq = defaultMinFreq
+	}
+
+	if c.MaxFreq <= 0 {
+		c.MaxFreq = defaultMaxFreq
+	}
+
+	if c.Threshold <= 0 {
+		c.Threshold = defaultThreshold
+	}
+
+	if c.MinRMS <= 0 {
+		c.MinRMS = defaultMinRMS
+	}
+
+	if c.A4 <= 0 {
+		c.A4 = chroma.DefaultA4
+	}
+
+	return c
+}
+
+// the pitch of one frame
+type Estimate struct {
+	// the center of the frame
+	Time time.Duration
+
+	// the fundamental in Hz, the best guess even when the frame is unvoiced (0 when it is silent)
+	Frequency float64
+
+	// 1 minus the normalized difference at the chosen lag, 1 for a perfectly periodic frame
+	Confidence float64
+
+	// the normalized difference dipped below Config.Threshold, so there is a pitch
+	Voiced bool
+
+	a4 float64
+}
+
+// the MIDI note number, fractional between notes
+func (e Estimate) MIDI() float64 {
+	return chroma.Pitch(e.Frequency, e.a4)
+}
+
+// the nearest note, like "A4" or "C#3", empty for unvoiced frames
+func (e Estimate) Note() string {
+	if !e.Voiced {
+		return ""
+	}
+
+	return NoteName(int(math.Round(e.MIDI())))
+}
+
+// how far the pitch is above the nearest note, from -50 to 50
+func (e Estimate) Cents() float64 {
+	midi := e.MIDI()
+	return 100 * (midi - math.Round(midi))
+}
+
+// the name of a MIDI note, 60 is "C4"
+func NoteName(midi int) string {
+	class := ((midi % chroma.PitchClasses) + chroma.PitchClasses) % chroma.PitchClasses
+	octave := int(math.Floor(float64(midi)/chroma.PitchClasses)) - 1
+	return fmt.Sprintf("%s%d", chroma.PitchClassNames[class], octave)
+}
+
+// estimates the pitch of every hop of an input, the channels are mixed. Each frame is twice the longest period
+type Tracker struct {
+	input audio.Input
+	cfg   Config
+	hop   int
+
+	// the shortest and longest lag searched, and the integration window (which is the longest lag)
+	minLag, maxLag int
+	window         int
+
+	correlator *fft.Correlator
+	frame      int
+
+	samples    [][]float64
+	mono       []float64
+	correlated []float64
+	energy     []float64
+	difference []float64
+}
+
+// a hop shorter than one sample is an error, the tracker would never move
+func NewTracker(input audio.Input, hop time.Duration, cfg Config, opts fft.Options) (out *Tracker, err error) {
+	hopSamples := int(hop / input.Timebase())
+	if hopSamples < 1 {
+		err = fmt.Errorf("hop %v is shorter than one sample (%v)", hop, input.Timebase())
+		return
+	}
+
+	cfg = cfg.withDefaults()
+	sampleRate := float64(input.SampleRate())
+
+	out = &Tracker{
+		input:  input,
+		cfg:    cfg,
+		hop:    hopSamples,
+		minLag: int(math.Floor(sampleRate / cfg.MaxFreq)),
+		maxLag: int(math.Ceil(sampleRate / cfg.MinFreq)),
+	}
+
+	if out.minLag < 2 {
+		out.minLag = 2
+	}
+
+	out.window = out.maxLag
+	frameSize := out.window + out.maxLag + 1
+	out.correlator = fft.NewCorrelator(out.window, frameSize, opts)
+	out.samples = util.Create2DFloats(frameSize, input.Channels())
+	out.mono = make([]float64, frameSize)
+	out.correlated = make([]float64, out.correlator.Lags())
+	out.energy = make([]float64, frameSize+1)
+	out.difference = make([]float64, out.maxLag+2)
+	return
+}
+
+// samples in each frame
+func (t *Tracker) FrameSize() int {
+	return len(t.mono)
+}
+
+func (t *Tracker) Hop() int {
+	return t.hop
+}
+
+// the lowest and highest fundamentals searched, in Hz
+func (t *Tracker) Range() (lowest, highest float64) {
+	return t.cfg.MinFreq, t.cfg.MaxFreq
+}
+
+func (t *Tracker) HasNext() bool {
+	return t.input.Has(len(t.samples))
+}
+
+// the pitch of the next frame
+func (t *Tracker) Next() (out Estimate, err error) {
+	if _, err = t.input.ReadSamples(t.samples); err != nil {
+		return
+	}
+
+	if err = t.input.Seek(t.hop - len(t.samples)); err != nil {
+		return
+	}
+
+	sampleRate := float64(t.input.SampleRate())
+	center := float64(t.frame*t.hop+len(t.samples)/2) / sampleRate
+	out = Estimate{Time: time.Duration(center * float64(time.Second)), a4: t.cfg.A4}
+	t.frame++
+
+	// mixed to mono, with the running sum of squares
+	for i, frame := range t.samples {
+		var sum float64
+		for _, v := range frame {
+			sum += v
+		}
+
+		t.mono[i] = sum / float64(len(frame))
+		t.energy[i+1] = t.energy[i] + t.mono[i]*t.mono[i]
+	}
+
+	if rms := math.Sqrt(t.energy[t.window] / float64(t.window)); rms < t.cfg.MinRMS {
+		return
+	}
+
+	t.cumulativeMeanNormalizedDifference()
+	lag := t.pickLag()
+
+	// the vertex of the parabola through the chosen lag and its neighbours
+	d := t.difference
+	period := float64(lag)
+	if a, b, c := d[lag-1], d[lag], d[lag+1]; a-2*b+c > 0 {
+		period += 0.5 * (a - c) / (a - 2*b + c)
+	}
+
+	out.Frequency = sampleRate / period
+	out.Confidence = math.Max(0, math.Min(1, 1-d[lag]))
+	out.Voiced = d[lag] < t.cfg.Threshold
+	return
+}
+
+// YIN's d'(τ), the squared difference between the window and the window τ later, divided by its mean over the lags up
+// to τ. The difference is E(0) + E(τ) - 2 r(τ), with the energies E from running sums and the correlation r by FFT
+func (t *Tracker) cumulativeMeanNormalizedDifference() {
+	t.correlator.Correlate(t.mono[:t.window], t.mono, t.correlated)
+
+	d := t.difference
+	d[0] = 1
+	var sum float64
+	for lag := 1; lag < len(d); lag++ {
+		difference := t.energy[t.window] + t.energy[lag+t.window] - t.energy[lag] - 2*t.correlated[lag]
+		sum += difference
+		if sum > 0 {
+			d[lag] = difference * float64(lag) / sum
+		} else {
+			d[lag] = 1
+		}
+	}
+}
+
+// the bottom of the first dip below the threshold, or the lowest point when there is none
+func (t *Tracker) pickLag() int {
+	d := t.difference
+	lowest := t.minLag
+	for lag := t.minLag; lag <= t.maxLag; lag++ {
+		if d[lag] < t.cfg.Threshold {
+			for lag < t.maxLag && d[lag+1] < d[lag] {
+				lag++
+			}
+
+			return lag
+		}
+
+		if d[lag] < d[lowest] {
+			lowest = lag
+		}
+	}
+
+	return lowest
+}
+
+// the pitch of every remaining frame
+func (t *Tracker) ComputeAll() (all []Estimate, err error) {
+	for t.HasNext() {
+		var e Estimate
+		if e, err = t.Next(); err != nil {
+			return
+		}
+
+		all = append(all, e)
+	}
+
+	return
+}
+
+// releases the correlator's plans, the input is left to whoever opened it
+func (t *Tracker) Close() {
+	t.correlator.Close()
+}
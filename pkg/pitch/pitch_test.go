@@ -0,0 +1,114 @@
+package pitch_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/audio"
+	"github.com/Twister915/vis.go/pkg/fft"
+	"github.com/Twister915/vis.go/pkg/pitch"
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+const testSampleRate = 22050
+
+// a sawtooth-like tone (harmonics falling off as 1 / k) at hz for each second of notes, so the strongest peak of the
+// spectrum is not always the fundamental. 0 is silence
+func melody(notes ...float64) audio.Input {
+	samples := util.Create2DFloats(len(notes)*testSampleRate, 2)
+	random := rand.New(rand.NewSource(3))
+	for i := range samples {
+		hz := notes[i/testSampleRate]
+		t := float64(i) / testSampleRate
+		var v float64
+		for k := 1; hz > 0 && float64(k)*hz < testSampleRate/2; k++ {
+			v += math.Sin(2*math.Pi*float64(k)*hz*t) / float64(k)
+		}
+
+		samples[i][0] = 0.3*v + 0.0001*random.NormFloat64()
+		samples[i][1] = samples[i][0]
+	}
+
+	return audio.ToInput(audio.NewMemoryReader(audio.Format{SampleRate: testSampleRate, Channels: 2, BitDepth: 16}, samples))
+}
+
+func TestTrackerFollowsMelody(t *testing.T) {
+	notes := []float64{110, 261.63, 0, 440, 1046.5, 82.41}
+	tracker, err := pitch.NewTracker(melody(notes...), 10*time.Millisecond, pitch.Config{}, fft.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	estimates, err := tracker.ComputeAll()
+	tracker.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(estimates) < 550 {
+		t.Fatalf("%d frames", len(estimates))
+	}
+
+	for _, e := range estimates {
+		// frames which span two notes can go either way
+		into := math.Mod(e.Time.Seconds(), 1)
+		if into < 0.05 || into > 0.95 {
+			continue
+		}
+
+		hz := notes[int(e.Time.Seconds())]
+		if hz == 0 {
+			if e.Voiced || e.Confidence != 0 {
+				t.Fatalf("silence at %v is %v", e.Time, e)
+			}
+
+			continue
+		}
+
+		if !e.Voiced || e.Confidence < 0.9 {
+			t.Fatalf("%v Hz at %v is unvoiced: %+v", hz, e.Time, e)
+		}
+
+		if cents := 1200 * math.Log2(e.Frequency/hz); math.Abs(cents) > 5 {
+			t.Fatalf("%v Hz at %v came out as %v Hz", hz, e.Time, e.Frequency)
+		}
+	}
+}
+
+func TestNotes(t *testing.T) {
+	tracker, err := pitch.NewTracker(melody(261.63, 440*math.Pow(2, 0.2/12)), 100*time.Millisecond, pitch.Config{}, fft.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	estimates, err := tracker.ComputeAll()
+	tracker.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, last := estimates[2], estimates[len(estimates)-2]
+	if first.Note() != "C4" || math.Abs(first.MIDI()-60) > 0.05 {
+		t.Fatalf("%s (%v)", first.Note(), first.MIDI())
+	}
+
+	if last.Note() != "A4" || math.Abs(last.Cents()-20) > 3 {
+		t.Fatalf("%s %+v cents", last.Note(), last.Cents())
+	}
+
+	names := map[int]string{60: "C4", 69: "A4", 61: "C#4", 21: "A0", 11: "B-1", 127: "G9"}
+	for midi, name := range names {
+		if n := pitch.NoteName(midi); n != name {
+			t.Fatalf("MIDI %d is %s, expected %s", midi, n, name)
+		}
+	}
+}
+
+func TestShortHop(t *testing.T) {
+	// 22050 Hz has about 45µs between samples
+	if _, err := pitch.NewTracker(melody(440), 10*time.Microsecond, pitch.Config{}, fft.Options{}); err == nil {
+		t.Fatal("expected a hop shorter than one sample to be rejected")
+	}
+}
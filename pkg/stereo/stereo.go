@@ -0,0 +1,168 @@
+package stereo
+
+import (
+	"math"
+
+	"github.com/Twister915/vis.go/pkg/fft"
+)
+
+// the stereo image of audio, measured from the complex spectra of its left and right channels. Sums over a one sided
+// spectrum count every bin but DC and the last (Nyquist) twice, so by Parseval they are the same as sums over the windowed
+// samples of the frame, assuming an even transform size. Mono input is measured as if both channels were the same, and
+// only the first two channels of anything wider are used
+
+// the stereo image of one frame
+type Frame struct {
+	// the correlation of the channels from -1 to 1, what a phase correlation meter shows. 1 is mono, 0 is unrelated
+	// channels (very wide) and -1 is one channel the inverse of the other, which cancels out when mixed to mono. 0 when
+	// either channel is silent
+	Correlation float64
+
+	// where the energy sits from -1 (all left) to 1 (all right), 0 when balanced or silent
+	Balance float64
+
+	// the mean square of the windowed mid ((L + R) / 2) and side ((L - R) / 2) signals over the frame
+	Mid, Side float64
+
+	// the share of the energy in the side signal, Side / (Mid + Side). 0 is mono, a half is unrelated channels and 1 is
+	// channels which cancel out. 0 when silent
+	Width float64
+
+	// where each bin's energy sits from -1 (all left) to 1 (all right), indexed [bin]. A sound panned by the constant
+	// power law to an angle θ from hard left (0) to hard right (π / 2) comes out as -cos 2θ. 0 for silent bins
+	Panning []float64
+}
+
+// measures one spectrum indexed [bin][channel] (like FFTByFrame.ComputeComplex) into dst, reusing dst.Panning when it is
+// long enough
+func Measure(spectrum [][]complex128, dst *Frame) {
+	if cap(dst.Panning) < len(spectrum) {
+		dst.Panning = make([]float64, len(spectrum))
+	}
+
+	dst.Panning = dst.Panning[:len(spectrum)]
+
+	var left, right, cross float64
+	for k, bin := range spectrum {
+		l, r := channels(bin)
+
+		ll := real(l)*real(l) + imag(l)*imag(l)
+		rr := real(r)*real(r) + imag(r)*imag(r)
+		lr := real(l)*real(r) + imag(l)*imag(r)
+
+		dst.Panning[k] = share(rr-ll, rr+ll)
+
+		weight := 2.0
+		if k == 0 || k == len(spectrum)-1 {
+			weight = 1
+		}
+
+		left += weight * ll
+		right += weight * rr
+		cross += weight * lr
+	}
+
+	if left > 0 && right > 0 {
+		dst.Correlation = cross / math.Sqrt(left*right)
+	} else {
+		dst.Correlation = 0
+	}
+
+	dst.Balance = share(right-left, right+left)
+
+	// Σ x² = Σ |X|² / N, and the mean divides by N again
+	n := float64(2 * (len(spectrum) - 1))
+	if n <= 0 {
+		n = 1
+	}
+
+	// |L + R|² = |L|² + |R|² + 2 Re L R*, and a quarter of that for the mid
+	dst.Mid = (left + right + 2*cross) / 4 / (n * n)
+	dst.Side = (left + right - 2*cross) / 4 / (n * n)
+	dst.Width = share(dst.Side, dst.Mid+dst.Side)
+}
+
+// the left and right values of a bin
+func channels(bin []complex128) (l, r complex128) {
+	l = bin[0]
+	r = l
+	if len(bin) > 1 {
+		r = bin[1]
+	}
+
+	return
+}
+
+// x / total, or 0 when total is
+func share(x, total float64) float64 {
+	if total <= 0 {
+		return 0
+	}
+
+	return x / total
+}
+
+// converts samples indexed [sample][channel] from left & right to mid & side, dst can be samples. Mono samples have no
+// side
+func MidSide(samples [][]float64, dst [][]float64) {
+	for i, frame := range samples {
+		l, r := frame[0], frame[0]
+		if len(frame) > 1 {
+			r = frame[1]
+		}
+
+		dst[i][0] = (l + r) / 2
+		if len(dst[i]) > 1 {
+			dst[i][1] = (l - r) / 2
+		}
+	}
+}
+
+// measures an FFTByFrame's frames as they are computed
+type Analyzer struct {
+	frames   *fft.FFTByFrame
+	spectrum [][]complex128
+}
+
+func NewAnalyzer(frames *fft.FFTByFrame) *Analyzer {
+	out := &Analyzer{frames: frames, spectrum: make([][]complex128, frames.NumberOutputFrequencies())}
+	spectrumN := make([]complex128, len(out.spectrum)*frames.Channels())
+	for i := range out.spectrum {
+		out.spectrum[i], spectrumN = spectrumN[:frames.Channels()], spectrumN[frames.Channels():]
+	}
+
+	return out
+}
+
+func (a *Analyzer) HasNext() bool {
+	return a.frames.HasNext()
+}
+
+// the next frame, with its own Panning
+func (a *Analyzer) Compute() (out Frame, err error) {
+	if err = a.frames.ComputeComplex(a.spectrum); err != nil {
+		return
+	}
+
+	Measure(a.spectrum, &out)
+	return
+}
+
+// every remaining frame
+func (a *Analyzer) ComputeAll() (all []Frame, err error) {
+	for a.HasNext() {
+		var frame Frame
+		if frame, err = a.Compute(); err != nil {
+			return
+		}
+
+		all = append(all, frame)
+	}
+
+	return
+}
+
+// closes the FFTByFrame
+func (a *Analyzer) Close() error {
+	return a.frames.Close()
+}
@@ -0,0 +1,163 @@
+package stereo_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/Twister915/vis.go/pkg/audio"
+	"github.com/Twister915/vis.go/pkg/fft"
+	"github.com/Twister915/vis.go/pkg/stereo"
+	"github.com/Twister915/vis.go/pkg/util"
+)
+
+const (
+	testSampleRate = 16000
+	frameSamples   = 2048
+)
+
+// every frame of samples indexed [sample][channel], Hann windowed and without DC removal
+func analyze(t *testing.T, samples [][]float64) []stereo.Frame {
+	input := audio.ToInput(audio.NewMemoryReader(audio.Format{SampleRate: testSampleRate, Channels: len(samples[0]), BitDepth: 16}, samples))
+	size := time.Duration(frameSamples) * input.Timebase()
+	a := stereo.NewAnalyzer(fft.NewFFTByFrameOptions(input, size, size/2, fft.HannWindow, fft.Options{}))
+	defer a.Close()
+
+	all, err := a.ComputeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(all) == 0 {
+		t.Fatal("no frames")
+	}
+
+	return all
+}
+
+// a second of stereo samples from fn, given the sample's time in seconds
+func generate(fn func(t float64) (l, r float64)) [][]float64 {
+	samples := util.Create2DFloats(testSampleRate, 2)
+	for i := range samples {
+		samples[i][0], samples[i][1] = fn(float64(i) / testSampleRate)
+	}
+
+	return samples
+}
+
+func sine(hz, t float64) float64 {
+	return math.Sin(2 * math.Pi * hz * t)
+}
+
+func near(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+func TestMono(t *testing.T) {
+	samples := generate(func(t float64) (l, r float64) {
+		v := 0.5 * sine(1000, t)
+		return v, v
+	})
+
+	// a sine of amplitude A has a mean square of A² / 2, the window scales that by Σw² / N
+	var sumSquares float64
+	for i := 0; i < frameSamples; i++ {
+		w := fft.HannWindow(float64(i), frameSamples)
+		sumSquares += w * w
+	}
+
+	expectedMid := 0.25 / 2 * sumSquares / frameSamples
+
+	for _, frame := range analyze(t, samples) {
+		if !near(frame.Correlation, 1, 1e-9) || !near(frame.Balance, 0, 1e-9) || !near(frame.Width, 0, 1e-9) || frame.Side > 1e-12 {
+			t.Fatalf("mono frame %+v", frame)
+		}
+
+		if !near(frame.Mid, expectedMid, 0.01*expectedMid) {
+			t.Fatalf("mid %v, expected %v", frame.Mid, expectedMid)
+		}
+
+		for k, p := range frame.Panning {
+			if !near(p, 0, 1e-9) {
+				t.Fatalf("bin %d panned %v", k, p)
+			}
+		}
+	}
+}
+
+func TestOutOfPhase(t *testing.T) {
+	samples := generate(func(t float64) (l, r float64) {
+		v := 0.5 * sine(440, t)
+		return v, -v
+	})
+
+	for _, frame := range analyze(t, samples) {
+		if !near(frame.Correlation, -1, 1e-9) || !near(frame.Width, 1, 1e-9) || frame.Mid > 1e-12 {
+			t.Fatalf("out of phase frame %+v", frame)
+		}
+	}
+}
+
+func TestUncorrelated(t *testing.T) {
+	random := rand.New(rand.NewSource(3))
+	samples := generate(func(float64) (l, r float64) {
+		return 0.2 * random.NormFloat64(), 0.2 * random.NormFloat64()
+	})
+
+	for _, frame := range analyze(t, samples) {
+		if !near(frame.Correlation, 0, 0.1) || !near(frame.Width, 0.5, 0.05) || !near(frame.Balance, 0, 0.1) {
+			t.Fatalf("uncorrelated frame correlation %v, width %v, balance %v", frame.Correlation, frame.Width, frame.Balance)
+		}
+	}
+}
+
+func TestPanning(t *testing.T) {
+	// constant power panning, 500 Hz a quarter of the way from the left and 3000 Hz hard right
+	low, high := math.Pi/8, math.Pi/2
+	samples := generate(func(t float64) (l, r float64) {
+		a, b := 0.3*sine(500, t), 0.3*sine(3000, t)
+		return a*math.Cos(low) + b*math.Cos(high), a*math.Sin(low) + b*math.Sin(high)
+	})
+
+	binHz := float64(testSampleRate) / frameSamples
+	for _, frame := range analyze(t, samples) {
+		if p := frame.Panning[int(500/binHz)]; !near(p, -math.Cos(2*low), 1e-6) {
+			t.Fatalf("500 Hz panned %v, expected %v", p, -math.Cos(2*low))
+		}
+
+		if p := frame.Panning[int(3000/binHz)]; !near(p, 1, 1e-6) {
+			t.Fatalf("3000 Hz panned %v", p)
+		}
+
+		// equal energy, one a little left and one right
+		if expected := (-math.Cos(2*low) + 1) / 2; !near(frame.Balance, expected, 0.01) {
+			t.Fatalf("balance %v, expected %v", frame.Balance, expected)
+		}
+	}
+
+	// left only
+	samples = generate(func(t float64) (l, r float64) {
+		return 0.3 * sine(500, t), 0
+	})
+
+	for _, frame := range analyze(t, samples) {
+		if frame.Balance != -1 || frame.Correlation != 0 || frame.Panning[int(500/binHz)] != -1 {
+			t.Fatalf("left only frame correlation %v, balance %v", frame.Correlation, frame.Balance)
+		}
+	}
+}
+
+func TestMidSide(t *testing.T) {
+	samples := [][]float64{{1, 0}, {0.5, 0.5}, {0.2, -0.2}}
+	expected := [][]float64{{0.5, 0.5}, {0.5, 0}, {0, 0.2}}
+
+	stereo.MidSide(samples, samples)
+	for i := range samples {
+		for ch := range samples[i] {
+			if !near(samples[i][ch], expected[i][ch], 1e-12) {
+				t.Fatalf("sample %d: %v, expected %v", i, samples[i], expected[i])
+			}
+		}
+	}
+}
@@ -47,7 +47,7 @@ func (m *MMapSeeker) Seek(to int64, rel int) (n int64, err error) {
 
 	if m.off < 0 {
 		err = errors.New("negative position")
-	} else if m.off >= l {
+	} else if m.off > l {
 		err = errors.New("past end of file")
 	} else {
 		n = m.off
@@ -0,0 +1,49 @@
+package wav
+
+import "fmt"
+
+// the file ended before a header field, or before the amount of data the data chunk declared
+type TruncatedError struct {
+	// byte offset the read started at
+	Offset int64
+	Want   int64
+	Got    int64
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("truncated file at offset %d (wanted %d bytes, got %d)", e.Offset, e.Want, e.Got)
+}
+
+// the fmt chunk describes something other than 8 or 16 bit integer PCM
+type UnsupportedCodecError struct {
+	// byte offset of the fmt chunk body
+	Offset        int64
+	AudioFormat   uint16
+	BitsPerSample uint16
+}
+
+func (e *UnsupportedCodecError) Error() string {
+	return fmt.Sprintf("unsupported codec at offset %d (format tag 0x%04x, %d bits per sample)", e.Offset, e.AudioFormat, e.BitsPerSample)
+}
+
+// a chunk header or body does not make sense
+type MalformedChunkError struct {
+	// byte offset of the chunk header
+	Offset  int64
+	ChunkID string
+	Reason  string
+}
+
+func (e *MalformedChunkError) Error() string {
+	return fmt.Sprintf("malformed chunk '%s' at offset %d: %s", e.ChunkID, e.Offset, e.Reason)
+}
+
+// the input was used after Close
+type ClosedError struct {
+	// byte offset the input was positioned at when it was closed
+	Offset int64
+}
+
+func (e *ClosedError) Error() string {
+	return fmt.Sprintf("read from closed file (at offset %d)", e.Offset)
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"os"
 	"strings"
 	"sync"
@@ -17,11 +18,30 @@ import (
 	"golang.org/x/exp/mmap"
 )
 
-var errShortRead = errors.New("need more bytes (n < min bytes)")
+// how forgiving the parser is with files that do not follow the spec
+type Mode int
+
+const (
+	// accepts every file ReadWav always has: inconsistent block alignment is fixed up, odd sized chunks may be missing
+	// their pad byte, and a data chunk running past the end of the file is read until the samples run out (when the
+	// read methods return a *TruncatedError)
+	Compatible Mode = iota
+
+	// any deviation from the spec is returned as an error
+	Strict
+
+	// recovers truncated data chunks (and data sizes of 0 or 0xFFFFFFFF written by streaming encoders), fixes up
+	// inconsistent block alignment, and skips over chunks with bad sizes or missing pad bytes
+	Lenient
+)
+
+// how far to scan for the next valid chunk when Lenient parsing hits garbage
+const maxResync = 1 << 16
 
 type wavInput struct {
 	f      io.ReadSeeker
 	header wavHeader
+	mode   Mode
 
 	mutex    *sync.Mutex
 	frame    int
@@ -30,7 +50,7 @@ type wavInput struct {
 
 	buf []byte
 
-	dataStart int
+	dataStart int64
 }
 
 type wavHeader struct {
@@ -70,10 +90,16 @@ func OpenWavPreLoad(file string) (input audio.Input, err error) {
 	return ReadWav(bytes.NewReader(data))
 }
 
+// reads the file in Compatible mode, use ReadWavMode for Strict or Lenient parsing
 func ReadWav(source io.ReadSeeker) (input audio.Input, err error) {
+	return ReadWavMode(source, Compatible)
+}
+
+func ReadWavMode(source io.ReadSeeker, mode Mode) (input audio.Input, err error) {
 	wav := new(wavInput)
 	wav.mutex = new(sync.Mutex)
 	wav.f = source
+	wav.mode = mode
 
 	if err = wav.readHeader(); err != nil {
 		return
@@ -87,136 +113,335 @@ func (w *wavInput) readHeader() (err error) {
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
 
+	if w.closed {
+		err = &ClosedError{Offset: w.position()}
+		return
+	}
+
 	w.frame = 0
 	// skip back to front of file
 	if _, err = w.f.Seek(0, io.SeekStart); err != nil {
 		return
 	}
 
-	var n int
-
 	// read...
 	//
-	//  * [4] ChunkID [read, just for validation]
+	//  * [4] ChunkID   [read, just for validation]
 	//  * [4] ChunkSize [skipped]
-	//  * [4] Format [checked]
+	//  * [4] Format    [checked]
 	//
 	{
-		var chunkID [4]byte
-		if n, err = w.f.Read(chunkID[:]); err != nil {
-			return
-		} else if n != len(chunkID) {
-			err = errShortRead
+		var riff [12]byte
+		if err = w.readFull(riff[:]); err != nil {
 			return
 		}
 
-		switch string(chunkID[:]) {
+		chunkID := string(riff[:4])
+		switch chunkID {
 		case "RIFX":
 			w.ordering = binary.BigEndian
 		case "RIFF":
 			w.ordering = binary.LittleEndian
 		default:
-			err = fmt.Errorf("invalid chunk ID '%s'", string(chunkID[:]))
+			err = &MalformedChunkError{Offset: 0, ChunkID: chunkID, Reason: "invalid chunk ID, not a RIFF file"}
 			return
 		}
 
-		if _, err = w.f.Seek(8, io.SeekCurrent); err != nil {
+		if format := string(riff[8:]); format != "WAVE" {
+			err = &MalformedChunkError{Offset: 0, ChunkID: chunkID, Reason: fmt.Sprintf("form type is '%s', not 'WAVE'", format)}
 			return
 		}
 	}
 
-	// read...
+	// read chunks until the data chunk...
 	//
-	//  * [4] SubChunkID    [read]
-	//  * [4] SubChunkSize  [read]
-	//  * [2] AudioFormat   [read]
-	//  * [2] NumChannels   [read]
-	//  * [4] SampleRate    [read]
-	//  * [4] ByteRate      [read]
-	//  * [2] BlockAlign    [read]
-	//  * [2] BitsPerSample [read]
-	//  * [?] Anything Else [skipped]
+	//  * [4] SubChunkID   [read]
+	//  * [4] SubChunkSize [read]
+	//  * [?] Body         [read if "fmt ", skipped otherwise]
 	//
-	{
-		var subChunkID [4]byte
-		if n, err = w.f.Read(subChunkID[:]); err != nil {
-			return
-		} else if n != len(subChunkID) {
-			err = errShortRead
-			return
-		}
+	haveFormat := false
+	for {
+		chunkOffset := w.offset()
+
+		var chunkHeader [8]byte
+		if err = w.readFull(chunkHeader[:]); err != nil {
+			if te, ok := err.(*TruncatedError); ok && te.Got == 0 {
+				err = &MalformedChunkError{Offset: chunkOffset, ChunkID: "data", Reason: "no data chunk found"}
+			}
 
-		if string(subChunkID[:]) != "fmt " {
-			err = fmt.Errorf("invalid sub-chunk ID '%s'", string(subChunkID[:]))
 			return
 		}
 
-		var subChunkSize uint32
-		if err = binary.Read(w.f, w.ordering, &subChunkSize); err != nil {
+		chunkID := string(chunkHeader[:4])
+		size := w.ordering.Uint32(chunkHeader[4:])
+
+		if !isFourCC(chunkHeader[:4]) {
+			switch {
+			case w.mode != Strict && w.missingPad(chunkOffset):
+				continue
+			case w.mode == Lenient:
+				if err = w.scan(chunkOffset); err != nil {
+					return
+				}
+
+				continue
+			}
+
+			err = &MalformedChunkError{Offset: chunkOffset, ChunkID: chunkID, Reason: "invalid chunk ID"}
 			return
 		}
 
-		fieldsToRead := []interface{}{
-			&w.header.AudioFormat,   //2
-			&w.header.NumChannels,   //2
-			&w.header.SampleRate,    //4
-			&w.header.ByteRate,      //4
-			&w.header.BlockAlign,    //2
-			&w.header.BitsPerSample, //2
-		}
+		switch {
+		case chunkID == "fmt ":
+			if err = w.readFormat(chunkOffset, size); err != nil {
+				return
+			}
 
-		for _, f := range fieldsToRead {
-			if err = binary.Read(w.f, w.ordering, f); err != nil {
+			haveFormat = true
+		case strings.ToLower(chunkID) == "data":
+			if !haveFormat {
+				err = &MalformedChunkError{Offset: chunkOffset, ChunkID: chunkID, Reason: "data chunk before fmt chunk"}
 				return
 			}
-		}
 
-		// if not PCM
-		if w.header.AudioFormat != 1 {
-			if _, err = w.f.Seek(int64(subChunkSize)-16, io.SeekCurrent); err != nil {
+			w.dataStart = chunkOffset + int64(len(chunkHeader))
+			w.header.DataSize = size
+
+			// file should now be pointing at the start of the data
+			err = w.checkDataSize()
+			return
+		default:
+			// a size running past the end of the file is garbage, look for the next chunk in the body instead
+			if end, ok := w.size(); ok && w.mode == Lenient && w.offset()+int64(size) > end {
+				if err = w.scan(w.offset()); err != nil {
+					return
+				}
+
+				continue
+			}
+
+			//skip this chunk
+			if err = w.skipChunk(size); err != nil {
 				return
 			}
 		}
 	}
+}
 
+func (w *wavInput) readFormat(chunkOffset int64, size uint32) (err error) {
 	// read...
-	//  * [4] SubChunkID   [read]
-	//  * [4] SubChunkSize [read]
 	//
-	for {
-		var chunkID [4]byte
-		if n, err = w.f.Read(chunkID[:]); err != nil {
-			return
-		} else if n != len(chunkID) {
-			err = errShortRead
+	//  * [2] AudioFormat   [read]
+	//  * [2] NumChannels   [read]
+	//  * [4] SampleRate    [read]
+	//  * [4] ByteRate      [read]
+	//  * [2] BlockAlign    [read]
+	//  * [2] BitsPerSample [read]
+	//  * [?] Anything Else [read for WAVE_FORMAT_EXTENSIBLE, otherwise skipped]
+	//
+	const (
+		formatPCM        = 1
+		formatExtensible = 0xFFFE
+
+		basicSize      = 16
+		extensibleSize = 40
+	)
+
+	if size < basicSize {
+		err = &MalformedChunkError{Offset: chunkOffset, ChunkID: "fmt ", Reason: fmt.Sprintf("chunk is %d bytes, need at least %d", size, basicSize)}
+		return
+	}
+
+	body := make([]byte, basicSize)
+	if size >= extensibleSize {
+		body = make([]byte, extensibleSize)
+	}
+
+	if err = w.readFull(body); err != nil {
+		return
+	}
+
+	w.header.AudioFormat = w.ordering.Uint16(body[0:])
+	w.header.NumChannels = w.ordering.Uint16(body[2:])
+	w.header.SampleRate = w.ordering.Uint32(body[4:])
+	w.header.ByteRate = w.ordering.Uint32(body[8:])
+	w.header.BlockAlign = w.ordering.Uint16(body[12:])
+	w.header.BitsPerSample = w.ordering.Uint16(body[14:])
+
+	if err = w.skipChunk(size - uint32(len(body))); err != nil {
+		return
+	}
+
+	bodyOffset := chunkOffset + 8
+	codec := w.header.AudioFormat
+	if codec == formatExtensible && len(body) >= extensibleSize {
+		// the first two bytes of the sub-format GUID are the format tag
+		codec = w.ordering.Uint16(body[24:])
+	}
+
+	if codec != formatPCM || (w.header.BitsPerSample != 8 && w.header.BitsPerSample != 16) {
+		err = &UnsupportedCodecError{Offset: bodyOffset, AudioFormat: codec, BitsPerSample: w.header.BitsPerSample}
+		return
+	}
+
+	if w.header.NumChannels == 0 || w.header.SampleRate == 0 {
+		err = &MalformedChunkError{Offset: chunkOffset, ChunkID: "fmt ", Reason: "zero channels or sample rate"}
+		return
+	}
+
+	blockAlign := w.header.NumChannels * (w.header.BitsPerSample / 8)
+	if w.header.BlockAlign != blockAlign {
+		if w.mode == Strict {
+			err = &MalformedChunkError{Offset: chunkOffset, ChunkID: "fmt ", Reason: fmt.Sprintf("block align is %d, expected %d", w.header.BlockAlign, blockAlign)}
 			return
 		}
 
-		if strings.ToLower(string(chunkID[:])) != "data" {
-			//skip this chunk
-			var size uint32
-			if err = binary.Read(w.f, w.ordering, &size); err != nil {
-				if err == io.EOF {
-					err = errors.New("no data chunk found")
-				}
+		w.header.BlockAlign = blockAlign
+	}
 
-				return
-			}
+	return
+}
 
-			if _, err = w.f.Seek(int64(size), io.SeekCurrent); err != nil {
-				return
-			}
-		} else {
-			err = binary.Read(w.f, w.ordering, &w.header.DataSize)
-			return
+// skips a chunk body of the given size, plus the pad byte which follows odd sized chunks
+func (w *wavInput) skipChunk(size uint32) (err error) {
+	_, err = w.f.Seek(int64(size)+int64(size&1), io.SeekCurrent)
+	return
+}
+
+// compares the declared size of the data chunk with the size of the file, the file must be positioned at the start of
+// the data and is left there
+func (w *wavInput) checkDataSize() (err error) {
+	end, ok := w.size()
+	if !ok {
+		return
+	}
+
+	available := end - w.dataStart
+	declared := int64(w.header.DataSize)
+	if declared <= available && !(w.mode == Lenient && declared == 0) {
+		return
+	}
+
+	switch w.mode {
+	case Strict:
+		err = &TruncatedError{Offset: w.dataStart, Want: declared, Got: available}
+		return
+	case Compatible:
+		// the read methods return a *TruncatedError once the samples run out
+		return
+	}
+
+	// streaming encoders write 0 or 0xFFFFFFFF here, and a cut-off download is still worth playing
+	available -= available % int64(w.header.BlockAlign)
+	if available > math.MaxUint32 {
+		available = math.MaxUint32 - (math.MaxUint32 % int64(w.header.BlockAlign))
+	}
+
+	w.header.DataSize = uint32(available)
+	return
+}
+
+// called when there is an invalid chunk ID at chunkOffset. The usual cause is an odd sized chunk which was written
+// without its pad byte, if there is a chunk ID one byte earlier the source is left there
+func (w *wavInput) missingPad(chunkOffset int64) bool {
+	var id [4]byte
+	if _, err := w.f.Seek(chunkOffset-1, io.SeekStart); err != nil {
+		return false
+	}
+
+	if err := w.readFull(id[:]); err != nil || !isFourCC(id[:]) {
+		return false
+	}
+
+	_, err := w.f.Seek(chunkOffset-1, io.SeekStart)
+	return err == nil
+}
+
+// seeks to the first "fmt " or "data" chunk within maxResync bytes of offset
+func (w *wavInput) scan(offset int64) (err error) {
+	if _, err = w.f.Seek(offset, io.SeekStart); err != nil {
+		return
+	}
+
+	window := make([]byte, maxResync)
+	n, _ := io.ReadFull(w.f, window)
+	window = window[:n]
+
+	next := -1
+	for _, want := range []string{"fmt ", "data", "DATA"} {
+		if i := bytes.Index(window, []byte(want)); i >= 0 && (next < 0 || i < next) {
+			next = i
 		}
 	}
 
-	// file should now be pointing at the start of the data
+	if next < 0 {
+		err = &MalformedChunkError{Offset: offset, ChunkID: string(window[:4]), Reason: "no fmt or data chunk follows"}
+		return
+	}
+
+	_, err = w.f.Seek(offset+int64(next), io.SeekStart)
+	return
+}
+
+// reads exactly len(buf) bytes, a short read is returned as a *TruncatedError
+func (w *wavInput) readFull(buf []byte) (err error) {
+	var n int
+	if n, err = io.ReadFull(w.f, buf); err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = &TruncatedError{Offset: w.offset() - int64(n), Want: int64(len(buf)), Got: int64(n)}
+	}
+
+	return
+}
+
+// the current byte offset in the source, or -1 if it cannot be determined
+func (w *wavInput) offset() int64 {
+	off, err := w.f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return -1
+	}
+
+	return off
+}
+
+// the byte offset of the current frame, computed without touching the source (so it works after Close)
+func (w *wavInput) position() int64 {
+	return w.dataStart + int64(w.frame)*int64(w.header.BlockAlign)
+}
+
+// the size of the source in bytes, leaves the position unchanged
+func (w *wavInput) size() (end int64, ok bool) {
+	cur := w.offset()
+	if cur < 0 {
+		return
+	}
 
+	end, err := w.f.Seek(0, io.SeekEnd)
+	if _, sErr := w.f.Seek(cur, io.SeekStart); err != nil || sErr != nil {
+		return
+	}
+
+	ok = true
 	return
 }
 
+func isFourCC(id []byte) bool {
+	for _, b := range id {
+		if b < ' ' || b > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (w *wavInput) Format() audio.Format {
+	return audio.Format{
+		BitDepth:   w.BitDepth(),
+		Channels:   w.Channels(),
+		SampleRate: w.SampleRate(),
+	}
+}
+
 func (w *wavInput) BitDepth() int {
 	return int(w.header.BitsPerSample)
 }
@@ -249,29 +474,90 @@ func (w *wavInput) ReadSamplesDir(to [][]float64, dir audio.SampleReadDirection)
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
 
+	var buf []byte
+	if buf, n, err = w.readFrames(len(to), len(to[0]), dir); err != nil {
+		return
+	}
+
+	dataI := 0
+	for i := 0; i < n; i++ {
+		for z := 0; z < int(w.header.NumChannels); z++ {
+			var v float64
+			if v, dataI, err = w.decodeSample(buf, dataI); err != nil {
+				return
+			}
+
+			switch dir {
+			case audio.ReadSampleByChannel:
+				to[i][z] = v
+			case audio.ReadChannelBySample:
+				to[z][i] = v
+			}
+		}
+
+		w.frame++
+	}
+
+	return
+}
+
+// same as ReadSamplesDir, but writes float32 samples (so the float32 pipeline never allocates float64 buffers)
+func (w *wavInput) ReadSamplesDir32(to [][]float32, dir audio.SampleReadDirection) (n int, err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	var buf []byte
+	if buf, n, err = w.readFrames(len(to), len(to[0]), dir); err != nil {
+		return
+	}
+
+	dataI := 0
+	for i := 0; i < n; i++ {
+		for z := 0; z < int(w.header.NumChannels); z++ {
+			var v float64
+			if v, dataI, err = w.decodeSample(buf, dataI); err != nil {
+				return
+			}
+
+			switch dir {
+			case audio.ReadSampleByChannel:
+				to[i][z] = float32(v)
+			case audio.ReadChannelBySample:
+				to[z][i] = float32(v)
+			}
+		}
+
+		w.frame++
+	}
+
+	return
+}
+
+// validates the shape of a destination buffer (rows x cols) and reads the raw bytes for as many frames as fit in it,
+// must be called with the mutex held
+func (w *wavInput) readFrames(rows, cols int, dir audio.SampleReadDirection) (buf []byte, n int, err error) {
 	switch dir {
 	case audio.ReadSampleByChannel:
-		if len(to[0]) != int(w.header.NumChannels) {
+		if cols != int(w.header.NumChannels) {
 			err = errors.New("must pass [][]float64 pre-allocated with correct number of channels")
 			return
 		}
+
+		n = rows
 	case audio.ReadChannelBySample:
-		if len(to) != int(w.header.NumChannels) {
+		if rows != int(w.header.NumChannels) {
 			err = errors.New("must pass [][]float64 pre-allocated with correct number of channels")
 			return
 		}
+
+		n = cols
 	default:
 		panic("invalid dir")
 	}
 
 	if w.closed {
-		panic("read from closed file")
-	}
-
-	if dir == audio.ReadSampleByChannel {
-		n = len(to)
-	} else {
-		n = len(to[0])
+		err = &ClosedError{Offset: w.position()}
+		return
 	}
 
 	{
@@ -288,7 +574,6 @@ func (w *wavInput) ReadSamplesDir(to [][]float64, dir audio.SampleReadDirection)
 	}
 
 	size := int(w.header.BlockAlign) * n
-	var buf []byte
 	if len(w.buf) == size {
 		buf = w.buf
 	} else if len(w.buf) > size {
@@ -298,43 +583,25 @@ func (w *wavInput) ReadSamplesDir(to [][]float64, dir audio.SampleReadDirection)
 		buf = w.buf
 	}
 
-	var bn int
-	if bn, err = io.ReadFull(w.f, buf); err != nil {
-		return
-	} else if bn < len(buf) {
-		err = errShortRead
-		return
-	}
-
-	dataI := 0
-	for i := 0; i < n; i++ {
-		for z := 0; z < int(w.header.NumChannels); z++ {
-			var v float64
-			switch int(w.header.BitsPerSample) {
-			case 8:
-				v = float64(int8(buf[dataI])) / (float64(1<<7) - 1)
-				dataI++
-			case 16:
-				const bytes16 = 2
-				twoBits := buf[dataI : dataI+bytes16]
-				vUint := w.ordering.Uint16(twoBits)
-				vInt := int16(vUint)
-				v = float64(vInt) / (float64(1<<15) - 1)
-				dataI += bytes16
-			default:
-				err = fmt.Errorf("no support for this bits per sample (%d)", w.header.BitsPerSample)
-				return
-			}
-
-			switch dir {
-			case audio.ReadSampleByChannel:
-				to[i][z] = v
-			case audio.ReadChannelBySample:
-				to[z][i] = v
-			}
-		}
+	err = w.readFull(buf)
+	return
+}
 
-		w.frame++
+// decodes the sample starting at buf[dataI], returning the value in [-1, 1] and the index of the next sample
+func (w *wavInput) decodeSample(buf []byte, dataI int) (v float64, next int, err error) {
+	switch int(w.header.BitsPerSample) {
+	case 8:
+		v = float64(int8(buf[dataI])) / (float64(1<<7) - 1)
+		next = dataI + 1
+	case 16:
+		const bytes16 = 2
+		twoBits := buf[dataI : dataI+bytes16]
+		vUint := w.ordering.Uint16(twoBits)
+		vInt := int16(vUint)
+		v = float64(vInt) / (float64(1<<15) - 1)
+		next = dataI + bytes16
+	default:
+		err = &UnsupportedCodecError{Offset: w.position(), AudioFormat: w.header.AudioFormat, BitsPerSample: w.header.BitsPerSample}
 	}
 
 	return
@@ -389,13 +656,18 @@ func (w *wavInput) Seek(n int) (err error) {
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
 
+	if w.closed {
+		err = &ClosedError{Offset: w.position()}
+		return
+	}
+
 	futureFrame := w.frame + n
 	if futureFrame < 0 || futureFrame >= w.Frames() {
 		err = io.EOF
 		return
 	}
 
-	bytesSeek := int64(n * int(w.header.NumChannels) * int(w.header.BitsPerSample/8))
+	bytesSeek := int64(n) * int64(w.header.BlockAlign)
 	if _, err = w.f.Seek(bytesSeek, io.SeekCurrent); err != nil {
 		return
 	}
@@ -0,0 +1,202 @@
+package wav_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bytes"
+	"encoding/binary"
+
+	"github.com/Twister915/vis.go/pkg/util"
+	. "github.com/Twister915/vis.go/pkg/wav"
+)
+
+type chunk struct {
+	id   string
+	size int
+	body []byte
+}
+
+// builds a little endian RIFF file out of the chunks, size -1 means use the length of the body
+func buildWav(chunks ...chunk) []byte {
+	var body bytes.Buffer
+	body.WriteString("WAVE")
+	for _, c := range chunks {
+		body.WriteString(c.id)
+		size := c.size
+		if size == -1 {
+			size = len(c.body)
+		}
+
+		binary.Write(&body, binary.LittleEndian, uint32(size))
+		body.Write(c.body)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("RIFF")
+	binary.Write(&out, binary.LittleEndian, uint32(body.Len()))
+	out.Write(body.Bytes())
+	return out.Bytes()
+}
+
+func fmtChunk(format, channels, bits uint16, sampleRate uint32) chunk {
+	var b bytes.Buffer
+	blockAlign := channels * (bits / 8)
+	for _, v := range []interface{}{format, channels, sampleRate, sampleRate * uint32(blockAlign), blockAlign, bits} {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+
+	return chunk{id: "fmt ", size: -1, body: b.Bytes()}
+}
+
+func pcm16(samples ...int16) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, samples)
+	return b.Bytes()
+}
+
+var _ = Describe("WavInput", func() {
+	samples := pcm16(0, 100, 200, 300, 400, 500, 600, 700)
+
+	It("reads a well formed file", func() {
+		in, err := ReadWav(bytes.NewReader(buildWav(fmtChunk(1, 2, 16, 8000), chunk{"data", -1, samples})))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(in.Channels()).Should(Equal(2))
+		Expect(in.Frames()).Should(Equal(4))
+
+		out := util.Create2DFloats(4, 2)
+		n, err := in.ReadSamples(out)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(n).Should(Equal(4))
+		Expect(out[3][1]).Should(BeNumerically("~", 700.0/32767, 1e-9))
+	})
+
+	It("skips odd sized chunks and their pad byte", func() {
+		in, err := ReadWav(bytes.NewReader(buildWav(
+			fmtChunk(1, 2, 16, 8000),
+			chunk{"LIST", 3, []byte{1, 2, 3, 0}},
+			chunk{"data", -1, samples},
+		)))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(in.Frames()).Should(Equal(4))
+	})
+
+	It("rejects files which are not RIFF", func() {
+		_, err := ReadWav(bytes.NewReader([]byte("OggS0000WAVE")))
+		Expect(err).Should(BeAssignableToTypeOf(&MalformedChunkError{}))
+		Expect(err.(*MalformedChunkError).Offset).Should(BeEquivalentTo(0))
+	})
+
+	It("reports unsupported codecs", func() {
+		_, err := ReadWav(bytes.NewReader(buildWav(fmtChunk(3, 2, 32, 8000), chunk{"data", -1, samples})))
+		Expect(err).Should(BeAssignableToTypeOf(&UnsupportedCodecError{}))
+
+		codecErr := err.(*UnsupportedCodecError)
+		Expect(codecErr.AudioFormat).Should(BeEquivalentTo(3))
+		Expect(codecErr.Offset).Should(BeEquivalentTo(20))
+	})
+
+	It("reports a missing data chunk", func() {
+		_, err := ReadWav(bytes.NewReader(buildWav(fmtChunk(1, 2, 16, 8000))))
+		Expect(err).Should(BeAssignableToTypeOf(&MalformedChunkError{}))
+	})
+
+	Describe("truncated data", func() {
+		truncated := buildWav(fmtChunk(1, 2, 16, 8000), chunk{"data", 64, samples})
+
+		It("is an error in strict mode", func() {
+			_, err := ReadWavMode(bytes.NewReader(truncated), Strict)
+			Expect(err).Should(BeAssignableToTypeOf(&TruncatedError{}))
+
+			truncErr := err.(*TruncatedError)
+			Expect(truncErr.Offset).Should(BeEquivalentTo(44))
+			Expect(truncErr.Want).Should(BeEquivalentTo(64))
+			Expect(truncErr.Got).Should(BeEquivalentTo(len(samples)))
+		})
+
+		It("is read until the samples run out by default", func() {
+			in, err := ReadWav(bytes.NewReader(truncated))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(in.Frames()).Should(Equal(16))
+
+			_, err = in.ReadSamples(util.Create2DFloats(16, 2))
+			Expect(err).Should(BeAssignableToTypeOf(&TruncatedError{}))
+		})
+
+		It("is recovered in lenient mode", func() {
+			in, err := ReadWavMode(bytes.NewReader(truncated), Lenient)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(in.Frames()).Should(Equal(4))
+		})
+
+		It("recovers streaming data sizes in lenient mode", func() {
+			in, err := ReadWavMode(bytes.NewReader(buildWav(fmtChunk(1, 2, 16, 8000), chunk{"data", 0, samples})), Lenient)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(in.Frames()).Should(Equal(4))
+		})
+	})
+
+	It("recovers a chunk missing its pad byte unless strict", func() {
+		file := buildWav(
+			fmtChunk(1, 2, 16, 8000),
+			chunk{"LIST", 3, []byte{1, 2, 3}},
+			chunk{"data", -1, samples},
+		)
+
+		_, err := ReadWavMode(bytes.NewReader(file), Strict)
+		Expect(err).Should(BeAssignableToTypeOf(&MalformedChunkError{}))
+
+		for _, mode := range []Mode{Compatible, Lenient} {
+			in, err := ReadWavMode(bytes.NewReader(file), mode)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(in.Frames()).Should(Equal(4))
+		}
+	})
+
+	It("fixes up the block align unless strict", func() {
+		file := buildWav(fmtChunk(1, 2, 16, 8000), chunk{"data", -1, samples})
+		binary.LittleEndian.PutUint16(file[32:], 8)
+
+		_, err := ReadWavMode(bytes.NewReader(file), Strict)
+		Expect(err).Should(BeAssignableToTypeOf(&MalformedChunkError{}))
+
+		in, err := ReadWav(bytes.NewReader(file))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(in.Frames()).Should(Equal(4))
+
+		out := util.Create2DFloats(4, 2)
+		_, err = in.ReadSamples(out)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(out[3][1]).Should(BeNumerically("~", 700.0/32767, 1e-9))
+	})
+
+	It("recovers a chunk whose size runs past the end of the file in lenient mode", func() {
+		file := buildWav(
+			fmtChunk(1, 2, 16, 8000),
+			chunk{"LIST", 0x7FFFFFFF, []byte("INFOISFT")},
+			chunk{"data", -1, samples},
+		)
+
+		_, err := ReadWav(bytes.NewReader(file))
+		Expect(err).Should(BeAssignableToTypeOf(&MalformedChunkError{}))
+
+		in, err := ReadWavMode(bytes.NewReader(file), Lenient)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(in.Frames()).Should(Equal(4))
+
+		out := util.Create2DFloats(4, 2)
+		_, err = in.ReadSamples(out)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(out[3][1]).Should(BeNumerically("~", 700.0/32767, 1e-9))
+	})
+
+	It("returns an error instead of panicking after close", func() {
+		in, err := ReadWav(bytes.NewReader(buildWav(fmtChunk(1, 2, 16, 8000), chunk{"data", -1, samples})))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(in.Close()).Should(Succeed())
+
+		_, err = in.ReadSamples(util.Create2DFloats(2, 2))
+		Expect(err).Should(BeAssignableToTypeOf(&ClosedError{}))
+		Expect(err.(*ClosedError).Offset).Should(BeEquivalentTo(44))
+	})
+})
@@ -0,0 +1,13 @@
+package wav_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestWav(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Wav Suite")
+}